@@ -1,7 +1,6 @@
 package binary
 
 import (
-	"bytes"
 	"io"
 
 	"github.com/tinywasm/fmt"
@@ -22,13 +21,13 @@ func Encode(input model.Encodable, output any) error {
 	var err error
 	switch out := output.(type) {
 	case *[]byte:
-		var buffer bytes.Buffer
-		w.reset(&buffer)
+		w.resetBuffer(nil, false)
 		input.EncodeFields(w)
 		if w.err == nil {
-			*out = buffer.Bytes()
+			*out = w.buf
 		}
 		err = w.err
+		w.buf = nil
 	case io.Writer:
 		w.reset(out)
 		input.EncodeFields(w)
@@ -40,10 +39,60 @@ func Encode(input model.Encodable, output any) error {
 	return err
 }
 
+// AppendEncode appends the encoding of v to dst and returns the extended
+// slice. dst is only grown when its capacity is exhausted, so a reused buffer
+// makes encoding allocation-free. On error the returned slice has the
+// length of dst.
+func AppendEncode(dst []byte, v model.Encodable) ([]byte, error) {
+	if v == nil || v.IsNil() {
+		return dst, fmt.Err("Encode: input is nil")
+	}
+
+	w := getWriter()
+	defer putWriter(w)
+
+	w.resetBuffer(dst, false)
+	v.EncodeFields(w)
+	out := w.buf
+	w.buf = nil
+	if w.err != nil {
+		return dst, w.err
+	}
+	return out, nil
+}
+
+// EncodeInto writes the encoding of v into buf and returns the number of
+// bytes written. It never grows buf: when the encoding does not fit it
+// returns 0 and ErrShortBuffer. Use Size to find the required length.
+func EncodeInto(buf []byte, v model.Encodable) (n int, err error) {
+	if v == nil || v.IsNil() {
+		return 0, fmt.Err("Encode: input is nil")
+	}
+
+	w := getWriter()
+	defer putWriter(w)
+
+	w.resetBuffer(buf[:0:len(buf)], true)
+	v.EncodeFields(w)
+	n, err = len(w.buf), w.err
+	w.buf = nil
+	if err != nil {
+		return 0, err
+	}
+	return n, nil
+}
+
 // Decode decodes input to output.
 // input: []byte or io.Reader
 // output: pointer to Decodable struct
+// Truncated or corrupt input is reported as ErrTruncated, ErrVarintOverflow
+// or ErrInvalidInput, and input breaking DefaultLimits as ErrLimitExceeded.
 func Decode(input, output any) error {
+	return DecodeWithLimits(input, output, DefaultLimits)
+}
+
+// DecodeWithLimits is like Decode but enforces limits instead of DefaultLimits.
+func DecodeWithLimits(input, output any, limits Limits) error {
 	if output == nil {
 		return fmt.Err("Decode: output is nil")
 	}
@@ -62,11 +111,13 @@ func Decode(input, output any) error {
 	var err error
 	switch in := input.(type) {
 	case []byte:
-		r.reset(bytes.NewReader(in))
+		r.reset(newSliceReader(in), limits)
 		dec.DecodeFields(r)
+		err = r.err
 	case io.Reader:
-		r.reset(in)
+		r.reset(in, limits)
 		dec.DecodeFields(r)
+		err = r.err
 	default:
 		err = fmt.Err("Decode", "input", "must be []byte or io.Reader")
 	}
@@ -74,7 +125,9 @@ func Decode(input, output any) error {
 	return err
 }
 
-// SetLog is deprecated and does nothing.
+// SetLog does nothing.
+//
+// Deprecated: log values with Sprint instead.
 func SetLog(fn func(msg ...any)) {}
 
 // Errorf is a helper for fmt.Errorf
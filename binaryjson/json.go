@@ -0,0 +1,864 @@
+// Package binaryjson converts values in the positional binary encoding to
+// JSON and back using only a binary.Schema, without the Go type. It lives
+// outside the binary package because exact float conversion needs strconv,
+// which TinyGo builds of the core package leave out.
+package binaryjson
+
+import (
+	"bytes"
+	"cmp"
+	"encoding/base64"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+
+	"github.com/tinywasm/binary"
+	"github.com/tinywasm/fmt"
+	"github.com/tinywasm/model"
+)
+
+// ToJSON converts an encoded value to JSON using the schema of its type, so
+// the Go type does not need to be linked in. Objects keep the schema field
+// order; Bytes become base64 strings, maps become objects with string keys,
+// nil objects, Null fields and absent optional values become null, and
+// non-finite floats become the strings "NaN", "+Inf" and "-Inf".
+func ToJSON(data []byte, s *binary.Schema) ([]byte, error) {
+	if s == nil {
+		return nil, fmt.Err("ToJSON", "schema", "is nil")
+	}
+	t := &transcoder{}
+	in := bytes.NewReader(data)
+	if err := binary.Decode(in, &jsonObject{t: t, s: s}); err != nil {
+		return nil, err
+	}
+	if t.err == nil && in.Len() > 0 {
+		t.fail("ToJSON", "trailing bytes after value")
+	}
+	if t.err != nil {
+		return nil, t.err
+	}
+	return t.out, nil
+}
+
+// FromJSON converts JSON produced by ToJSON, or written by hand, back to the
+// binary encoding described by s. Missing fields are encoded as their zero
+// value; unknown fields are an error.
+func FromJSON(json []byte, s *binary.Schema) ([]byte, error) {
+	if s == nil {
+		return nil, fmt.Err("FromJSON", "schema", "is nil")
+	}
+	p := jsonParser{in: json, maxDepth: binary.DefaultLimits.MaxDepth}
+	v := p.value(0)
+	if p.err == nil {
+		p.space()
+		if p.pos < len(p.in) {
+			p.fail("unexpected data after value")
+		}
+	}
+	if p.err != nil {
+		return nil, p.err
+	}
+
+	t := &transcoder{}
+	var out []byte
+	err := binary.Encode(&jsonObject{t: t, v: &v, s: s}, &out)
+	if t.err != nil {
+		return nil, t.err
+	}
+	return out, err
+}
+
+// transcoder holds the state of one conversion, in either direction.
+type transcoder struct {
+	out []byte // ToJSON output
+	err error
+}
+
+func (t *transcoder) fail(msgs ...any) {
+	if t.err == nil {
+		t.err = fmt.Err(msgs...)
+	}
+}
+
+// jsonObject is one object being converted: a model.Decodable appending the
+// fields it reads as JSON for ToJSON, and a model.Encodable writing the
+// members of v for FromJSON.
+type jsonObject struct {
+	t    *transcoder
+	name string
+	s    *binary.Schema
+	v    *jsonValue // FromJSON input
+	read bool       // DecodeFields was called
+}
+
+func (o *jsonObject) IsNil() bool { return o == nil }
+
+// uintReader and uintWriter are implemented by the readers and writers of
+// the binary package, which support unsigned fields beyond model's.
+type uintReader interface {
+	Uint(name string) (uint64, bool)
+}
+
+type uintWriter interface {
+	Uint(name string, val uint64)
+}
+
+// --- binary to JSON ---
+
+func (o *jsonObject) DecodeFields(r model.FieldReader) {
+	t := o.t
+	o.read = true
+	if o.s == nil {
+		t.fail("ToJSON", "field", o.name, "has no schema")
+		return
+	}
+	t.out = append(t.out, '{')
+	for i, f := range o.s.Fields {
+		if t.err != nil {
+			return
+		}
+		if i > 0 {
+			t.out = append(t.out, ',')
+		}
+		t.out = appendJSONString(t.out, f.Name)
+		t.out = append(t.out, ':')
+		switch f.Kind {
+		case binary.KindArray:
+			t.array(r, f)
+		case binary.KindMap:
+			t.mapping(r, f)
+		case binary.KindObject:
+			t.nested(f.Name, f.Schema, func(into model.Decodable) { r.Object(f.Name, into) })
+		case binary.KindNull:
+			if v, ok := r.Bool(f.Name); ok && v {
+				t.err = binary.ErrInvalidInput
+			}
+			t.out = append(t.out, "null"...)
+		case binary.KindOptional:
+			or, ok := r.(binary.OptionalReader)
+			if !ok {
+				t.fail("ToJSON", "field", f.Name, "cannot be read as optional")
+				return
+			}
+			if present, ok := or.Present(f.Name); ok && present {
+				t.scalar(r, f.Name, f.Elem)
+			} else if ok {
+				t.out = append(t.out, "null"...)
+			}
+		default:
+			t.scalar(r, f.Name, f.Kind)
+		}
+	}
+	t.out = append(t.out, '}')
+}
+
+// nested writes an Object read by read, or null when it is nil.
+func (t *transcoder) nested(name string, s *binary.Schema, read func(into model.Decodable)) {
+	o := &jsonObject{t: t, name: name, s: s}
+	read(o)
+	if !o.read {
+		t.out = append(t.out, "null"...)
+	}
+}
+
+func (t *transcoder) array(r model.FieldReader, f binary.SchemaField) {
+	ar, ok := r.Array(f.Name)
+	if !ok {
+		return
+	}
+	n := ar.Len()
+	if n > 0 && f.Elem == binary.KindInvalid {
+		t.fail("ToJSON", "field", f.Name, "has no element kind")
+		return
+	}
+	t.out = append(t.out, '[')
+	for i := 0; i < n && t.err == nil; i++ {
+		if i > 0 {
+			t.out = append(t.out, ',')
+		}
+		t.elem(ar, i, f)
+	}
+	t.out = append(t.out, ']')
+}
+
+// elem writes element i of an array or map with the element kind of f.
+func (t *transcoder) elem(ar model.ArrayReader, i int, f binary.SchemaField) {
+	if f.Elem == binary.KindObject {
+		t.nested(f.Name, f.Schema, func(into model.Decodable) { ar.Object(i, into) })
+	} else {
+		t.scalar(elemReader{ar, i}, f.Name, f.Elem)
+	}
+}
+
+// mapping writes a Map as a JSON object; integer keys become strings.
+func (t *transcoder) mapping(r model.FieldReader, f binary.SchemaField) {
+	mr, ok := r.(binary.MapReader)
+	if !ok {
+		t.fail("ToJSON", "field", f.Name, "cannot be read as a map")
+		return
+	}
+	ar, ok := mr.Map(f.Name)
+	if !ok {
+		return
+	}
+	n := ar.Len() / 2
+	if n > 0 && (f.Elem == binary.KindInvalid || (f.Key != binary.KindString && f.Key != binary.KindInt)) {
+		t.fail("ToJSON", "field", f.Name, "has no key or value kind")
+		return
+	}
+	t.out = append(t.out, '{')
+	for i := 0; i < n && t.err == nil; i++ {
+		if i > 0 {
+			t.out = append(t.out, ',')
+		}
+		if f.Key == binary.KindString {
+			t.scalar(elemReader{ar, 2 * i}, f.Name, binary.KindString)
+		} else {
+			t.out = append(t.out, '"')
+			t.out = strconv.AppendInt(t.out, ar.Int(2*i), 10)
+			t.out = append(t.out, '"')
+		}
+		t.out = append(t.out, ':')
+		t.elem(ar, 2*i+1, f)
+	}
+	t.out = append(t.out, '}')
+}
+
+func (t *transcoder) scalar(r model.FieldReader, name string, kind binary.Kind) {
+	switch kind {
+	case binary.KindString, binary.KindRaw:
+		if v, ok := r.String(name); ok {
+			if !utf8.ValidString(v) {
+				t.fail("ToJSON", "field", name, "is not valid UTF-8")
+				return
+			}
+			t.out = appendJSONString(t.out, v)
+		}
+	case binary.KindInt:
+		if v, ok := r.Int(name); ok {
+			t.out = strconv.AppendInt(t.out, v, 10)
+		}
+	case binary.KindUint:
+		ur, ok := r.(uintReader)
+		if !ok {
+			t.fail("ToJSON", "field", name, "has unsupported kind", kind.String())
+			return
+		}
+		if v, ok := ur.Uint(name); ok {
+			t.out = strconv.AppendUint(t.out, v, 10)
+		}
+	case binary.KindFloat:
+		if v, ok := r.Float(name); ok {
+			t.out = appendJSONFloat(t.out, v, 64)
+		}
+	case binary.KindFloat32:
+		if v, ok := binary.ReadFloat32(r, name); ok {
+			t.out = appendJSONFloat(t.out, float64(v), 32)
+		}
+	case binary.KindFixed32:
+		if v, ok := binary.ReadFixed32(r, name); ok {
+			t.out = strconv.AppendUint(t.out, uint64(v), 10)
+		}
+	case binary.KindFixed64:
+		if v, ok := binary.ReadFixed64(r, name); ok {
+			t.out = strconv.AppendUint(t.out, v, 10)
+		}
+	case binary.KindBool:
+		if v, ok := r.Bool(name); ok {
+			t.out = strconv.AppendBool(t.out, v)
+		}
+	case binary.KindBytes:
+		if v, ok := r.Bytes(name); ok {
+			t.out = append(t.out, '"')
+			t.out = base64.StdEncoding.AppendEncode(t.out, v)
+			t.out = append(t.out, '"')
+		}
+	default:
+		t.fail("ToJSON", "field", name, "has unsupported kind", kind.String())
+	}
+}
+
+// appendJSONFloat appends the shortest representation of v that reads back
+// as the same float of bitSize bits.
+func appendJSONFloat(b []byte, v float64, bitSize int) []byte {
+	switch {
+	case math.IsNaN(v):
+		return append(b, `"NaN"`...)
+	case math.IsInf(v, 1):
+		return append(b, `"+Inf"`...)
+	case math.IsInf(v, -1):
+		return append(b, `"-Inf"`...)
+	}
+	return strconv.AppendFloat(b, v, 'g', -1, bitSize)
+}
+
+func appendJSONString(b []byte, s string) []byte {
+	const hex = "0123456789abcdef"
+	b = append(b, '"')
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == '"' || c == '\\':
+			b = append(b, '\\', c)
+		case c == '\n':
+			b = append(b, '\\', 'n')
+		case c == '\r':
+			b = append(b, '\\', 'r')
+		case c == '\t':
+			b = append(b, '\\', 't')
+		case c < 0x20:
+			b = append(b, '\\', 'u', '0', '0', hex[c>>4], hex[c&0xf])
+		default:
+			b = append(b, c)
+		}
+	}
+	return append(b, '"')
+}
+
+// elemReader reads element i of an array as a field, so that scalar serves
+// fields and elements alike.
+type elemReader struct {
+	ar model.ArrayReader
+	i  int
+}
+
+func (e elemReader) String(name string) (string, bool)      { return e.ar.String(e.i), true }
+func (e elemReader) Raw(name string) (string, bool)         { return e.ar.String(e.i), true }
+func (e elemReader) Int(name string) (int64, bool)          { return e.ar.Int(e.i), true }
+func (e elemReader) Float(name string) (float64, bool)      { return e.ar.Float(e.i), true }
+func (e elemReader) Bool(name string) (bool, bool)          { return e.ar.Bool(e.i), true }
+func (e elemReader) Bytes(name string) ([]byte, bool)       { return e.ar.Bytes(e.i), true }
+func (e elemReader) Array(string) (model.ArrayReader, bool) { return nil, false }
+
+func (e elemReader) Object(name string, into model.Decodable) bool {
+	return e.ar.Object(e.i, into)
+}
+
+func (e elemReader) Float32(name string) (float32, bool) {
+	if cr, ok := e.ar.(binary.CompactArrayReader); ok {
+		return cr.Float32(e.i), true
+	}
+	return float32(e.ar.Float(e.i)), true
+}
+
+func (e elemReader) Fixed32(name string) (uint32, bool) {
+	if cr, ok := e.ar.(binary.CompactArrayReader); ok {
+		return cr.Fixed32(e.i), true
+	}
+	return uint32(e.ar.Int(e.i)), true
+}
+
+func (e elemReader) Fixed64(name string) (uint64, bool) {
+	if cr, ok := e.ar.(binary.CompactArrayReader); ok {
+		return cr.Fixed64(e.i), true
+	}
+	return uint64(e.ar.Int(e.i)), true
+}
+
+// --- JSON to binary ---
+
+func (o *jsonObject) EncodeFields(w model.FieldWriter) {
+	t, v, s := o.t, o.v, o.s
+	if v.kind != 'o' {
+		t.fail("FromJSON", "field", o.name, "expects an object")
+		return
+	}
+	for _, m := range v.obj {
+		if _, ok := s.Field(m.name); !ok {
+			t.fail("FromJSON", "unknown field", m.name)
+			return
+		}
+	}
+	for _, f := range s.Fields {
+		if t.err != nil {
+			return
+		}
+		fv := v.member(f.Name)
+		switch f.Kind {
+		case binary.KindArray:
+			t.writeArray(w, f, fv)
+		case binary.KindMap:
+			t.writeMap(w, f, fv)
+		case binary.KindObject:
+			t.writeNested(w, f.Name, fv, f.Schema)
+		case binary.KindNull:
+			if fv.kind != 'n' {
+				t.fail("FromJSON", "field", f.Name, "expects null")
+			}
+			w.Null(f.Name)
+		case binary.KindOptional:
+			ow, ok := w.(binary.OptionalWriter)
+			if !ok {
+				t.fail("FromJSON", "field", f.Name, "cannot be written as optional")
+				return
+			}
+			ow.Present(f.Name, fv.kind != 'n')
+			if fv.kind != 'n' {
+				t.writeScalar(w, f.Name, f.Elem, fv)
+			}
+		default:
+			t.writeScalar(w, f.Name, f.Kind, fv)
+		}
+	}
+}
+
+func (t *transcoder) writeNested(w model.FieldWriter, name string, v *jsonValue, s *binary.Schema) {
+	if v.kind == 'n' {
+		w.Object(name, nil)
+		return
+	}
+	if s == nil {
+		t.fail("FromJSON", "field", name, "has no schema")
+		return
+	}
+	w.Object(name, &jsonObject{t: t, name: name, v: v, s: s})
+}
+
+func (t *transcoder) writeArray(w model.FieldWriter, f binary.SchemaField, v *jsonValue) {
+	if v.kind == 'n' {
+		w.Array(f.Name, 0).Close()
+		return
+	}
+	if v.kind != 'a' {
+		t.fail("FromJSON", "field", f.Name, "expects an array")
+		return
+	}
+	if len(v.arr) > 0 && f.Elem == binary.KindInvalid {
+		t.fail("FromJSON", "field", f.Name, "has no element kind")
+		return
+	}
+	aw := w.Array(f.Name, len(v.arr))
+	for i := range v.arr {
+		t.writeElem(aw, f, &v.arr[i])
+	}
+	aw.Close()
+}
+
+// writeElem writes an array element or map value with the element kind of f.
+func (t *transcoder) writeElem(aw model.ArrayWriter, f binary.SchemaField, v *jsonValue) {
+	if f.Elem == binary.KindObject {
+		t.writeNested(elemWriter{aw}, f.Name, v, f.Schema)
+	} else {
+		t.writeScalar(elemWriter{aw}, f.Name, f.Elem, v)
+	}
+}
+
+// writeMap writes a JSON object as a Map, sorting the entries by key like
+// binary.WriteMap does.
+func (t *transcoder) writeMap(w model.FieldWriter, f binary.SchemaField, v *jsonValue) {
+	mw, ok := w.(binary.MapWriter)
+	if !ok {
+		t.fail("FromJSON", "field", f.Name, "cannot be written as a map")
+		return
+	}
+	if v.kind == 'n' {
+		mw.Map(f.Name, 0, f.Key, f.Elem).Close()
+		return
+	}
+	if v.kind != 'o' {
+		t.fail("FromJSON", "field", f.Name, "expects an object")
+		return
+	}
+	if len(v.obj) > 0 && (f.Elem == binary.KindInvalid || (f.Key != binary.KindString && f.Key != binary.KindInt)) {
+		t.fail("FromJSON", "field", f.Name, "has no key or value kind")
+		return
+	}
+	type entry struct {
+		key int64
+		m   *jsonMember
+	}
+	entries := make([]entry, len(v.obj))
+	for i := range v.obj {
+		entries[i].m = &v.obj[i]
+		if f.Key == binary.KindInt {
+			k, err := strconv.ParseInt(v.obj[i].name, 10, 64)
+			if err != nil {
+				t.fail("FromJSON", "field", f.Name, "expects integer keys")
+				return
+			}
+			entries[i].key = k
+		}
+	}
+	slices.SortFunc(entries, func(a, b entry) int {
+		if f.Key == binary.KindInt {
+			return cmp.Compare(a.key, b.key)
+		}
+		return strings.Compare(a.m.name, b.m.name)
+	})
+	for i := 1; i < len(entries); i++ {
+		if entries[i].m.name == entries[i-1].m.name || (f.Key == binary.KindInt && entries[i].key == entries[i-1].key) {
+			t.fail("FromJSON", "field", f.Name, "has duplicate key", entries[i].m.name)
+			return
+		}
+	}
+
+	aw := mw.Map(f.Name, len(entries), f.Key, f.Elem)
+	for _, e := range entries {
+		if f.Key == binary.KindInt {
+			aw.Int(e.key)
+		} else {
+			aw.String(e.m.name)
+		}
+		t.writeElem(aw, f, &e.m.val)
+	}
+	aw.Close()
+}
+
+func (t *transcoder) writeScalar(w model.FieldWriter, name string, kind binary.Kind, v *jsonValue) {
+	var err error
+	switch kind {
+	case binary.KindString, binary.KindRaw:
+		if v.kind != 's' && v.kind != 'n' {
+			t.fail("FromJSON", "field", name, "expects a string")
+			return
+		}
+		w.String(name, v.str)
+	case binary.KindInt:
+		var n int64
+		if v.kind != 'n' {
+			n, err = strconv.ParseInt(v.number(), 10, 64)
+		}
+		w.Int(name, n)
+	case binary.KindUint:
+		uw, ok := w.(uintWriter)
+		if !ok {
+			t.fail("FromJSON", "field", name, "has unsupported kind", kind.String())
+			return
+		}
+		var n uint64
+		if v.kind != 'n' {
+			n, err = strconv.ParseUint(v.number(), 10, 64)
+		}
+		uw.Uint(name, n)
+	case binary.KindFloat, binary.KindFloat32:
+		bitSize := 64
+		if kind == binary.KindFloat32 {
+			bitSize = 32
+		}
+		var f float64
+		switch {
+		case v.kind == 's' && (v.str == "NaN" || v.str == "+Inf" || v.str == "-Inf"):
+			f, _ = strconv.ParseFloat(v.str, bitSize)
+		case v.kind != 'n':
+			f, err = strconv.ParseFloat(v.number(), bitSize)
+		}
+		if kind == binary.KindFloat32 {
+			binary.WriteFloat32(w, name, float32(f))
+		} else {
+			w.Float(name, f)
+		}
+	case binary.KindFixed32:
+		var n uint64
+		if v.kind != 'n' {
+			n, err = strconv.ParseUint(v.number(), 10, 32)
+		}
+		binary.WriteFixed32(w, name, uint32(n))
+	case binary.KindFixed64:
+		var n uint64
+		if v.kind != 'n' {
+			n, err = strconv.ParseUint(v.number(), 10, 64)
+		}
+		binary.WriteFixed64(w, name, n)
+	case binary.KindBool:
+		if v.kind != 'b' && v.kind != 'n' {
+			t.fail("FromJSON", "field", name, "expects a bool")
+			return
+		}
+		w.Bool(name, v.b)
+	case binary.KindBytes:
+		if v.kind != 's' && v.kind != 'n' {
+			t.fail("FromJSON", "field", name, "expects a base64 string")
+			return
+		}
+		var b []byte
+		if b, err = base64.StdEncoding.DecodeString(v.str); err == nil {
+			w.Bytes(name, b)
+		}
+	default:
+		t.fail("FromJSON", "field", name, "has unsupported kind", kind.String())
+	}
+	if err != nil {
+		t.fail("FromJSON", "field", name, "expects", kind.String())
+	}
+}
+
+// elemWriter writes a value as an array element, so that writeScalar and
+// writeNested serve fields and elements alike.
+type elemWriter struct {
+	aw model.ArrayWriter
+}
+
+func (e elemWriter) String(name, val string)                 { e.aw.String(val) }
+func (e elemWriter) Raw(name, val string)                    { e.aw.String(val) }
+func (e elemWriter) Int(name string, val int64)              { e.aw.Int(val) }
+func (e elemWriter) Float(name string, val float64)          { e.aw.Float(val) }
+func (e elemWriter) Bool(name string, val bool)              { e.aw.Bool(val) }
+func (e elemWriter) Bytes(name string, val []byte)           { e.aw.Bytes(val) }
+func (e elemWriter) Null(name string)                        { e.aw.Object(nil) }
+func (e elemWriter) Object(name string, val model.Encodable) { e.aw.Object(val) }
+
+// Array is never called: elements are not arrays in the positional format.
+func (e elemWriter) Array(string, int) model.ArrayWriter { return nil }
+
+func (e elemWriter) Float32(name string, val float32) {
+	if cw, ok := e.aw.(binary.CompactArrayWriter); ok {
+		cw.Float32(val)
+	} else {
+		e.aw.Float(float64(val))
+	}
+}
+
+func (e elemWriter) Fixed32(name string, val uint32) {
+	if cw, ok := e.aw.(binary.CompactArrayWriter); ok {
+		cw.Fixed32(val)
+	} else {
+		e.aw.Int(int64(val))
+	}
+}
+
+func (e elemWriter) Fixed64(name string, val uint64) {
+	if cw, ok := e.aw.(binary.CompactArrayWriter); ok {
+		cw.Fixed64(val)
+	} else {
+		e.aw.Int(int64(val))
+	}
+}
+
+// --- minimal JSON parser ---
+
+// jsonValue is a parsed JSON value; kind is one of n b s 0 a o
+// (null, bool, string, number, array, object).
+type jsonValue struct {
+	kind byte
+	b    bool
+	str  string // string contents, or the literal text of a number
+	arr  []jsonValue
+	obj  []jsonMember
+}
+
+type jsonMember struct {
+	name string
+	val  jsonValue
+}
+
+var jsonNull = jsonValue{kind: 'n'}
+
+// member returns the value of an object member, or null when absent.
+func (v *jsonValue) member(name string) *jsonValue {
+	for i := range v.obj {
+		if v.obj[i].name == name {
+			return &v.obj[i].val
+		}
+	}
+	return &jsonNull
+}
+
+// number returns the literal of a number value; other kinds fail to parse.
+func (v *jsonValue) number() string {
+	if v.kind != '0' {
+		return "!"
+	}
+	return v.str
+}
+
+type jsonParser struct {
+	in       []byte
+	pos      int
+	maxDepth int
+	err      error
+}
+
+func (p *jsonParser) fail(msg string) {
+	if p.err == nil {
+		p.err = fmt.Err("FromJSON", msg, "at offset", p.pos)
+	}
+}
+
+func (p *jsonParser) space() {
+	for p.pos < len(p.in) {
+		switch p.in[p.pos] {
+		case ' ', '\t', '\n', '\r':
+			p.pos++
+		default:
+			return
+		}
+	}
+}
+
+func (p *jsonParser) literal(lit string) bool {
+	if len(p.in)-p.pos >= len(lit) && string(p.in[p.pos:p.pos+len(lit)]) == lit {
+		p.pos += len(lit)
+		return true
+	}
+	return false
+}
+
+func (p *jsonParser) value(depth int) jsonValue {
+	p.space()
+	if p.pos >= len(p.in) {
+		p.fail("unexpected end of input")
+		return jsonNull
+	}
+	if p.maxDepth > 0 && depth > p.maxDepth {
+		p.fail("nesting too deep")
+		return jsonNull
+	}
+	switch c := p.in[p.pos]; {
+	case c == '{':
+		return p.object(depth)
+	case c == '[':
+		return p.array(depth)
+	case c == '"':
+		return jsonValue{kind: 's', str: p.string()}
+	case p.literal("null"):
+		return jsonNull
+	case p.literal("true"):
+		return jsonValue{kind: 'b', b: true}
+	case p.literal("false"):
+		return jsonValue{kind: 'b'}
+	case c == '-' || (c >= '0' && c <= '9'):
+		start := p.pos
+		for p.pos < len(p.in) && isJSONNumberByte(p.in[p.pos]) {
+			p.pos++
+		}
+		return jsonValue{kind: '0', str: string(p.in[start:p.pos])}
+	}
+	p.fail("unexpected character")
+	return jsonNull
+}
+
+func isJSONNumberByte(c byte) bool {
+	return (c >= '0' && c <= '9') || c == '-' || c == '+' || c == '.' || c == 'e' || c == 'E'
+}
+
+func (p *jsonParser) object(depth int) jsonValue {
+	v := jsonValue{kind: 'o'}
+	p.pos++ // {
+	p.space()
+	if p.pos < len(p.in) && p.in[p.pos] == '}' {
+		p.pos++
+		return v
+	}
+	for p.err == nil {
+		p.space()
+		if p.pos >= len(p.in) || p.in[p.pos] != '"' {
+			p.fail("expected member name")
+			break
+		}
+		name := p.string()
+		p.space()
+		if p.pos >= len(p.in) || p.in[p.pos] != ':' {
+			p.fail("expected ':'")
+			break
+		}
+		p.pos++
+		v.obj = append(v.obj, jsonMember{name: name, val: p.value(depth + 1)})
+		if p.end('}') {
+			break
+		}
+	}
+	return v
+}
+
+func (p *jsonParser) array(depth int) jsonValue {
+	v := jsonValue{kind: 'a'}
+	p.pos++ // [
+	p.space()
+	if p.pos < len(p.in) && p.in[p.pos] == ']' {
+		p.pos++
+		return v
+	}
+	for p.err == nil {
+		v.arr = append(v.arr, p.value(depth+1))
+		if p.end(']') {
+			break
+		}
+	}
+	return v
+}
+
+// end consumes a ',' (returning false) or the closing byte (returning true).
+func (p *jsonParser) end(close byte) bool {
+	p.space()
+	if p.pos < len(p.in) {
+		switch p.in[p.pos] {
+		case ',':
+			p.pos++
+			return false
+		case close:
+			p.pos++
+			return true
+		}
+	}
+	p.fail("expected ',' or '" + string(close) + "'")
+	return true
+}
+
+func (p *jsonParser) string() string {
+	p.pos++ // opening quote
+	var out []byte
+	for p.pos < len(p.in) {
+		c := p.in[p.pos]
+		switch {
+		case c == '"':
+			p.pos++
+			return string(out)
+		case c == '\\':
+			p.pos++
+			if p.pos >= len(p.in) {
+				p.fail("unterminated string")
+				return ""
+			}
+			e := p.in[p.pos]
+			p.pos++
+			switch e {
+			case '"', '\\', '/':
+				out = append(out, e)
+			case 'b':
+				out = append(out, '\b')
+			case 'f':
+				out = append(out, '\f')
+			case 'n':
+				out = append(out, '\n')
+			case 'r':
+				out = append(out, '\r')
+			case 't':
+				out = append(out, '\t')
+			case 'u':
+				r := p.hex4()
+				if utf16.IsSurrogate(r) && p.literal(`\u`) {
+					r = utf16.DecodeRune(r, p.hex4())
+				}
+				out = utf8.AppendRune(out, r)
+			default:
+				p.fail("invalid escape")
+				return ""
+			}
+		case c < 0x20:
+			p.fail("control character in string")
+			return ""
+		default:
+			out = append(out, c)
+			p.pos++
+		}
+	}
+	p.fail("unterminated string")
+	return ""
+}
+
+func (p *jsonParser) hex4() rune {
+	if len(p.in)-p.pos < 4 {
+		p.fail("invalid unicode escape")
+		return utf8.RuneError
+	}
+	n, err := strconv.ParseUint(string(p.in[p.pos:p.pos+4]), 16, 16)
+	if err != nil {
+		p.fail("invalid unicode escape")
+		return utf8.RuneError
+	}
+	p.pos += 4
+	return rune(n)
+}
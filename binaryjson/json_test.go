@@ -0,0 +1,311 @@
+package binaryjson
+
+import (
+	"bytes"
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/tinywasm/binary"
+	"github.com/tinywasm/model"
+)
+
+type basic struct {
+	Name      string
+	Timestamp int64
+	Payload   []byte
+	Tags      []uint32
+	Count     int16
+	Active    bool
+	Score     float64
+}
+
+func (b *basic) IsNil() bool { return b == nil }
+
+func (b *basic) EncodeFields(w model.FieldWriter) {
+	w.String("Name", b.Name)
+	w.Int("Timestamp", b.Timestamp)
+	w.Bytes("Payload", b.Payload)
+	aw := w.Array("Tags", len(b.Tags))
+	for _, v := range b.Tags {
+		aw.Int(int64(v))
+	}
+	w.Int("Count", int64(b.Count))
+	w.Bool("Active", b.Active)
+	w.Float("Score", b.Score)
+}
+
+func (b *basic) DecodeFields(r model.FieldReader) {
+	b.Name, _ = r.String("Name")
+	b.Timestamp, _ = r.Int("Timestamp")
+	b.Payload, _ = r.Bytes("Payload")
+	b.Tags = nil
+	if ar, ok := r.Array("Tags"); ok && ar.Len() > 0 {
+		b.Tags = make([]uint32, ar.Len())
+		for i := range b.Tags {
+			b.Tags[i] = uint32(ar.Int(i))
+		}
+	}
+	count, _ := r.Int("Count")
+	b.Count = int16(count)
+	b.Active, _ = r.Bool("Active")
+	b.Score, _ = r.Float("Score")
+}
+
+type nested struct {
+	ID        uint64
+	Primary   basic
+	Secondary *basic
+	List      []basic
+	Matrix    [3]int
+}
+
+func (n *nested) IsNil() bool { return n == nil }
+
+func (n *nested) EncodeFields(w model.FieldWriter) {
+	w.Int("ID", int64(n.ID))
+	w.Object("Primary", &n.Primary)
+	w.Object("Secondary", n.Secondary)
+	aw := w.Array("List", len(n.List))
+	for i := range n.List {
+		aw.Object(&n.List[i])
+	}
+	aw = w.Array("Matrix", len(n.Matrix))
+	for _, v := range n.Matrix {
+		aw.Int(int64(v))
+	}
+}
+
+func (n *nested) DecodeFields(r model.FieldReader) {
+	id, _ := r.Int("ID")
+	n.ID = uint64(id)
+	r.Object("Primary", &n.Primary)
+	n.Secondary = &basic{}
+	if !r.Object("Secondary", n.Secondary) {
+		n.Secondary = nil
+	}
+	n.List = nil
+	if ar, ok := r.Array("List"); ok && ar.Len() > 0 {
+		n.List = make([]basic, ar.Len())
+		for i := range n.List {
+			ar.Object(i, &n.List[i])
+		}
+	}
+	if ar, ok := r.Array("Matrix"); ok {
+		for i := 0; i < ar.Len() && i < len(n.Matrix); i++ {
+			n.Matrix[i] = int(ar.Int(i))
+		}
+	}
+}
+
+// nestedSchema returns the schema of nested, with every nested schema known.
+func nestedSchema() *binary.Schema {
+	return binary.SchemaOf(&nested{
+		Primary:   basic{Tags: []uint32{1}},
+		Secondary: &basic{},
+		List:      []basic{{}},
+	})
+}
+
+// extras covers the extension kinds: maps, optional values and fixed-width
+// numbers.
+type extras struct {
+	Labels  map[string]string
+	Names   map[int]string
+	Users   map[string]*basic
+	Count   *int
+	Name    *string
+	Temp    float32
+	ID      uint32
+	Hash    uint64
+	Samples []float32
+}
+
+func (e *extras) IsNil() bool { return e == nil }
+
+func (e *extras) EncodeFields(w model.FieldWriter) {
+	binary.WriteMap(w, "Labels", e.Labels)
+	binary.WriteMap(w, "Names", e.Names)
+	binary.WriteObjectMap(w, "Users", e.Users)
+	binary.WriteOptional(w, "Count", e.Count)
+	binary.WriteOptional(w, "Name", e.Name)
+	binary.WriteFloat32(w, "Temp", e.Temp)
+	binary.WriteFixed32(w, "ID", e.ID)
+	binary.WriteFixed64(w, "Hash", e.Hash)
+	binary.WriteFloat32s(w, "Samples", e.Samples)
+}
+
+func ptr[T any](v T) *T { return &v }
+
+func extrasValue() *extras {
+	return &extras{
+		Labels:  map[string]string{"env": "prod", "app": "api"},
+		Names:   map[int]string{3: "c", 1: "a", 2: "b"},
+		Users:   map[string]*basic{"bob": {Name: "Bob", Tags: []uint32{1}}, "nil": nil},
+		Count:   ptr(-42),
+		Temp:    0.1,
+		ID:      0xdeadbeef,
+		Hash:    1<<63 + 5,
+		Samples: []float32{1.5, -2.25, 0.1},
+	}
+}
+
+func TestJSONRoundTrip(t *testing.T) {
+	s := nestedSchema()
+	input := &nested{
+		ID: 42,
+		Primary: basic{
+			Name:      "Alice \"A\" \né",
+			Timestamp: -1234567890,
+			Payload:   []byte{0, 1, 0xfe, 0xff},
+			Tags:      []uint32{1, 2, 3},
+			Count:     -7,
+			Active:    true,
+			Score:     3.25,
+		},
+		List:   []basic{{Name: "one"}, {Name: "two", Score: math.Inf(-1)}},
+		Matrix: [3]int{1, -2, 3},
+	}
+	var data []byte
+	if err := binary.Encode(input, &data); err != nil {
+		t.Fatalf("Encode failed: %v", err)
+	}
+
+	js, err := ToJSON(data, s)
+	if err != nil {
+		t.Fatalf("ToJSON failed: %v", err)
+	}
+	want := `{"ID":42,"Primary":{"Name":"Alice \"A\" \n` + "é" + `","Timestamp":-1234567890,` +
+		`"Payload":"AAH+/w==","Tags":[1,2,3],"Count":-7,"Active":true,"Score":3.25},"Secondary":null,` +
+		`"List":[{"Name":"one","Timestamp":0,"Payload":"","Tags":[],"Count":0,"Active":false,"Score":0},` +
+		`{"Name":"two","Timestamp":0,"Payload":"","Tags":[],"Count":0,"Active":false,"Score":"-Inf"}],"Matrix":[1,-2,3]}`
+	if string(js) != want {
+		t.Errorf("Expected\n%s\ngot\n%s", want, js)
+	}
+
+	back, err := FromJSON(js, s)
+	if err != nil {
+		t.Fatalf("FromJSON failed: %v", err)
+	}
+	if !bytes.Equal(back, data) {
+		t.Errorf("Expected %x, got %x", data, back)
+	}
+}
+
+func TestJSONAllKinds(t *testing.T) {
+	inner := &binary.Schema{Fields: []binary.SchemaField{{Name: "V", Kind: binary.KindUint}}}
+	s := &binary.Schema{Fields: []binary.SchemaField{
+		{Name: "S", Kind: binary.KindString},
+		{Name: "R", Kind: binary.KindRaw},
+		{Name: "I", Kind: binary.KindInt},
+		{Name: "U", Kind: binary.KindUint},
+		{Name: "F", Kind: binary.KindFloat},
+		{Name: "B", Kind: binary.KindBool},
+		{Name: "Y", Kind: binary.KindBytes},
+		{Name: "N", Kind: binary.KindNull},
+		{Name: "O", Kind: binary.KindObject, Schema: inner},
+		{Name: "A", Kind: binary.KindArray, Elem: binary.KindObject, Schema: inner},
+		{Name: "X", Kind: binary.KindArray, Elem: binary.KindFloat},
+	}}
+	js := `{"S":"tab\t","R":"{\"raw\":1}","I":-9223372036854775808,"U":18446744073709551615,` +
+		`"F":1e-300,"B":false,"Y":"AQI=","N":null,"O":{"V":7},"A":[{"V":1},null],"X":["NaN","+Inf",0.5]}`
+
+	data, err := FromJSON([]byte(js), s)
+	if err != nil {
+		t.Fatalf("FromJSON failed: %v", err)
+	}
+	out, err := ToJSON(data, s)
+	if err != nil {
+		t.Fatalf("ToJSON failed: %v", err)
+	}
+	if string(out) != js {
+		t.Errorf("Expected\n%s\ngot\n%s", js, out)
+	}
+}
+
+func TestJSONExtensionKinds(t *testing.T) {
+	in := extrasValue()
+	s := binary.SchemaOf(in)
+	var data []byte
+	if err := binary.Encode(in, &data); err != nil {
+		t.Fatal(err)
+	}
+	js, err := ToJSON(data, s)
+	if err != nil {
+		t.Fatalf("ToJSON failed: %v", err)
+	}
+	want := `{"Labels":{"app":"api","env":"prod"},"Names":{"1":"a","2":"b","3":"c"},` +
+		`"Users":{"bob":{"Name":"Bob","Timestamp":0,"Payload":"","Tags":[1],"Count":0,"Active":false,"Score":0},"nil":null},` +
+		`"Count":-42,"Name":null,"Temp":0.1,"ID":3735928559,"Hash":9223372036854775813,"Samples":[1.5,-2.25,0.1]}`
+	if string(js) != want {
+		t.Errorf("Expected\n%s\ngot\n%s", want, js)
+	}
+	back, err := FromJSON(js, s)
+	if err != nil || !bytes.Equal(data, back) {
+		t.Errorf("Expected %x, got %x, %v", data, back, err)
+	}
+
+	// Keys are sorted when converting from hand-written JSON.
+	reordered := strings.Replace(string(js), `{"1":"a","2":"b","3":"c"}`, `{"3":"c","1":"a","2":"b"}`, 1)
+	if back, err := FromJSON([]byte(reordered), s); err != nil || !bytes.Equal(data, back) {
+		t.Errorf("Expected sorted entries, got %x, %v", back, err)
+	}
+	for _, bad := range []string{`{"Names":{"x":"a"}}`, `{"Names":{"1":"a","01":"b"}}`, `{"Names":[]}`, `{"ID":4294967296}`} {
+		if _, err := FromJSON([]byte(bad), s); err == nil {
+			t.Errorf("Expected error for %s", bad)
+		}
+	}
+}
+
+func TestFromJSONDefaults(t *testing.T) {
+	s := nestedSchema()
+	data, err := FromJSON([]byte(` { "ID" : 5, "Primary": {"Name": "A😀"} } `), s)
+	if err != nil {
+		t.Fatalf("FromJSON failed: %v", err)
+	}
+	decoded := &nested{}
+	if err := binary.Decode(data, decoded); err != nil {
+		t.Fatalf("Decode failed: %v", err)
+	}
+	if decoded.ID != 5 || decoded.Primary.Name != "A\U0001F600" || decoded.Secondary != nil || len(decoded.List) != 0 {
+		t.Errorf("Unexpected decode: %+v", decoded)
+	}
+}
+
+func TestJSONErrors(t *testing.T) {
+	s := nestedSchema()
+	bad := []string{
+		``,
+		`[]`,
+		`{"ID":1.5}`,
+		`{"ID":"1"}`,
+		`{"Unknown":1}`,
+		`{"ID":1,}`,
+		`{"ID":1} x`,
+		`{"Primary":{"Payload":"not base64!"}}`,
+		`{"Primary":{"Name":"unterminated}}`,
+		`{"Matrix":{}}`,
+	}
+	for _, js := range bad {
+		if _, err := FromJSON([]byte(js), s); err == nil {
+			t.Errorf("Expected error for %q", js)
+		}
+	}
+
+	var data []byte
+	if err := binary.Encode(&nested{Secondary: &basic{}}, &data); err != nil {
+		t.Fatalf("Encode failed: %v", err)
+	}
+	if _, err := ToJSON(data[:len(data)-1], s); err != binary.ErrTruncated {
+		t.Errorf("Expected ErrTruncated for truncated input, got %v", err)
+	}
+	if _, err := ToJSON(append(data, 0), s); err == nil {
+		t.Error("Expected error for trailing bytes")
+	}
+	if _, err := ToJSON(data, nil); err == nil {
+		t.Error("Expected error for nil schema")
+	}
+	if _, err := ToJSON([]byte{0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0xff}, nestedSchema()); err == nil {
+		t.Error("Expected error for invalid UTF-8")
+	}
+}
@@ -0,0 +1,405 @@
+// Command binarydump prints an annotated hexdump of data encoded with
+// github.com/tinywasm/binary.
+//
+// Usage:
+//
+//	binarydump [-schema file] [-message] [-frames] [file]
+//
+// It reads file, or stdin when no file is given. Without a schema it prints a
+// plain hexdump. With -schema, whose file holds a Schema encoded with
+// binary.Encode(binary.SchemaOf(v), ...), every field is printed on its own
+// line with its offset, raw bytes, name, kind and decoded value.
+//
+// -message decodes the input as a binary.Message envelope; the payload is
+// annotated with -schema when one is given. -frames walks a stream of
+// length-prefixed frames and dumps each frame body in the selected mode.
+package main
+
+import (
+	"bufio"
+	ebin "encoding/binary"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/tinywasm/binary"
+)
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("binarydump", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	schemaFile := fs.String("schema", "", "file holding an encoded binary.Schema of the value")
+	message := fs.Bool("message", false, "decode the input as a binary.Message envelope")
+	frames := fs.Bool("frames", false, "walk a stream of length-prefixed frames")
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "usage: binarydump [-schema file] [-message] [-frames] [file]")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() > 1 {
+		fs.Usage()
+		return 2
+	}
+
+	var schema *binary.Schema
+	if *schemaFile != "" {
+		raw, err := os.ReadFile(*schemaFile)
+		if err != nil {
+			fmt.Fprintln(stderr, "binarydump:", err)
+			return 1
+		}
+		schema = &binary.Schema{}
+		if err := binary.Decode(raw, schema); err != nil {
+			fmt.Fprintln(stderr, "binarydump: reading schema:", err)
+			return 1
+		}
+	}
+
+	in := stdin
+	if fs.NArg() == 1 {
+		f, err := os.Open(fs.Arg(0))
+		if err != nil {
+			fmt.Fprintln(stderr, "binarydump:", err)
+			return 1
+		}
+		defer f.Close()
+		in = f
+	}
+	data, err := io.ReadAll(in)
+	if err != nil {
+		fmt.Fprintln(stderr, "binarydump:", err)
+		return 1
+	}
+
+	out := bufio.NewWriter(stdout)
+	d := &dumper{out: out, schema: schema, message: *message}
+	if *frames {
+		err = d.frames(data)
+	} else {
+		err = d.body(data, 0)
+	}
+	out.Flush()
+	if err != nil {
+		fmt.Fprintln(stderr, "binarydump:", err)
+		return 1
+	}
+	return 0
+}
+
+// messageSchema is the layout of binary.Message; a set Fingerprint records
+// the kind of the optional value.
+var messageSchema = binary.SchemaOf(&binary.Message{Fingerprint: 1})
+
+// dumper prints one input in the selected mode.
+type dumper struct {
+	out     *bufio.Writer
+	schema  *binary.Schema
+	message bool
+}
+
+// body dumps one encoded value starting at absolute offset base.
+func (d *dumper) body(data []byte, base int) error {
+	switch {
+	case d.message:
+		return d.annotate(data, base, messageSchema, d.schema)
+	case d.schema != nil:
+		return d.annotate(data, base, d.schema, nil)
+	}
+	hexdump(d.out, data, base)
+	return nil
+}
+
+func (d *dumper) frames(data []byte) error {
+	for n, pos := 0, 0; pos < len(data); n++ {
+		size, w := ebin.Uvarint(data[pos:])
+		if w <= 0 {
+			return fmt.Errorf("frame %d: bad length prefix at offset %#x", n, pos)
+		}
+		start := pos + w
+		if size > uint64(len(data)-start) {
+			return fmt.Errorf("frame %d: length %d at offset %#x runs past the end of input", n, size, pos)
+		}
+		fmt.Fprintf(d.out, "%08x  %-24s  frame %d, %d bytes\n", pos, hexBytes(data[pos:start], nil), n, size)
+		end := start + int(size)
+		if err := d.body(data[start:end], start); err != nil {
+			return err
+		}
+		pos = end
+	}
+	return nil
+}
+
+// annotate walks data with schema s. payload, when set, is the schema of the
+// Message Payload field.
+func (d *dumper) annotate(data []byte, base int, s, payload *binary.Schema) error {
+	w := &walker{dumper: d, data: data, base: base, payload: payload}
+	w.object(s, "", "")
+	if w.err == nil && w.pos < len(data) {
+		w.line(w.pos, len(data), "", "trailing", strconv.Itoa(len(data)-w.pos)+" unread bytes")
+	}
+	return w.err
+}
+
+// walker annotates the positional format field by field.
+type walker struct {
+	*dumper
+	data    []byte
+	pos     int
+	base    int
+	payload *binary.Schema
+	err     error
+}
+
+func (w *walker) fail(format string, args ...any) {
+	if w.err == nil {
+		w.err = fmt.Errorf("offset %#x: "+format, append([]any{w.base + w.pos}, args...)...)
+	}
+}
+
+func (w *walker) line(start, end int, indent, label, value string) {
+	w.bodyLine(start, end, end, indent, label, value)
+}
+
+// bodyLine is line for a string or bytes value whose body starts at body.
+func (w *walker) bodyLine(start, body, end int, indent, label, value string) {
+	fmt.Fprintf(w.out, "%08x  %-24s  %s%s = %s\n", w.base+start, hexBytes(w.data[start:body], w.data[body:end]), indent, label, value)
+}
+
+func (w *walker) uvarint() (uint64, bool) {
+	v, n := ebin.Uvarint(w.data[w.pos:])
+	if n <= 0 {
+		if n == 0 {
+			w.fail("truncated varint")
+		} else {
+			w.fail("varint overflows 64 bits")
+		}
+		return 0, false
+	}
+	w.pos += n
+	return v, true
+}
+
+func (w *walker) take(n uint64) ([]byte, bool) {
+	if n > uint64(len(w.data)-w.pos) {
+		w.fail("%d bytes needed, %d left", n, len(w.data)-w.pos)
+		return nil, false
+	}
+	b := w.data[w.pos : w.pos+int(n)]
+	w.pos += int(n)
+	return b, true
+}
+
+func (w *walker) object(s *binary.Schema, path, indent string) {
+	for _, f := range s.Fields {
+		if w.err != nil {
+			return
+		}
+		name := path + f.Name
+		switch f.Kind {
+		case binary.KindObject:
+			w.nested(name, indent, f.Schema)
+		case binary.KindArray:
+			start := w.pos
+			n, ok := w.uvarint()
+			if !ok {
+				return
+			}
+			w.line(start, w.pos, indent, name+" array", strconv.FormatUint(n, 10)+" elements")
+			if n > 0 && f.Elem == binary.KindInvalid {
+				w.fail("%s: element kind unknown in schema", name)
+				return
+			}
+			for i := uint64(0); i < n && w.err == nil; i++ {
+				elem := name + "[" + strconv.FormatUint(i, 10) + "]"
+				if f.Elem == binary.KindObject {
+					w.nested(elem, indent+"  ", f.Schema)
+				} else {
+					w.scalar(elem, indent+"  ", f.Elem)
+				}
+			}
+		case binary.KindMap:
+			start := w.pos
+			n, ok := w.uvarint()
+			if !ok {
+				return
+			}
+			w.line(start, w.pos, indent, name+" map", strconv.FormatUint(n, 10)+" entries")
+			if n > 0 && (f.Key == binary.KindInvalid || f.Elem == binary.KindInvalid) {
+				w.fail("%s: key or value kind unknown in schema", name)
+				return
+			}
+			for i := uint64(0); i < n && w.err == nil; i++ {
+				elem := name + "[" + strconv.FormatUint(i, 10) + "]"
+				w.scalar(elem+".key", indent+"  ", f.Key)
+				if f.Elem == binary.KindObject {
+					w.nested(elem+".value", indent+"  ", f.Schema)
+				} else {
+					w.scalar(elem+".value", indent+"  ", f.Elem)
+				}
+			}
+		case binary.KindOptional:
+			start := w.pos
+			flag, ok := w.take(1)
+			if !ok {
+				return
+			}
+			switch flag[0] {
+			case 0:
+				w.line(start, w.pos, indent, name+" optional", "absent")
+			case 1:
+				w.line(start, w.pos, indent, name+" optional", "present")
+				w.scalar(name, indent+"  ", f.Elem)
+			default:
+				w.fail("%s: invalid presence byte %#x", name, flag[0])
+			}
+		default:
+			w.scalar(name, indent, f.Kind)
+		}
+	}
+}
+
+func (w *walker) nested(name, indent string, s *binary.Schema) {
+	start := w.pos
+	flag, ok := w.take(1)
+	if !ok {
+		return
+	}
+	switch flag[0] {
+	case 0:
+		w.line(start, w.pos, indent, name+" object", "nil")
+	case 1:
+		w.line(start, w.pos, indent, name+" object", "present")
+		if s == nil {
+			w.fail("%s: nested schema unknown", name)
+			return
+		}
+		w.object(s, name+".", indent+"  ")
+	default:
+		w.fail("%s: invalid presence byte %#x", name, flag[0])
+	}
+}
+
+func (w *walker) scalar(name, indent string, kind binary.Kind) {
+	start := w.pos
+	label := name + " " + kind.String()
+	switch kind {
+	case binary.KindString, binary.KindRaw:
+		n, ok := w.uvarint()
+		if !ok {
+			return
+		}
+		body := w.pos
+		if b, ok := w.take(n); ok {
+			w.bodyLine(start, body, w.pos, indent, label, strconv.Quote(string(b)))
+		}
+	case binary.KindBytes:
+		n, ok := w.uvarint()
+		if !ok {
+			return
+		}
+		body := w.pos
+		b, ok := w.take(n)
+		if !ok {
+			return
+		}
+		w.bodyLine(start, body, w.pos, indent, label, strconv.Itoa(len(b))+" bytes")
+		if w.payload != nil && name == "Payload" {
+			sub := &walker{dumper: w.dumper, data: b, base: w.base + w.pos - len(b)}
+			sub.object(w.payload, "", indent+"  ")
+			if sub.err != nil {
+				w.err = sub.err
+			}
+		}
+	case binary.KindInt:
+		if u, ok := w.uvarint(); ok {
+			v := int64(u>>1) ^ -int64(u&1)
+			w.line(start, w.pos, indent, label, strconv.FormatInt(v, 10))
+		}
+	case binary.KindUint:
+		if v, ok := w.uvarint(); ok {
+			w.line(start, w.pos, indent, label, strconv.FormatUint(v, 10))
+		}
+	case binary.KindFloat:
+		if b, ok := w.take(8); ok {
+			v := math.Float64frombits(ebin.LittleEndian.Uint64(b))
+			w.line(start, w.pos, indent, label, strconv.FormatFloat(v, 'g', -1, 64))
+		}
+	case binary.KindFloat32:
+		if b, ok := w.take(4); ok {
+			v := math.Float32frombits(ebin.LittleEndian.Uint32(b))
+			w.line(start, w.pos, indent, label, strconv.FormatFloat(float64(v), 'g', -1, 32))
+		}
+	case binary.KindFixed32:
+		if b, ok := w.take(4); ok {
+			w.line(start, w.pos, indent, label, strconv.FormatUint(uint64(ebin.LittleEndian.Uint32(b)), 10))
+		}
+	case binary.KindFixed64:
+		if b, ok := w.take(8); ok {
+			w.line(start, w.pos, indent, label, strconv.FormatUint(ebin.LittleEndian.Uint64(b), 10))
+		}
+	case binary.KindBool, binary.KindNull:
+		b, ok := w.take(1)
+		if !ok {
+			return
+		}
+		switch {
+		case kind == binary.KindNull && b[0] == 0:
+			w.line(start, w.pos, indent, label, "null")
+		case kind == binary.KindBool && b[0] <= 1:
+			w.line(start, w.pos, indent, label, strconv.FormatBool(b[0] == 1))
+		default:
+			w.fail("%s: invalid %s byte %#x", name, kind, b[0])
+		}
+	default:
+		w.fail("%s: unsupported kind %s", name, kind)
+	}
+}
+
+// hexBytes formats raw bytes for the hex column. head, a varint or
+// fixed-width value, is shown in full; the middle of a long string or bytes
+// body is elided so values stay on one line.
+func hexBytes(head, body []byte) string {
+	const max = 6
+	b := append(head[:len(head):len(head)], body...)
+	if len(body) <= max {
+		return spacedHex(b)
+	}
+	return spacedHex(b[:len(head)+max-2]) + " .. " + spacedHex(b[len(b)-1:])
+}
+
+func spacedHex(b []byte) string {
+	var sb strings.Builder
+	for i, c := range b {
+		if i > 0 {
+			sb.WriteByte(' ')
+		}
+		sb.WriteString(hex.EncodeToString([]byte{c}))
+	}
+	return sb.String()
+}
+
+// hexdump prints data 16 bytes per line with an ASCII column.
+func hexdump(out io.Writer, data []byte, base int) {
+	for i := 0; i < len(data); i += 16 {
+		row := data[i:min(i+16, len(data))]
+		ascii := make([]byte, len(row))
+		for j, c := range row {
+			if c < 0x20 || c > 0x7e {
+				c = '.'
+			}
+			ascii[j] = c
+		}
+		fmt.Fprintf(out, "%08x  %-47s  |%s|\n", base+i, spacedHex(row), ascii)
+	}
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"bytes"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/tinywasm/binary"
+	"github.com/tinywasm/model"
+)
+
+type point struct {
+	Name string
+	X    int64
+	Tags []string
+	Next *point
+}
+
+func (p *point) IsNil() bool { return p == nil }
+
+func (p *point) EncodeFields(w model.FieldWriter) {
+	w.String("Name", p.Name)
+	w.Int("X", p.X)
+	aw := w.Array("Tags", len(p.Tags))
+	for _, t := range p.Tags {
+		aw.String(t)
+	}
+	w.Object("Next", p.Next)
+}
+
+func writeSchema(t *testing.T) string {
+	t.Helper()
+	var raw []byte
+	if err := binary.Encode(binary.SchemaOf(&point{Tags: []string{""}, Next: &point{Tags: []string{""}}}), &raw); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "point.schema")
+	if err := os.WriteFile(path, raw, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func dump(t *testing.T, input []byte, args ...string) (string, int) {
+	t.Helper()
+	var stdout, stderr bytes.Buffer
+	code := run(args, bytes.NewReader(input), &stdout, &stderr)
+	return stdout.String() + stderr.String(), code
+}
+
+func TestDumpSchema(t *testing.T) {
+	var data []byte
+	if err := binary.Encode(&point{Name: "a", X: -3, Tags: []string{"t"}}, &data); err != nil {
+		t.Fatal(err)
+	}
+	out, code := dump(t, data, "-schema", writeSchema(t))
+	if code != 0 {
+		t.Fatalf("Expected exit 0, got %d:\n%s", code, out)
+	}
+	want := "" +
+		"00000000  01 61                     Name string = \"a\"\n" +
+		"00000002  05                        X int = -3\n" +
+		"00000003  01                        Tags array = 1 elements\n" +
+		"00000004  01 74                       Tags[0] string = \"t\"\n" +
+		"00000006  00                        Next object = nil\n"
+	if out != want {
+		t.Errorf("Expected\n%s\ngot\n%s", want, out)
+	}
+
+	// Corrupt input reports the offset of the failure.
+	out, code = dump(t, data[:3], "-schema", writeSchema(t))
+	if code != 1 || !strings.Contains(out, "offset 0x3: truncated varint") {
+		t.Errorf("Expected truncation error, got %d:\n%s", code, out)
+	}
+}
+
+func TestDumpMessageFrames(t *testing.T) {
+	var payload []byte
+	if err := binary.Encode(&point{Name: "p"}, &payload); err != nil {
+		t.Fatal(err)
+	}
+	var stream []byte
+	for _, topic := range []string{"a.b", "c"} {
+		var err error
+		stream, err = binary.AppendFrame(stream, &binary.Message{Topic: topic, ID: 7, Payload: payload})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	out, code := dump(t, stream, "-frames", "-message", "-schema", writeSchema(t))
+	if code != 0 {
+		t.Fatalf("Expected exit 0, got %d:\n%s", code, out)
+	}
+	for _, want := range []string{
+		"frame 0, 13 bytes",
+		"Topic string = \"a.b\"",
+		"ID int = 7",
+		"Payload bytes = 5 bytes",
+		"  Name string = \"p\"",
+		"Fingerprint optional = absent",
+		"frame 1, 11 bytes",
+		"Topic string = \"c\"",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("Expected output to contain %q:\n%s", want, out)
+		}
+	}
+}
+
+func TestDumpWideValues(t *testing.T) {
+	schema := writeSchema(t)
+	for _, tc := range []struct {
+		x    int64
+		want string
+	}{
+		{math.MaxInt64, "fe ff ff ff ff ff ff ff ff 01  X int = 9223372036854775807\n"},
+		{math.MinInt64, "ff ff ff ff ff ff ff ff ff 01  X int = -9223372036854775808\n"},
+	} {
+		var data []byte
+		if err := binary.Encode(&point{Name: "long string", X: tc.x}, &data); err != nil {
+			t.Fatal(err)
+		}
+		out, code := dump(t, data, "-schema", schema)
+		if code != 0 {
+			t.Fatalf("Expected exit 0, got %d:\n%s", code, out)
+		}
+		// Varints are shown in full; only long string bodies are elided.
+		if !strings.Contains(out, "0000000c  "+tc.want) {
+			t.Errorf("Expected %q in\n%s", tc.want, out)
+		}
+		if want := "00000000  0b 6c 6f 6e 67 .. 67      Name string"; !strings.HasPrefix(out, want) {
+			t.Errorf("Expected %q in\n%s", want, out)
+		}
+	}
+}
+
+func TestDumpHex(t *testing.T) {
+	out, code := dump(t, []byte("hello, binary world\x00"))
+	want := "" +
+		"00000000  68 65 6c 6c 6f 2c 20 62 69 6e 61 72 79 20 77 6f  |hello, binary wo|\n" +
+		"00000010  72 6c 64 00                                      |rld.|\n"
+	if code != 0 || out != want {
+		t.Errorf("Expected\n%s\ngot %d\n%s", want, code, out)
+	}
+}
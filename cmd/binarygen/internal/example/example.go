@@ -0,0 +1,53 @@
+// Package example holds structs covering every field type binarygen
+// supports. example_binary.go is generated from this file.
+package example
+
+import "time"
+
+//go:generate go run github.com/tinywasm/binary/cmd/binarygen
+
+// Celsius is a named numeric type.
+type Celsius float64
+
+// Role is a named unsigned type.
+type Role uint8
+
+// Labels is a named slice type.
+type Labels []string
+
+// Blob is a named byte slice.
+type Blob []byte
+
+// Point is a small nested struct.
+type Point struct {
+	X, Y int32
+}
+
+// Record exercises nesting, pointers, slices, fixed arrays and times.
+type Record struct {
+	ID       uint64
+	Name     string `json:"name"`
+	Temp     Celsius
+	Role     Role
+	Active   bool
+	Ratio    float32
+	Data     []byte
+	Hash     [4]byte
+	Blob     Blob
+	Labels   Labels
+	Scores   []int16
+	Matrix   [3]int
+	Origin   Point
+	Target   *Point
+	Path     []Point
+	Stops    []*Point
+	Corners  [2]Point
+	When     time.Time
+	Until    *time.Time
+	Log      []time.Time
+	Window   [2]time.Time
+	Renamed  string `binary:"alias"`
+	Password string `binary:"-"`
+	Internal string `json:"-"`
+	private  int
+}
@@ -0,0 +1,211 @@
+// Code generated by binarygen. DO NOT EDIT.
+
+package example
+
+import (
+	"time"
+
+	"github.com/tinywasm/binary"
+	"github.com/tinywasm/model"
+)
+
+// EncodeFields implements model.Encodable
+func (p *Point) EncodeFields(w model.FieldWriter) {
+	w.Int("X", int64(p.X))
+	w.Int("Y", int64(p.Y))
+}
+
+// DecodeFields implements model.Decodable
+func (p *Point) DecodeFields(r model.FieldReader) {
+	if v, ok := r.Int("X"); ok {
+		p.X = int32(v)
+	}
+	if v, ok := r.Int("Y"); ok {
+		p.Y = int32(v)
+	}
+}
+
+// IsNil implements model.Encodable and model.Decodable
+func (p *Point) IsNil() bool {
+	return p == nil
+}
+
+// EncodeFields implements model.Encodable
+func (x *Record) EncodeFields(w model.FieldWriter) {
+	w.Int("ID", int64(x.ID))
+	w.String("Name", x.Name)
+	w.Float("Temp", float64(x.Temp))
+	w.Int("Role", int64(x.Role))
+	w.Bool("Active", x.Active)
+	w.Float("Ratio", float64(x.Ratio))
+	w.Bytes("Data", x.Data)
+	w.Bytes("Hash", x.Hash[:])
+	w.Bytes("Blob", x.Blob)
+	aw := w.Array("Labels", len(x.Labels))
+	for i := range x.Labels {
+		aw.String(x.Labels[i])
+	}
+	aw2 := w.Array("Scores", len(x.Scores))
+	for i := range x.Scores {
+		aw2.Int(int64(x.Scores[i]))
+	}
+	aw3 := w.Array("Matrix", len(x.Matrix))
+	for i := range x.Matrix {
+		aw3.Int(int64(x.Matrix[i]))
+	}
+	w.Object("Origin", &x.Origin)
+	w.Object("Target", x.Target)
+	aw4 := w.Array("Path", len(x.Path))
+	for i := range x.Path {
+		aw4.Object(&x.Path[i])
+	}
+	aw5 := w.Array("Stops", len(x.Stops))
+	for i := range x.Stops {
+		aw5.Object(x.Stops[i])
+	}
+	aw6 := w.Array("Corners", len(x.Corners))
+	for i := range x.Corners {
+		aw6.Object(&x.Corners[i])
+	}
+	w.Object("When", binary.TimeOf(&x.When))
+	w.Object("Until", binary.TimeOf(x.Until))
+	aw7 := w.Array("Log", len(x.Log))
+	for i := range x.Log {
+		aw7.Object(binary.TimeOf(&x.Log[i]))
+	}
+	aw8 := w.Array("Window", len(x.Window))
+	for i := range x.Window {
+		aw8.Object(binary.TimeOf(&x.Window[i]))
+	}
+	w.String("alias", x.Renamed)
+}
+
+// DecodeFields implements model.Decodable
+func (x *Record) DecodeFields(r model.FieldReader) {
+	if v, ok := r.Int("ID"); ok {
+		x.ID = uint64(v)
+	}
+	if v, ok := r.String("Name"); ok {
+		x.Name = v
+	}
+	if v, ok := r.Float("Temp"); ok {
+		x.Temp = Celsius(v)
+	}
+	if v, ok := r.Int("Role"); ok {
+		x.Role = Role(v)
+	}
+	if v, ok := r.Bool("Active"); ok {
+		x.Active = v
+	}
+	if v, ok := r.Float("Ratio"); ok {
+		x.Ratio = float32(v)
+	}
+	if v, ok := r.Bytes("Data"); ok {
+		x.Data = v
+	}
+	if v, ok := r.Bytes("Hash"); ok {
+		x.Hash = [4]byte{}
+		copy(x.Hash[:], v)
+	}
+	if v, ok := r.Bytes("Blob"); ok {
+		x.Blob = v
+	}
+	if ar, ok := r.Array("Labels"); ok {
+		if ar.Len() > 0 {
+			x.Labels = make(Labels, ar.Len())
+			for i := range x.Labels {
+				x.Labels[i] = ar.String(i)
+			}
+		} else {
+			x.Labels = nil
+		}
+	}
+	if ar, ok := r.Array("Scores"); ok {
+		if ar.Len() > 0 {
+			x.Scores = make([]int16, ar.Len())
+			for i := range x.Scores {
+				x.Scores[i] = int16(ar.Int(i))
+			}
+		} else {
+			x.Scores = nil
+		}
+	}
+	if ar, ok := r.Array("Matrix"); ok {
+		for i := 0; i < ar.Len(); i++ {
+			if i < len(x.Matrix) {
+				x.Matrix[i] = int(ar.Int(i))
+			} else {
+				ar.Int(i)
+			}
+		}
+	}
+	r.Object("Origin", &x.Origin)
+	x.Target = &Point{}
+	if !r.Object("Target", x.Target) {
+		x.Target = nil
+	}
+	if ar, ok := r.Array("Path"); ok {
+		if ar.Len() > 0 {
+			x.Path = make([]Point, ar.Len())
+			for i := range x.Path {
+				ar.Object(i, &x.Path[i])
+			}
+		} else {
+			x.Path = nil
+		}
+	}
+	if ar, ok := r.Array("Stops"); ok {
+		if ar.Len() > 0 {
+			x.Stops = make([]*Point, ar.Len())
+			for i := range x.Stops {
+				x.Stops[i] = &Point{}
+				if !ar.Object(i, x.Stops[i]) {
+					x.Stops[i] = nil
+				}
+			}
+		} else {
+			x.Stops = nil
+		}
+	}
+	if ar, ok := r.Array("Corners"); ok {
+		for i := 0; i < ar.Len(); i++ {
+			if i < len(x.Corners) {
+				ar.Object(i, &x.Corners[i])
+			} else {
+				ar.Object(i, &Point{})
+			}
+		}
+	}
+	r.Object("When", binary.TimeOf(&x.When))
+	x.Until = &time.Time{}
+	if !r.Object("Until", binary.TimeOf(x.Until)) {
+		x.Until = nil
+	}
+	if ar, ok := r.Array("Log"); ok {
+		if ar.Len() > 0 {
+			x.Log = make([]time.Time, ar.Len())
+			for i := range x.Log {
+				ar.Object(i, binary.TimeOf(&x.Log[i]))
+			}
+		} else {
+			x.Log = nil
+		}
+	}
+	if ar, ok := r.Array("Window"); ok {
+		for i := 0; i < ar.Len(); i++ {
+			if i < len(x.Window) {
+				ar.Object(i, binary.TimeOf(&x.Window[i]))
+			} else {
+				ar.Object(i, binary.TimeOf(&time.Time{}))
+			}
+		}
+	}
+	if v, ok := r.String("alias"); ok {
+		x.Renamed = v
+	}
+}
+
+// IsNil implements model.Encodable and model.Decodable
+func (x *Record) IsNil() bool {
+	return x == nil
+}
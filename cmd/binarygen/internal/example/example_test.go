@@ -0,0 +1,131 @@
+package example
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/tinywasm/binary"
+	"github.com/tinywasm/model"
+)
+
+func sampleRecord() *Record {
+	return &Record{
+		ID:       1<<64 - 1,
+		Name:     "Alice",
+		Temp:     -3.5,
+		Role:     200,
+		Active:   true,
+		Ratio:    0.25,
+		Data:     []byte{1, 2},
+		Hash:     [4]byte{9, 8, 7, 6},
+		Blob:     Blob("blob"),
+		Labels:   Labels{"a", "b"},
+		Scores:   []int16{-1, 300},
+		Matrix:   [3]int{1, 2, 3},
+		Origin:   Point{X: 1, Y: -1},
+		Target:   &Point{X: 5},
+		Path:     []Point{{X: 1}, {Y: 2}},
+		Stops:    []*Point{{X: 3}, nil},
+		Corners:  [2]Point{{X: 7}, {Y: 8}},
+		When:     time.Date(2024, 2, 29, 12, 30, 0, 5, time.UTC),
+		Until:    &time.Time{},
+		Log:      []time.Time{time.Unix(-1, 0).UTC(), {}},
+		Window:   [2]time.Time{time.Unix(1e10, 7).UTC()},
+		Renamed:  "alias",
+		Password: "secret",
+		Internal: "internal",
+		private:  42,
+	}
+}
+
+func TestRecordRoundTrip(t *testing.T) {
+	in := sampleRecord()
+	var data []byte
+	if err := binary.Encode(in, &data); err != nil {
+		t.Fatalf("Encode failed: %v", err)
+	}
+	out := &Record{}
+	if err := binary.Decode(data, out); err != nil {
+		t.Fatalf("Decode failed: %v", err)
+	}
+
+	want := *in
+	want.Password, want.Internal, want.private = "", "", 0
+	if !reflect.DeepEqual(&want, out) {
+		t.Errorf("Expected %+v, got %+v", &want, out)
+	}
+
+	names := map[string]bool{}
+	for _, f := range binary.SchemaOf(in).Fields {
+		names[f.Name] = true
+	}
+	if !names["alias"] || names["Renamed"] || names["Password"] || names["Internal"] || names["private"] {
+		t.Errorf("Unexpected field names: %v", names)
+	}
+}
+
+// The generated methods and Reflect must agree on every field type, in both
+// directions.
+func TestRecordMatchesReflect(t *testing.T) {
+	for name, in := range map[string]*Record{"full": sampleRecord(), "zero": {}} {
+		gen, refl := binary.SchemaOf(in), binary.SchemaOf(binary.Reflect(in))
+		if len(gen.Fields) != len(refl.Fields) {
+			t.Fatalf("%s: expected %d fields, got %d", name, len(gen.Fields), len(refl.Fields))
+		}
+		for i, f := range gen.Fields {
+			if !reflect.DeepEqual(f, refl.Fields[i]) {
+				t.Errorf("%s: expected field %+v, got %+v", name, f, refl.Fields[i])
+			}
+			var generated, reflected []byte
+			if err := binary.EncodeMask(in, binary.Mask{f.Name}, &generated); err != nil {
+				t.Fatal(err)
+			}
+			if err := binary.EncodeMask(binary.Reflect(in), binary.Mask{f.Name}, &reflected); err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(generated, reflected) {
+				t.Errorf("%s: %s: expected %x, got %x", name, f.Name, generated, reflected)
+			}
+		}
+
+		var data []byte
+		if err := binary.Encode(in, &data); err != nil {
+			t.Fatal(err)
+		}
+		generated, reflected := &Record{}, &Record{}
+		if err := binary.Decode(data, generated); err != nil {
+			t.Fatal(err)
+		}
+		if err := binary.Decode(data, binary.Reflect(reflected)); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(generated, reflected) {
+			t.Errorf("%s: expected %+v, got %+v", name, generated, reflected)
+		}
+	}
+}
+
+// shortHash writes a Hash shorter than Record's.
+type shortHash struct{}
+
+func (shortHash) IsNil() bool { return false }
+
+func (shortHash) EncodeFields(w model.FieldWriter) {
+	w.Bytes("Hash", []byte{1, 2})
+}
+
+func TestRecordShortArray(t *testing.T) {
+	var data []byte
+	if err := binary.EncodeTagged(shortHash{}, &data); err != nil {
+		t.Fatal(err)
+	}
+	out := sampleRecord()
+	if err := binary.DecodeTagged(data, out); err != nil {
+		t.Fatal(err)
+	}
+	if out.Hash != [4]byte{1, 2} {
+		t.Errorf("Expected the rest of Hash to be zeroed, got %v", out.Hash)
+	}
+}
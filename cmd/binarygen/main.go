@@ -0,0 +1,579 @@
+// Command binarygen writes the model.Encodable and model.Decodable methods
+// (EncodeFields, DecodeFields and IsNil) for plain Go structs, so they can be
+// encoded with github.com/tinywasm/binary without reflection.
+//
+// Usage:
+//
+//	binarygen [-type T1,T2] [-output file] [file.go ... | dir]
+//
+// It is meant for go:generate:
+//
+//	//go:generate go run github.com/tinywasm/binary/cmd/binarygen
+//
+// Without -type, methods are generated for every struct declared in the
+// given files, or in $GOFILE under go:generate. The output defaults to
+// <file>_binary.go next to the first input.
+//
+// Fields are written in declaration order. Unexported fields and fields
+// tagged `binary:"-"` or `json:"-"` are skipped; `binary:"name"` changes the
+// field name used on the wire. Supported field types are strings, bools,
+// integers, unsigned integers and floats, []byte, structs of the same
+// package and time.Time (as values or pointers), slices and fixed arrays of
+// those, and named types whose underlying type is one of them. Unsigned
+// integers are written with Int, like the hand-written codecs in this
+// repository, and time.Time like binary.WriteTime.
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+const generatedHeader = "// Code generated by binarygen. DO NOT EDIT."
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stderr))
+}
+
+func run(args []string, stderr io.Writer) int {
+	fs := flag.NewFlagSet("binarygen", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	typeList := fs.String("type", "", "comma-separated struct names; default all structs in the input files")
+	output := fs.String("output", "", "output file; default <first input>_binary.go")
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "usage: binarygen [-type T1,T2] [-output file] [file.go ... | dir]")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	inputs := fs.Args()
+	if len(inputs) == 0 {
+		if gofile := os.Getenv("GOFILE"); gofile != "" {
+			inputs = []string{gofile}
+		} else {
+			inputs = []string{"."}
+		}
+	}
+	var types []string
+	if *typeList != "" {
+		types = strings.Split(*typeList, ",")
+	}
+
+	src, out, err := generate(inputs, types, *output)
+	if err == nil {
+		err = os.WriteFile(out, src, 0o644)
+	}
+	if err != nil {
+		fmt.Fprintln(stderr, "binarygen:", err)
+		return 1
+	}
+	return 0
+}
+
+// generate parses the package holding inputs and returns the generated
+// source and the file it belongs in.
+func generate(inputs, types []string, output string) ([]byte, string, error) {
+	dir, files, err := inputFiles(inputs)
+	if err != nil {
+		return nil, "", err
+	}
+	if output == "" {
+		base := "binary"
+		if len(files) > 0 {
+			base = strings.TrimSuffix(filepath.Base(files[0]), ".go")
+		}
+		output = filepath.Join(dir, base+"_binary.go")
+	}
+
+	g := &generator{decls: map[string]ast.Expr{}}
+	fset := token.NewFileSet()
+	paths, err := filepath.Glob(filepath.Join(dir, "*.go"))
+	if err != nil {
+		return nil, "", err
+	}
+	selected := map[string]bool{}
+	for _, f := range files {
+		selected[filepath.Clean(f)] = true
+	}
+	var order []string // struct names declared in the selected files
+	for _, path := range paths {
+		if strings.HasSuffix(path, "_test.go") || filepath.Clean(path) == filepath.Clean(output) {
+			continue
+		}
+		f, err := parser.ParseFile(fset, path, nil, parser.ParseComments|parser.SkipObjectResolution)
+		if err != nil {
+			return nil, "", err
+		}
+		if isGenerated(f) {
+			continue
+		}
+		if g.pkg == "" {
+			g.pkg = f.Name.Name
+		}
+		for _, decl := range f.Decls {
+			gd, ok := decl.(*ast.GenDecl)
+			if !ok || gd.Tok != token.TYPE {
+				continue
+			}
+			for _, spec := range gd.Specs {
+				ts := spec.(*ast.TypeSpec)
+				if ts.TypeParams != nil {
+					continue
+				}
+				g.decls[ts.Name.Name] = ts.Type
+				if _, ok := ts.Type.(*ast.StructType); ok && selected[filepath.Clean(path)] {
+					order = append(order, ts.Name.Name)
+				}
+			}
+		}
+	}
+	if g.pkg == "" {
+		return nil, "", errors.New("no Go files in " + dir)
+	}
+
+	if len(types) == 0 {
+		types = order
+	}
+	if len(types) == 0 {
+		return nil, "", errors.New("no structs found")
+	}
+
+	for _, name := range types {
+		if err := g.structType(name); err != nil {
+			return nil, "", err
+		}
+	}
+	var head bytes.Buffer
+	fmt.Fprintf(&head, "%s\n\npackage %s\n\n", generatedHeader, g.pkg)
+	switch {
+	case g.usesTime:
+		head.WriteString("import (\n\"time\"\n\n\"github.com/tinywasm/binary\"\n\"github.com/tinywasm/model\"\n)\n")
+	case g.usesBinary:
+		head.WriteString("import (\n\"github.com/tinywasm/binary\"\n\"github.com/tinywasm/model\"\n)\n")
+	default:
+		head.WriteString("import \"github.com/tinywasm/model\"\n")
+	}
+	src, err := format.Source(append(head.Bytes(), g.buf.Bytes()...))
+	if err != nil {
+		return nil, "", fmt.Errorf("formatting output: %v", err)
+	}
+	return src, output, nil
+}
+
+// inputFiles returns the package directory and the files whose structs are
+// generated by default.
+func inputFiles(inputs []string) (dir string, files []string, err error) {
+	for _, in := range inputs {
+		info, err := os.Stat(in)
+		if err != nil {
+			return "", nil, err
+		}
+		d := in
+		if info.IsDir() {
+			matches, err := filepath.Glob(filepath.Join(in, "*.go"))
+			if err != nil {
+				return "", nil, err
+			}
+			sort.Strings(matches)
+			for _, m := range matches {
+				if !strings.HasSuffix(m, "_test.go") {
+					files = append(files, m)
+				}
+			}
+		} else {
+			d = filepath.Dir(in)
+			files = append(files, in)
+		}
+		if dir == "" {
+			dir = d
+		} else if filepath.Clean(dir) != filepath.Clean(d) {
+			return "", nil, errors.New("inputs must be in one package directory")
+		}
+	}
+	return dir, files, nil
+}
+
+func isGenerated(f *ast.File) bool {
+	for _, c := range f.Comments {
+		if c.Pos() >= f.Package {
+			break
+		}
+		for _, l := range c.List {
+			if l.Text == generatedHeader {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// kind is the wire representation of a Go type.
+type kind int
+
+const (
+	kString kind = iota
+	kInt         // signed and unsigned integers, written with Int
+	kFloat
+	kBool
+	kBytes  // []byte, or [N]byte when array is set
+	kStruct // struct value, written with Object; time.Time when time is set
+	kPtr    // pointer to struct
+	kSlice
+	kArray
+)
+
+// goType describes a field type in terms of its wire representation.
+type goType struct {
+	kind  kind
+	name  string  // Go spelling, used for conversions and allocation
+	array bool    // kBytes backed by a fixed array
+	time  bool    // kStruct holding a time.Time, written through binary.TimeOf
+	elem  *goType // element of kSlice and kArray, target of kPtr
+}
+
+type generator struct {
+	pkg   string
+	decls map[string]ast.Expr // type declarations of the package
+	buf   bytes.Buffer
+
+	usesBinary bool // the output calls binary.TimeOf
+	usesTime   bool // the output spells a time.Time type
+}
+
+var basicKinds = map[string]kind{
+	"string": kString, "bool": kBool, "float32": kFloat, "float64": kFloat,
+	"int": kInt, "int8": kInt, "int16": kInt, "int32": kInt, "int64": kInt, "rune": kInt,
+	"uint": kInt, "uint8": kInt, "uint16": kInt, "uint32": kInt, "uint64": kInt, "uintptr": kInt, "byte": kInt,
+}
+
+// resolve describes the type expression e.
+func (g *generator) resolve(e ast.Expr) (*goType, error) {
+	switch t := e.(type) {
+	case *ast.Ident:
+		if k, ok := basicKinds[t.Name]; ok {
+			return &goType{kind: k, name: t.Name}, nil
+		}
+		decl, ok := g.decls[t.Name]
+		if !ok {
+			return nil, fmt.Errorf("unknown type %s", t.Name)
+		}
+		if _, ok := decl.(*ast.StructType); ok {
+			return &goType{kind: kStruct, name: t.Name}, nil
+		}
+		under, err := g.resolve(decl)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", t.Name, err)
+		}
+		named := *under
+		named.name = t.Name
+		return &named, nil
+	case *ast.SelectorExpr:
+		if pkg, ok := t.X.(*ast.Ident); ok && pkg.Name == "time" && t.Sel.Name == "Time" {
+			return &goType{kind: kStruct, name: "time.Time", time: true}, nil
+		}
+	case *ast.StarExpr:
+		elem, err := g.resolve(t.X)
+		if err != nil {
+			return nil, err
+		}
+		if elem.kind != kStruct {
+			return nil, fmt.Errorf("pointer to non-struct type %s", exprString(t.X))
+		}
+		return &goType{kind: kPtr, name: "*" + elem.name, elem: elem}, nil
+	case *ast.ArrayType:
+		elem, err := g.resolve(t.Elt)
+		if err != nil {
+			return nil, err
+		}
+		if elem.name == "byte" || elem.name == "uint8" {
+			return &goType{kind: kBytes, name: exprString(t), array: t.Len != nil}, nil
+		}
+		switch elem.kind {
+		case kSlice, kArray, kBytes:
+			return nil, fmt.Errorf("nested array type %s", exprString(t))
+		}
+		if t.Len == nil {
+			return &goType{kind: kSlice, name: exprString(t), elem: elem}, nil
+		}
+		return &goType{kind: kArray, name: exprString(t), elem: elem}, nil
+	}
+	return nil, fmt.Errorf("unsupported type %s", exprString(e))
+}
+
+func exprString(e ast.Expr) string {
+	var b bytes.Buffer
+	format.Node(&b, token.NewFileSet(), e)
+	return b.String()
+}
+
+// field is one encoded struct field.
+type field struct {
+	goName string // Go field name
+	wire   string // name passed to the writer and reader
+	t      *goType
+}
+
+func (g *generator) fields(name string) ([]field, error) {
+	decl, ok := g.decls[name]
+	if !ok {
+		return nil, fmt.Errorf("type %s not found", name)
+	}
+	st, ok := decl.(*ast.StructType)
+	if !ok {
+		return nil, fmt.Errorf("type %s is not a struct", name)
+	}
+	var out []field
+	for _, f := range st.Fields.List {
+		wire, skip := tagName(f.Tag)
+		if skip {
+			continue
+		}
+		names := f.Names
+		if len(names) == 0 { // embedded
+			id, ok := f.Type.(*ast.Ident)
+			if !ok {
+				if star, isPtr := f.Type.(*ast.StarExpr); isPtr {
+					id, ok = star.X.(*ast.Ident)
+				}
+			}
+			if !ok {
+				return nil, fmt.Errorf("%s: unsupported embedded field %s", name, exprString(f.Type))
+			}
+			names = []*ast.Ident{id}
+		}
+		for _, n := range names {
+			if !n.IsExported() {
+				continue
+			}
+			t, err := g.resolve(f.Type)
+			if err != nil {
+				return nil, fmt.Errorf("%s.%s: %v (tag it `binary:\"-\"` to skip it)", name, n.Name, err)
+			}
+			w := wire
+			if w == "" {
+				w = n.Name
+			}
+			out = append(out, field{goName: n.Name, wire: w, t: t})
+		}
+	}
+	return out, nil
+}
+
+// tagName returns the wire name from a `binary:"name"` tag and whether the
+// field is skipped by `binary:"-"` or `json:"-"`.
+func tagName(lit *ast.BasicLit) (string, bool) {
+	if lit == nil {
+		return "", false
+	}
+	tag := reflect.StructTag(strings.Trim(lit.Value, "`"))
+	b := tag.Get("binary")
+	if b == "-" || tag.Get("json") == "-" {
+		return "", true
+	}
+	name, _, _ := strings.Cut(b, ",")
+	return name, false
+}
+
+func receiver(typeName string) string {
+	r := strings.ToLower(typeName[:1])
+	if r == "i" || r == "r" || r == "w" || r == "v" || r == "_" {
+		return "x"
+	}
+	return r
+}
+
+func (g *generator) structType(name string) error {
+	fields, err := g.fields(name)
+	if err != nil {
+		return err
+	}
+	x := receiver(name)
+	p := func(format string, args ...any) { fmt.Fprintf(&g.buf, format+"\n", args...) }
+
+	p("\n// EncodeFields implements model.Encodable")
+	p("func (%s *%s) EncodeFields(w model.FieldWriter) {", x, name)
+	arrays := 0
+	for _, f := range fields {
+		v := x + "." + f.goName
+		switch f.t.kind {
+		case kSlice, kArray:
+			arrays++
+			aw := "aw"
+			if arrays > 1 {
+				aw = fmt.Sprintf("aw%d", arrays)
+			}
+			p("%s := w.Array(%q, len(%s))", aw, f.wire, v)
+			p("for i := range %s {", v)
+			p("%s.%s", aw, g.writeCall(f.t.elem, v+"[i]", ""))
+			p("}")
+		default:
+			p("w.%s", g.writeCall(f.t, v, f.wire))
+		}
+	}
+	p("}")
+
+	p("\n// DecodeFields implements model.Decodable")
+	p("func (%s *%s) DecodeFields(r model.FieldReader) {", x, name)
+	for _, f := range fields {
+		v := x + "." + f.goName
+		switch f.t.kind {
+		case kStruct:
+			p("r.Object(%q, %s)", f.wire, g.object(f.t, "&"+v))
+		case kPtr:
+			p("%s = &%s{}", v, g.spell(f.t.elem))
+			p("if !r.Object(%q, %s) {", f.wire, g.object(f.t.elem, v))
+			p("%s = nil", v)
+			p("}")
+		case kSlice:
+			p("if ar, ok := r.Array(%q); ok {", f.wire)
+			p("if ar.Len() > 0 {")
+			p("%s = make(%s, ar.Len())", v, g.spell(f.t))
+			p("for i := range %s {", v)
+			g.readElem(p, f.t.elem, v+"[i]")
+			p("}")
+			p("} else {")
+			p("%s = nil", v)
+			p("}")
+			p("}")
+		case kArray:
+			p("if ar, ok := r.Array(%q); ok {", f.wire)
+			p("for i := 0; i < ar.Len(); i++ {")
+			p("if i < len(%s) {", v)
+			g.readElem(p, f.t.elem, v+"[i]")
+			p("} else {")
+			g.discardElem(p, f.t.elem)
+			p("}")
+			p("}")
+			p("}")
+		default:
+			method, conv := scalar(f.t)
+			p("if v, ok := r.%s(%q); ok {", method, f.wire)
+			switch {
+			case f.t.kind == kBytes && f.t.array:
+				p("%s = %s{}", v, f.t.name) // a shorter value leaves no stale bytes
+				p("copy(%s[:], v)", v)
+			case conv:
+				p("%s = %s(v)", v, f.t.name)
+			default:
+				p("%s = v", v)
+			}
+			p("}")
+		}
+	}
+	p("}")
+
+	p("\n// IsNil implements model.Encodable and model.Decodable")
+	p("func (%s *%s) IsNil() bool {", x, name)
+	p("return %s == nil", x)
+	p("}")
+	return nil
+}
+
+// scalar returns the writer/reader method for a scalar type and whether
+// its values need a conversion.
+func scalar(t *goType) (method string, conv bool) {
+	switch t.kind {
+	case kString:
+		return "String", t.name != "string"
+	case kInt:
+		return "Int", t.name != "int64"
+	case kFloat:
+		return "Float", t.name != "float64"
+	case kBool:
+		return "Bool", t.name != "bool"
+	case kBytes:
+		return "Bytes", false
+	}
+	return "Object", false
+}
+
+// spell returns the Go spelling of t, noting when it needs the time import.
+func (g *generator) spell(t *goType) string {
+	if strings.Contains(t.name, "time.Time") {
+		g.usesTime = true
+	}
+	return t.name
+}
+
+// object returns the Encodable or Decodable passed to Object for ptr, a
+// pointer to a value of struct type t.
+func (g *generator) object(t *goType, ptr string) string {
+	if !t.time {
+		return ptr
+	}
+	g.usesBinary = true
+	return "binary.TimeOf(" + ptr + ")"
+}
+
+// writeCall returns the writer call for value v; wire is empty for array
+// elements.
+func (g *generator) writeCall(t *goType, v, wire string) string {
+	arg := v
+	switch t.kind {
+	case kStruct:
+		arg = g.object(t, "&"+v)
+	case kPtr:
+		arg = g.object(t.elem, v)
+	case kBytes:
+		if t.array {
+			arg = v + "[:]"
+		}
+	default:
+		method, conv := scalar(t)
+		if conv {
+			arg = map[string]string{"String": "string", "Int": "int64", "Float": "float64", "Bool": "bool"}[method] + "(" + v + ")"
+		}
+	}
+	method, _ := scalar(t)
+	if wire == "" {
+		return method + "(" + arg + ")"
+	}
+	return fmt.Sprintf("%s(%q, %s)", method, wire, arg)
+}
+
+func (g *generator) readElem(p func(string, ...any), t *goType, v string) {
+	switch t.kind {
+	case kStruct:
+		p("ar.Object(i, %s)", g.object(t, "&"+v))
+	case kPtr:
+		p("%s = &%s{}", v, g.spell(t.elem))
+		p("if !ar.Object(i, %s) {", g.object(t.elem, v))
+		p("%s = nil", v)
+		p("}")
+	default:
+		method, conv := scalar(t)
+		if conv {
+			p("%s = %s(ar.%s(i))", v, t.name, method)
+		} else {
+			p("%s = ar.%s(i)", v, method)
+		}
+	}
+}
+
+// discardElem reads an element that does not fit a fixed array, keeping the
+// reader in position.
+func (g *generator) discardElem(p func(string, ...any), t *goType) {
+	switch t.kind {
+	case kStruct:
+		p("ar.Object(i, %s)", g.object(t, "&"+g.spell(t)+"{}"))
+	case kPtr:
+		p("ar.Object(i, %s)", g.object(t.elem, "&"+g.spell(t.elem)+"{}"))
+	default:
+		method, _ := scalar(t)
+		p("ar.%s(i)", method)
+	}
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestGeneratedExampleUpToDate(t *testing.T) {
+	src, out, err := generate([]string{"internal/example/example.go"}, nil, "")
+	if err != nil {
+		t.Fatalf("generate failed: %v", err)
+	}
+	if out != filepath.Join("internal", "example", "example_binary.go") {
+		t.Errorf("Unexpected output file %s", out)
+	}
+	have, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(src, have) {
+		t.Errorf("%s is stale; run go generate ./cmd/binarygen/...", out)
+	}
+}
+
+func TestGenerateTypeFlag(t *testing.T) {
+	src, _, err := generate([]string{"internal/example"}, []string{"Point"}, "")
+	if err != nil {
+		t.Fatalf("generate failed: %v", err)
+	}
+	if !strings.Contains(string(src), "func (p *Point) EncodeFields") || strings.Contains(string(src), "Record") {
+		t.Errorf("Expected only Point methods, got:\n%s", src)
+	}
+}
+
+func TestGenerateTimeImports(t *testing.T) {
+	for decl, wantTime := range map[string]bool{"When time.Time": false, "When *time.Time": true, "Log []time.Time": true} {
+		dir := t.TempDir()
+		src := "package p\n\nimport \"time\"\n\ntype T struct {\n\t" + decl + "\n}\n"
+		if err := os.WriteFile(filepath.Join(dir, "t.go"), []byte(src), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		out, _, err := generate([]string{dir}, nil, "")
+		if err != nil {
+			t.Fatalf("%s: generate failed: %v", decl, err)
+		}
+		got := string(out)
+		if !strings.Contains(got, `"github.com/tinywasm/binary"`) || strings.Contains(got, `"time"`) != wantTime {
+			t.Errorf("%s: unexpected imports:\n%s", decl, got)
+		}
+	}
+}
+
+func TestGenerateErrors(t *testing.T) {
+	cases := map[string]string{
+		"map":      "Tags map[string]int",
+		"external": "Buf bytes.Buffer",
+		"nested":   "Grid [][]int",
+		"pointer":  "Count *int",
+		"inline":   "Inner struct{ A int }",
+	}
+	for name, decl := range cases {
+		dir := t.TempDir()
+		src := "package p\n\ntype T struct {\n\t" + decl + "\n}\n"
+		if err := os.WriteFile(filepath.Join(dir, "t.go"), []byte(src), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		_, _, err := generate([]string{dir}, nil, "")
+		if err == nil || !strings.Contains(err.Error(), "T.") {
+			t.Errorf("%s: expected a field error, got %v", name, err)
+		}
+
+		// Skipping the field makes it generate.
+		src = strings.Replace(src, decl, decl+" `binary:\"-\"`", 1)
+		if err := os.WriteFile(filepath.Join(dir, "t.go"), []byte(src), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if _, _, err := generate([]string{dir}, nil, ""); err != nil {
+			t.Errorf("%s: expected skipped field to generate, got %v", name, err)
+		}
+	}
+}
+
+func TestRunWritesOutput(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "user.go"), []byte("package p\n\ntype User struct {\n\tName string\n}\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	var stderr bytes.Buffer
+	if code := run([]string{filepath.Join(dir, "user.go")}, &stderr); code != 0 {
+		t.Fatalf("Expected exit 0, got %d: %s", code, stderr.String())
+	}
+	got, err := os.ReadFile(filepath.Join(dir, "user_binary.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(string(got), generatedHeader) || !strings.Contains(string(got), `w.String("Name", u.Name)`) {
+		t.Errorf("Unexpected output:\n%s", got)
+	}
+
+	// Running again ignores the generated file.
+	if code := run([]string{dir}, &stderr); code != 0 {
+		t.Fatalf("Expected rerun to succeed, got %d: %s", code, stderr.String())
+	}
+}
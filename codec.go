@@ -8,17 +8,31 @@ import (
 
 type binaryWriter struct {
 	out     io.Writer
+	buf     []byte // destination when out is nil
+	fixed   bool   // buf must not grow past its capacity
+	aw      binaryArrayWriter
 	scratch [10]byte
 	err     error
 }
 
 func (w *binaryWriter) reset(out io.Writer) {
 	w.out = out
+	w.buf = nil
+	w.fixed = false
+	w.aw.w = w
 	w.err = nil
 }
 
+// resetBuffer makes w append to dst instead of writing to an io.Writer.
+func (w *binaryWriter) resetBuffer(dst []byte, fixed bool) {
+	w.reset(nil)
+	w.buf = dst
+	w.fixed = fixed
+}
+
 func newWriter(out io.Writer) *binaryWriter {
 	w := &binaryWriter{out: out}
+	w.aw.w = w
 	return w
 }
 
@@ -26,7 +40,7 @@ func newWriter(out io.Writer) *binaryWriter {
 
 func (w *binaryWriter) String(name, val string) {
 	w.writeUvarint(uint64(len(val)))
-	w.write([]byte(val))
+	w.writeString(val)
 }
 
 func (w *binaryWriter) Raw(name, val string) {
@@ -42,23 +56,26 @@ func (w *binaryWriter) Uint(name string, val uint64) {
 }
 
 func (w *binaryWriter) Float(name string, val float64) {
-	bits := math.Float64bits(val)
-	w.scratch[0] = byte(bits)
-	w.scratch[1] = byte(bits >> 8)
-	w.scratch[2] = byte(bits >> 16)
-	w.scratch[3] = byte(bits >> 24)
-	w.scratch[4] = byte(bits >> 32)
-	w.scratch[5] = byte(bits >> 40)
-	w.scratch[6] = byte(bits >> 48)
-	w.scratch[7] = byte(bits >> 56)
-	w.write(w.scratch[:8])
+	w.write(appendFixed64(w.scratch[:0], math.Float64bits(val)))
+}
+
+// Float32 implements CompactWriter
+func (w *binaryWriter) Float32(name string, val float32) {
+	w.Fixed32(name, math.Float32bits(val))
+}
+
+// Fixed32 implements CompactWriter
+func (w *binaryWriter) Fixed32(name string, val uint32) {
+	w.write(appendFixed32(w.scratch[:0], val))
+}
+
+// Fixed64 implements CompactWriter
+func (w *binaryWriter) Fixed64(name string, val uint64) {
+	w.write(appendFixed64(w.scratch[:0], val))
 }
 
 func (w *binaryWriter) Bool(name string, val bool) {
-	w.scratch[0] = 0
-	if val {
-		w.scratch[0] = 1
-	}
+	w.scratch[0] = boolByte(val)
 	w.write(w.scratch[:1])
 }
 
@@ -72,6 +89,11 @@ func (w *binaryWriter) Null(name string) {
 	w.write(w.scratch[:1])
 }
 
+// Present implements OptionalWriter
+func (w *binaryWriter) Present(name string, ok bool) {
+	w.Bool(name, ok)
+}
+
 func (w *binaryWriter) Object(name string, val model.Encodable) {
 	if val != nil && !val.IsNil() {
 		w.scratch[0] = 1
@@ -82,9 +104,14 @@ func (w *binaryWriter) Object(name string, val model.Encodable) {
 	}
 }
 
+// Map implements MapWriter
+func (w *binaryWriter) Map(name string, n int, key, value Kind) model.ArrayWriter {
+	return w.Array(name, n)
+}
+
 func (w *binaryWriter) Array(name string, n int) model.ArrayWriter {
 	w.writeUvarint(uint64(n))
-	return &binaryArrayWriter{w: w}
+	return &w.aw
 }
 
 // ArrayWriter implementation
@@ -105,6 +132,18 @@ func (w *binaryArrayWriter) Float(val float64) {
 	w.w.Float("", val)
 }
 
+func (w *binaryArrayWriter) Float32(val float32) {
+	w.w.Float32("", val)
+}
+
+func (w *binaryArrayWriter) Fixed32(val uint32) {
+	w.w.Fixed32("", val)
+}
+
+func (w *binaryArrayWriter) Fixed64(val uint64) {
+	w.w.Fixed64("", val)
+}
+
 func (w *binaryArrayWriter) Bool(val bool) {
 	w.w.Bool("", val)
 }
@@ -121,20 +160,117 @@ func (w *binaryArrayWriter) Close() {
 	// binary protocol does not need a closing delimiter
 }
 
+// discardArrayWriter is a model.ArrayWriter that ignores its elements.
+type discardArrayWriter struct{}
+
+func (discardArrayWriter) String(val string)          {}
+func (discardArrayWriter) Int(val int64)              {}
+func (discardArrayWriter) Float(val float64)          {}
+func (discardArrayWriter) Bool(val bool)              {}
+func (discardArrayWriter) Bytes(val []byte)           {}
+func (discardArrayWriter) Object(val model.Encodable) {}
+func (discardArrayWriter) Close()                     {}
+
 // Internal helpers
 
 func (w *binaryWriter) write(p []byte) {
-	if w.err == nil {
+	if w.err != nil {
+		return
+	}
+	if w.out != nil {
 		_, w.err = w.out.Write(p)
+		return
+	}
+	if w.fixed && len(w.buf)+len(p) > cap(w.buf) {
+		w.err = ErrShortBuffer
+		return
+	}
+	w.buf = append(w.buf, p...)
+}
+
+// fail records an error raised while encoding, such as a failing
+// MarshalBinary; the first one wins.
+func (w *binaryWriter) fail(err error) {
+	if w.err == nil {
+		w.err = err
 	}
 }
 
+// writeString is write for strings, avoiding the []byte conversion when
+// appending to a buffer.
+func (w *binaryWriter) writeString(s string) {
+	if w.out != nil || w.err != nil {
+		w.write([]byte(s))
+		return
+	}
+	if w.fixed && len(w.buf)+len(s) > cap(w.buf) {
+		w.err = ErrShortBuffer
+		return
+	}
+	w.buf = append(w.buf, s...)
+}
+
 func (w *binaryWriter) writeVarint(v int64) {
+	w.writeUvarint(zigzag(v))
+}
+
+// appendUvarint appends x using the same encoding as writeUvarint.
+func appendUvarint(b []byte, x uint64) []byte {
+	for x >= 0x80 {
+		b = append(b, byte(x)|0x80)
+		x >>= 7
+	}
+	return append(b, byte(x))
+}
+
+// insertUvarint inserts the encoding of x at b[at:], shifting the tail.
+func insertUvarint(b []byte, at int, x uint64) []byte {
+	n := uvarintLen(x)
+	for i := 0; i < n; i++ {
+		b = append(b, 0)
+	}
+	copy(b[at+n:], b[at:len(b)-n])
+	appendUvarint(b[at:at], x)
+	return b
+}
+
+// appendFixed64 appends v as 8 little-endian bytes.
+func appendFixed64(b []byte, v uint64) []byte {
+	return append(b, byte(v), byte(v>>8), byte(v>>16), byte(v>>24),
+		byte(v>>32), byte(v>>40), byte(v>>48), byte(v>>56))
+}
+
+// appendFixed32 appends v as 4 little-endian bytes.
+func appendFixed32(b []byte, v uint32) []byte {
+	return append(b, byte(v), byte(v>>8), byte(v>>16), byte(v>>24))
+}
+
+// fixed32 reads 4 little-endian bytes.
+func fixed32(b []byte) uint32 {
+	return uint32(b[0]) | uint32(b[1])<<8 | uint32(b[2])<<16 | uint32(b[3])<<24
+}
+
+// fixed64 reads 8 little-endian bytes.
+func fixed64(b []byte) uint64 {
+	return uint64(b[0]) | uint64(b[1])<<8 | uint64(b[2])<<16 | uint64(b[3])<<24 |
+		uint64(b[4])<<32 | uint64(b[5])<<40 | uint64(b[6])<<48 | uint64(b[7])<<56
+}
+
+func boolByte(v bool) byte {
+	if v {
+		return 1
+	}
+	return 0
+}
+
+// zigzag maps signed integers to unsigned ones so that small magnitudes
+// of either sign encode to short varints.
+func zigzag(v int64) uint64 {
 	x := uint64(v) << 1
 	if v < 0 {
 		x = ^x
 	}
-	w.writeUvarint(x)
+	return x
 }
 
 func (w *binaryWriter) writeUvarint(x uint64) {
@@ -151,31 +287,37 @@ func (w *binaryWriter) writeUvarint(x uint64) {
 // --- Reader ---
 
 type binaryReader struct {
-	r reader
+	r      reader
+	err    error // first read error; once set, every read is a no-op
+	limits Limits
+	depth  int   // current Object nesting
+	end    int64 // input offset at which MaxTotalBytes is reached, 0 if unbounded
 }
 
-func (br *binaryReader) reset(r io.Reader) {
+func (br *binaryReader) reset(r io.Reader, limits Limits) {
 	br.r = newReader(r)
+	br.err = nil
+	br.setLimits(limits)
 }
 
 func newBinaryReader(r io.Reader) *binaryReader {
-	br := &binaryReader{r: newReader(r)}
+	br := &binaryReader{r: newReader(r), limits: DefaultLimits}
 	return br
 }
 
+// fail records err unless an earlier error is already set.
+func (br *binaryReader) fail(err error) {
+	if br.err == nil {
+		br.err = readError(err)
+	}
+}
+
 // FieldReader implementation
 
 func (br *binaryReader) String(name string) (string, bool) {
-	l, err := br.r.ReadUvarint()
-	if err != nil {
-		return "", false
-	}
-	if l == 0 {
-		return "", true
-	}
-	b, err := br.r.Slice(int(l))
-	if err != nil {
-		return "", false
+	b, ok := br.readBytes()
+	if !ok || len(b) == 0 {
+		return "", ok
 	}
 	return string(b), true
 }
@@ -185,74 +327,163 @@ func (br *binaryReader) Raw(name string) (string, bool) {
 }
 
 func (br *binaryReader) Int(name string) (int64, bool) {
-	v, err := br.r.ReadVarint()
-	if err != nil {
-		return 0, false
-	}
-	return v, true
+	v, ok := br.readUvarint()
+	return int64(v>>1) ^ -int64(v&1), ok
 }
 
 func (br *binaryReader) Uint(name string) (uint64, bool) {
-	v, err := br.r.ReadUvarint()
+	return br.readUvarint()
+}
+
+func (br *binaryReader) Float(name string) (float64, bool) {
+	v, ok := br.Fixed64(name)
+	return math.Float64frombits(v), ok
+}
+
+// Float32 implements CompactReader
+func (br *binaryReader) Float32(name string) (float32, bool) {
+	v, ok := br.Fixed32(name)
+	return math.Float32frombits(v), ok
+}
+
+// Fixed32 implements CompactReader
+func (br *binaryReader) Fixed32(name string) (uint32, bool) {
+	if br.err != nil || !br.within(4) {
+		return 0, false
+	}
+	b, err := br.r.Slice(4)
 	if err != nil {
+		br.fail(err)
 		return 0, false
 	}
-	return v, true
+	return fixed32(b), true
 }
 
-func (br *binaryReader) Float(name string) (float64, bool) {
+// Fixed64 implements CompactReader
+func (br *binaryReader) Fixed64(name string) (uint64, bool) {
+	if br.err != nil || !br.within(8) {
+		return 0, false
+	}
 	b, err := br.r.Slice(8)
 	if err != nil {
+		br.fail(err)
 		return 0, false
 	}
-	bits := uint64(b[0]) | uint64(b[1])<<8 | uint64(b[2])<<16 | uint64(b[3])<<24 |
-		uint64(b[4])<<32 | uint64(b[5])<<40 | uint64(b[6])<<48 | uint64(b[7])<<56
-	return math.Float64frombits(bits), true
+	return fixed64(b), true
 }
 
 func (br *binaryReader) Bool(name string) (bool, bool) {
-	b, err := br.r.ReadByte()
-	if err != nil {
-		return false, false
-	}
-	return b == 1, true
+	b, ok := br.readFlag()
+	return b, ok
 }
 
 func (br *binaryReader) Bytes(name string) ([]byte, bool) {
-	l, err := br.r.ReadUvarint()
-	if err != nil {
-		return nil, false
-	}
-	if l == 0 {
-		return nil, true
+	b, ok := br.readBytes()
+	if !ok || len(b) == 0 {
+		return nil, ok
 	}
-	b, err := br.r.Slice(int(l))
-	if err != nil {
-		return nil, false
+	if _, shared := br.r.(*sliceReader); shared {
+		b = append([]byte(nil), b...) // never hand out the caller's input buffer
 	}
 	return b, true
 }
 
+// Present implements OptionalReader
+func (br *binaryReader) Present(name string) (bool, bool) {
+	return br.readFlag()
+}
+
 func (br *binaryReader) Object(name string, into model.Decodable) bool {
 	if into == nil {
 		return false
 	}
-	presence, err := br.r.ReadByte()
-	if err != nil || presence == 0 {
+	present, ok := br.readFlag()
+	if !ok || !present || !br.enter() {
 		return false
 	}
 	into.DecodeFields(br)
-	return true
+	br.leave()
+	return br.err == nil
 }
 
 func (br *binaryReader) Array(name string) (model.ArrayReader, bool) {
-	l, err := br.r.ReadUvarint()
-	if err != nil {
+	l, ok := br.readUvarint()
+	if !ok {
+		return nil, false
+	}
+	if !br.checkCount(l) {
 		return nil, false
 	}
 	return &binaryArrayReader{br: br, len: int(l)}, true
 }
 
+// Map implements MapReader
+func (br *binaryReader) Map(name string) (model.ArrayReader, bool) {
+	l, ok := br.readUvarint()
+	if !ok {
+		return nil, false
+	}
+	if !br.checkMapLen(l) {
+		return nil, false
+	}
+	return &binaryArrayReader{br: br, len: 2 * int(l)}, true
+}
+
+// atEnd reports whether the input is exhausted, so that a trailing field
+// added to a type can be read as absent from data written before it. A
+// stream reader that cannot unread a byte is assumed to have more data.
+func (br *binaryReader) atEnd() bool {
+	if br.err != nil {
+		return false
+	}
+	switch r := br.r.(type) {
+	case *sliceReader:
+		return r.Len() == 0
+	case *streamReader:
+		return r.peek() == io.EOF
+	}
+	return false
+}
+
+// readFlag reads a single byte that must be 0 or 1, as written by Bool,
+// Null and the Object presence marker.
+func (br *binaryReader) readFlag() (bool, bool) {
+	if br.err != nil || !br.within(1) {
+		return false, false
+	}
+	b, err := br.r.ReadByte()
+	if err != nil {
+		br.fail(err)
+		return false, false
+	}
+	if b > 1 {
+		br.fail(ErrInvalidInput)
+		return false, false
+	}
+	return b == 1, true
+}
+
+// readBytes reads a length-prefixed byte slice. The result may alias the
+// input when it comes from a sliceReader.
+func (br *binaryReader) readBytes() ([]byte, bool) {
+	l, ok := br.readUvarint()
+	if !ok {
+		return nil, false
+	}
+	if l == 0 {
+		return nil, true
+	}
+	if !br.checkLen(l) {
+		return nil, false
+	}
+	b, err := br.r.Slice(int(l))
+	if err != nil {
+		br.fail(err)
+		return nil, false
+	}
+	return b, true
+}
+
 // ArrayReader implementation
 
 type binaryArrayReader struct {
@@ -279,6 +510,21 @@ func (ar *binaryArrayReader) Float(i int) float64 {
 	return val
 }
 
+func (ar *binaryArrayReader) Float32(i int) float32 {
+	val, _ := ar.br.Float32("")
+	return val
+}
+
+func (ar *binaryArrayReader) Fixed32(i int) uint32 {
+	val, _ := ar.br.Fixed32("")
+	return val
+}
+
+func (ar *binaryArrayReader) Fixed64(i int) uint64 {
+	val, _ := ar.br.Fixed64("")
+	return val
+}
+
 func (ar *binaryArrayReader) Bool(i int) bool {
 	val, _ := ar.br.Bool("")
 	return val
@@ -0,0 +1,167 @@
+package binary
+
+import "github.com/tinywasm/model"
+
+// CompactWriter is implemented by writers with fixed-width encodings:
+// Float32 writes 4 bytes instead of Float's 8, and Fixed32/Fixed64 write 4
+// and 8 little-endian bytes, which is smaller than a varint for hashes and
+// random IDs. Use WriteFloat32, WriteFixed32 and WriteFixed64, which fall
+// back to Float and Int on other writers.
+type CompactWriter interface {
+	Float32(name string, val float32)
+	Fixed32(name string, val uint32)
+	Fixed64(name string, val uint64)
+}
+
+// CompactReader is the reading side of CompactWriter.
+type CompactReader interface {
+	Float32(name string) (float32, bool)
+	Fixed32(name string) (uint32, bool)
+	Fixed64(name string) (uint64, bool)
+}
+
+// CompactArrayWriter is implemented by the array writers of a CompactWriter.
+type CompactArrayWriter interface {
+	Float32(val float32)
+	Fixed32(val uint32)
+	Fixed64(val uint64)
+}
+
+// CompactArrayReader is implemented by the array readers of a CompactReader.
+type CompactArrayReader interface {
+	Float32(i int) float32
+	Fixed32(i int) uint32
+	Fixed64(i int) uint64
+}
+
+// WriteFloat32 writes val in 4 bytes, or with Float when w is not a
+// CompactWriter.
+func WriteFloat32(w model.FieldWriter, name string, val float32) {
+	if cw, ok := w.(CompactWriter); ok {
+		cw.Float32(name, val)
+	} else {
+		w.Float(name, float64(val))
+	}
+}
+
+// ReadFloat32 reads a value written by WriteFloat32.
+func ReadFloat32(r model.FieldReader, name string) (float32, bool) {
+	if cr, ok := r.(CompactReader); ok {
+		return cr.Float32(name)
+	}
+	v, ok := r.Float(name)
+	return float32(v), ok
+}
+
+// WriteFixed32 writes val in 4 little-endian bytes, or with Int when w is
+// not a CompactWriter.
+func WriteFixed32(w model.FieldWriter, name string, val uint32) {
+	if cw, ok := w.(CompactWriter); ok {
+		cw.Fixed32(name, val)
+	} else {
+		w.Int(name, int64(val))
+	}
+}
+
+// ReadFixed32 reads a value written by WriteFixed32.
+func ReadFixed32(r model.FieldReader, name string) (uint32, bool) {
+	if cr, ok := r.(CompactReader); ok {
+		return cr.Fixed32(name)
+	}
+	v, ok := r.Int(name)
+	return uint32(v), ok
+}
+
+// WriteFixed64 writes val in 8 little-endian bytes, or with Int when w is
+// not a CompactWriter.
+func WriteFixed64(w model.FieldWriter, name string, val uint64) {
+	if cw, ok := w.(CompactWriter); ok {
+		cw.Fixed64(name, val)
+	} else {
+		w.Int(name, int64(val))
+	}
+}
+
+// ReadFixed64 reads a value written by WriteFixed64.
+func ReadFixed64(r model.FieldReader, name string) (uint64, bool) {
+	if cr, ok := r.(CompactReader); ok {
+		return cr.Fixed64(name)
+	}
+	v, ok := r.Int(name)
+	return uint64(v), ok
+}
+
+// WriteFloat32s writes s as an Array of 4-byte floats, half the size of
+// Float elements.
+func WriteFloat32s(w model.FieldWriter, name string, s []float32) {
+	aw := w.Array(name, len(s))
+	for _, v := range s {
+		float32Elem(aw, v)
+	}
+}
+
+// ReadFloat32s reads an Array written by WriteFloat32s. An empty array
+// decodes as nil.
+func ReadFloat32s(r model.FieldReader, name string) ([]float32, bool) {
+	ar, ok := r.Array(name)
+	if !ok {
+		return nil, false
+	}
+	if ar.Len() == 0 {
+		return nil, true
+	}
+	s := make([]float32, ar.Len())
+	for i := range s {
+		s[i] = float32At(ar, i)
+	}
+	return s, true
+}
+
+// float32Elem, fixed32Elem and fixed64Elem write an array element like
+// WriteFloat32, WriteFixed32 and WriteFixed64 write a field.
+func float32Elem(aw model.ArrayWriter, val float32) {
+	if cw, ok := aw.(CompactArrayWriter); ok {
+		cw.Float32(val)
+	} else {
+		aw.Float(float64(val))
+	}
+}
+
+func fixed32Elem(aw model.ArrayWriter, val uint32) {
+	if cw, ok := aw.(CompactArrayWriter); ok {
+		cw.Fixed32(val)
+	} else {
+		aw.Int(int64(val))
+	}
+}
+
+func fixed64Elem(aw model.ArrayWriter, val uint64) {
+	if cw, ok := aw.(CompactArrayWriter); ok {
+		cw.Fixed64(val)
+	} else {
+		aw.Int(int64(val))
+	}
+}
+
+// float32At, fixed32At and fixed64At are the reading side of float32Elem,
+// fixed32Elem and fixed64Elem.
+func float32At(ar model.ArrayReader, i int) float32 {
+	if cr, ok := ar.(CompactArrayReader); ok {
+		return cr.Float32(i)
+	}
+	return float32(ar.Float(i))
+}
+
+func fixed32At(ar model.ArrayReader, i int) uint32 {
+	if cr, ok := ar.(CompactArrayReader); ok {
+		return cr.Fixed32(i)
+	}
+	return uint32(ar.Int(i))
+}
+
+func fixed64At(ar model.ArrayReader, i int) uint64 {
+	if cr, ok := ar.(CompactArrayReader); ok {
+		return cr.Fixed64(i)
+	}
+	return uint64(ar.Int(i))
+}
@@ -0,0 +1,178 @@
+package binary
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/tinywasm/model"
+)
+
+// FixtureCompact uses the fixed-width encodings for every field.
+type FixtureCompact struct {
+	Temp    float32
+	ID      uint32
+	Hash    uint64
+	Samples []float32
+}
+
+func (f *FixtureCompact) EncodeFields(w model.FieldWriter) {
+	WriteFloat32(w, "Temp", f.Temp)
+	WriteFixed32(w, "ID", f.ID)
+	WriteFixed64(w, "Hash", f.Hash)
+	WriteFloat32s(w, "Samples", f.Samples)
+}
+
+func (f *FixtureCompact) DecodeFields(r model.FieldReader) {
+	f.Temp, _ = ReadFloat32(r, "Temp")
+	f.ID, _ = ReadFixed32(r, "ID")
+	f.Hash, _ = ReadFixed64(r, "Hash")
+	if v, ok := ReadFloat32s(r, "Samples"); ok {
+		f.Samples = v
+	}
+}
+
+func (f *FixtureCompact) IsNil() bool {
+	return f == nil
+}
+
+func compactValue() *FixtureCompact {
+	return &FixtureCompact{Temp: 0.1, ID: 0xdeadbeef, Hash: 1<<63 + 5, Samples: []float32{1.5, -2.25, 0.1}}
+}
+
+// plainFields hides the extension interfaces of the writers and readers
+// given to v, as a FieldWriter from another package would.
+type plainFields struct {
+	v *FixtureCompact
+}
+
+func (p plainFields) IsNil() bool { return p.v.IsNil() }
+
+func (p plainFields) EncodeFields(w model.FieldWriter) {
+	p.v.EncodeFields(struct{ model.FieldWriter }{w})
+}
+
+func (p plainFields) DecodeFields(r model.FieldReader) {
+	p.v.DecodeFields(struct{ model.FieldReader }{r})
+}
+
+func TestCompactRoundTrip(t *testing.T) {
+	in := compactValue()
+	var data []byte
+	if err := Encode(in, &data); err != nil {
+		t.Fatalf("Encode failed: %v", err)
+	}
+	want := []byte{
+		0xcd, 0xcc, 0xcc, 0x3d, // Temp
+		0xef, 0xbe, 0xad, 0xde, // ID
+		5, 0, 0, 0, 0, 0, 0, 0x80, // Hash
+		3, 0, 0, 0xc0, 0x3f, 0, 0, 0x10, 0xc0, 0xcd, 0xcc, 0xcc, 0x3d, // Samples
+	}
+	if !bytes.Equal(want, data) {
+		t.Errorf("Expected %x, got %x", want, data)
+	}
+	if n, err := Size(in); err != nil || n != len(data) {
+		t.Errorf("Expected size %d, got %d, %v", len(data), n, err)
+	}
+
+	decoders := map[string]func(in, out *FixtureCompact) error{
+		"positional": func(in, out *FixtureCompact) error { return Decode(data, out) },
+		"tagged": func(in, out *FixtureCompact) error {
+			var b []byte
+			if err := EncodeTagged(in, &b); err != nil {
+				return err
+			}
+			return DecodeTagged(b, out)
+		},
+		"sparse": func(in, out *FixtureCompact) error {
+			var b []byte
+			if err := EncodeSparse(in, &b); err != nil {
+				return err
+			}
+			_, err := DecodeSparse(b, out)
+			return err
+		},
+	}
+	for name, decode := range decoders {
+		out := &FixtureCompact{}
+		if err := decode(in, out); err != nil {
+			t.Fatalf("%s: decode failed: %v", name, err)
+		}
+		if !reflect.DeepEqual(in, out) {
+			t.Errorf("%s: expected %+v, got %+v", name, in, out)
+		}
+	}
+
+	// A fixed-width value read as a varint or a float64 fails the decode.
+	var tagged []byte
+	if err := EncodeTagged(in, &tagged); err != nil {
+		t.Fatal(err)
+	}
+	st := &taggedState{}
+	if _, ok := st.object(tagged).Int("ID"); ok || !errors.Is(st.err, ErrInvalidInput) {
+		t.Errorf("Expected ErrInvalidInput for Fixed32 field read as Int, got %v", st.err)
+	}
+	st = &taggedState{}
+	if _, ok := st.object(tagged).Float("Temp"); ok || !errors.Is(st.err, ErrInvalidInput) {
+		t.Errorf("Expected ErrInvalidInput for Float32 field read as Float, got %v", st.err)
+	}
+}
+
+func TestCompactFallback(t *testing.T) {
+	in := compactValue()
+	var compact, plain []byte
+	if err := Encode(in, &compact); err != nil {
+		t.Fatal(err)
+	}
+	if err := Encode(plainFields{in}, &plain); err != nil {
+		t.Fatal(err)
+	}
+	// Float and varints for the fields; the array writer still has the
+	// extension, so Samples keeps its 4-byte elements.
+	if len(plain) != 36 || len(compact) != 29 {
+		t.Errorf("Expected 36 bytes without and 29 with the extension, got %d and %d", len(plain), len(compact))
+	}
+	out := &FixtureCompact{}
+	if err := Decode(plain, plainFields{out}); err != nil {
+		t.Fatalf("Decode failed: %v", err)
+	}
+	if !reflect.DeepEqual(in, out) {
+		t.Errorf("Expected %+v, got %+v", in, out)
+	}
+
+	// Empty arrays decode as nil.
+	var empty []byte
+	if err := Encode(&FixtureCompact{Samples: []float32{}}, &empty); err != nil {
+		t.Fatal(err)
+	}
+	out = &FixtureCompact{Samples: []float32{1}}
+	if err := Decode(empty, out); err != nil || out.Samples != nil {
+		t.Errorf("Expected nil Samples, got %v, %v", out.Samples, err)
+	}
+}
+
+func TestCompactSchemaAndText(t *testing.T) {
+	in := compactValue()
+	s := SchemaOf(in)
+	for name, kind := range map[string]Kind{"Temp": KindFloat32, "ID": KindFixed32, "Hash": KindFixed64} {
+		if f, _ := s.Field(name); f.Kind != kind {
+			t.Errorf("Expected %s to be %s, got %s", name, kind, f.Kind)
+		}
+	}
+	if f, _ := s.Field("Samples"); f.Kind != KindArray || f.Elem != KindFloat32 {
+		t.Errorf("Unexpected Samples field %+v", f)
+	}
+	if Fingerprint(in) == Fingerprint(&FixtureBasic{}) {
+		t.Error("Expected distinct fingerprints")
+	}
+
+	got := Sprint(in)
+	if !strings.Contains(got, "Temp: 0.1") || !strings.Contains(got, "Samples: [1.5, -2.25, 0.1]") || !strings.Contains(got, "ID: 3735928559") {
+		t.Errorf("Unexpected text: %s", got)
+	}
+	if got := Sprint(Redact(in, "Hash", "Samples")); strings.Contains(got, "9223372036854775813") || strings.Contains(got, "2.25") {
+		t.Errorf("Expected redacted fields, got %s", got)
+	}
+}
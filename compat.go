@@ -0,0 +1,178 @@
+package binary
+
+import "github.com/tinywasm/fmt"
+
+// Incompatibility is one difference between two versions of a schema that
+// changes how the positional wire format is read.
+type Incompatibility struct {
+	Path   string // dotted field path, "[]" marks array elements: "List[].Name"
+	Reason string
+	// BreaksNewReaders is set when data written with the old schema cannot be
+	// read with the new one.
+	BreaksNewReaders bool
+	// BreaksOldReaders is set when data written with the new schema cannot be
+	// read with the old one.
+	BreaksOldReaders bool
+}
+
+// CheckCompatible compares two versions of a schema field by field and
+// reports reordered, removed, added and retyped fields. Renaming a field
+// without changing its kind or position keeps the wire format and is not
+// reported. Fields appended at the end of the top-level value only break
+// new readers of old data, which would run out of input; removing trailing
+// fields only breaks old readers. Inside nested objects and elements the
+// fields that follow would be misread, so there both break every reader.
+func CheckCompatible(old, new *Schema) []Incompatibility {
+	var issues []Incompatibility
+	checkSchemas("", old, new, &issues)
+	return issues
+}
+
+// Readable summarises issues: whether new readers can read old data and
+// whether old readers can read new data.
+func Readable(issues []Incompatibility) (newReadsOld, oldReadsNew bool) {
+	newReadsOld, oldReadsNew = true, true
+	for _, is := range issues {
+		if is.BreaksNewReaders {
+			newReadsOld = false
+		}
+		if is.BreaksOldReaders {
+			oldReadsNew = false
+		}
+	}
+	return newReadsOld, oldReadsNew
+}
+
+func checkSchemas(prefix string, old, new *Schema, issues *[]Incompatibility) {
+	if old == nil || new == nil {
+		return // nested layout unknown on one side
+	}
+	add := func(name, reason string, breaksNew, breaksOld bool) {
+		*issues = append(*issues, Incompatibility{
+			Path:             prefix + name,
+			Reason:           reason,
+			BreaksNewReaders: breaksNew,
+			BreaksOldReaders: breaksOld,
+		})
+	}
+
+	for i, of := range old.Fields {
+		j := fieldIndex(new, of.Name)
+		switch {
+		case j == i:
+			checkFields(prefix, of, new.Fields[j], issues)
+		case j >= 0:
+			add(of.Name, "moved from position "+fmt.Convert(i).String()+" to "+fmt.Convert(j).String(), true, true)
+		case isRename(old, new, i):
+			checkFields(prefix, of, new.Fields[i], issues)
+		case i >= len(new.Fields):
+			add(of.Name, "trailing field removed", prefix != "", true)
+		default:
+			add(of.Name, "field removed", true, true)
+		}
+	}
+
+	for j, nf := range new.Fields {
+		if fieldIndex(old, nf.Name) >= 0 || isRename(old, new, j) {
+			continue
+		}
+		if j >= len(old.Fields) {
+			add(nf.Name, "trailing field added", true, prefix != "")
+		} else {
+			add(nf.Name, "field added before the end", true, true)
+		}
+	}
+}
+
+// checkFields compares two fields found at the same position.
+func checkFields(prefix string, of, nf SchemaField, issues *[]Incompatibility) {
+	path := prefix + nf.Name
+	if !sameWire(of.Kind, nf.Kind) {
+		*issues = append(*issues, Incompatibility{
+			Path:             path,
+			Reason:           "kind changed from " + of.Kind.String() + " to " + nf.Kind.String(),
+			BreaksNewReaders: true,
+			BreaksOldReaders: true,
+		})
+		return
+	}
+	switch nf.Kind {
+	case KindObject:
+		checkSchemas(path+".", of.Schema, nf.Schema, issues)
+	case KindArray:
+		if of.Elem == KindInvalid || nf.Elem == KindInvalid {
+			return // element kind unknown on one side
+		}
+		if !sameWire(of.Elem, nf.Elem) {
+			*issues = append(*issues, Incompatibility{
+				Path:             path + "[]",
+				Reason:           "element kind changed from " + of.Elem.String() + " to " + nf.Elem.String(),
+				BreaksNewReaders: true,
+				BreaksOldReaders: true,
+			})
+			return
+		}
+		if nf.Elem == KindObject {
+			checkSchemas(path+"[].", of.Schema, nf.Schema, issues)
+		}
+	case KindMap:
+		if of.Key == KindInvalid || nf.Key == KindInvalid || of.Elem == KindInvalid || nf.Elem == KindInvalid {
+			return // kinds unknown on one side
+		}
+		if !sameWire(of.Key, nf.Key) || !sameWire(of.Elem, nf.Elem) {
+			*issues = append(*issues, Incompatibility{
+				Path:             path,
+				Reason:           "map kinds changed from " + of.Key.String() + "/" + of.Elem.String() + " to " + nf.Key.String() + "/" + nf.Elem.String(),
+				BreaksNewReaders: true,
+				BreaksOldReaders: true,
+			})
+			return
+		}
+		if nf.Elem == KindObject {
+			checkSchemas(path+"[].", of.Schema, nf.Schema, issues)
+		}
+	case KindOptional:
+		if of.Elem != KindInvalid && nf.Elem != KindInvalid && !sameWire(of.Elem, nf.Elem) {
+			*issues = append(*issues, Incompatibility{
+				Path:             path,
+				Reason:           "optional kind changed from " + of.Elem.String() + " to " + nf.Elem.String(),
+				BreaksNewReaders: true,
+				BreaksOldReaders: true,
+			})
+			return
+		}
+		switch {
+		case nf.Elem == KindObject:
+			checkSchemas(path+".", of.Schema, nf.Schema, issues)
+		case of.Schema != nil && nf.Schema != nil && len(of.Schema.Fields) == 1 && len(nf.Schema.Fields) == 1:
+			checkFields(prefix, of.Schema.Fields[0], nf.Schema.Fields[0], issues) // an Array or Map value
+		}
+	}
+}
+
+// isRename reports whether position i holds a field that exists under a
+// different name on each side, which is a pure rename.
+func isRename(old, new *Schema, i int) bool {
+	return i < len(old.Fields) && i < len(new.Fields) &&
+		fieldIndex(new, old.Fields[i].Name) < 0 &&
+		fieldIndex(old, new.Fields[i].Name) < 0
+}
+
+// sameWire reports whether two kinds share a wire layout. String, Raw and
+// Bytes are all a uvarint length followed by the bytes.
+func sameWire(a, b Kind) bool {
+	return a == b || lengthPrefixed(a) && lengthPrefixed(b)
+}
+
+func lengthPrefixed(k Kind) bool {
+	return k == KindString || k == KindRaw || k == KindBytes
+}
+
+func fieldIndex(s *Schema, name string) int {
+	for i, f := range s.Fields {
+		if f.Name == name {
+			return i
+		}
+	}
+	return -1
+}
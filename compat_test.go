@@ -0,0 +1,129 @@
+package binary
+
+import (
+	"reflect"
+	"testing"
+)
+
+func schemaOfFields(fields ...SchemaField) *Schema {
+	return &Schema{Fields: fields}
+}
+
+func TestCheckCompatible(t *testing.T) {
+	base := SchemaOf(complexSchemaValue())
+
+	cases := []struct {
+		name   string
+		new    *Schema
+		issues []Incompatibility
+	}{
+		{"Identical", SchemaOf(complexSchemaValue()), nil},
+		{
+			"TrailingFieldAdded",
+			schemaOfFields(append(append([]SchemaField(nil), base.Fields...), SchemaField{Name: "Extra", Kind: KindString})...),
+			[]Incompatibility{{Path: "Extra", Reason: "trailing field added", BreaksNewReaders: true}},
+		},
+		{
+			"TrailingFieldRemoved",
+			schemaOfFields(base.Fields[:4]...),
+			[]Incompatibility{{Path: "Matrix", Reason: "trailing field removed", BreaksOldReaders: true}},
+		},
+		{
+			"FieldRemoved",
+			schemaOfFields(base.Fields[0], base.Fields[2], base.Fields[3], base.Fields[4]),
+			[]Incompatibility{
+				{Path: "Primary", Reason: "field removed", BreaksNewReaders: true, BreaksOldReaders: true},
+				{Path: "Secondary", Reason: "moved from position 2 to 1", BreaksNewReaders: true, BreaksOldReaders: true},
+				{Path: "List", Reason: "moved from position 3 to 2", BreaksNewReaders: true, BreaksOldReaders: true},
+				{Path: "Matrix", Reason: "moved from position 4 to 3", BreaksNewReaders: true, BreaksOldReaders: true},
+			},
+		},
+		{
+			"Renamed",
+			schemaOfFields(SchemaField{Name: "Key", Kind: KindInt}, base.Fields[1], base.Fields[2], base.Fields[3], base.Fields[4]),
+			nil,
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := CheckCompatible(base, tc.new)
+			if !reflect.DeepEqual(tc.issues, got) {
+				t.Errorf("Expected %+v, got %+v", tc.issues, got)
+			}
+		})
+	}
+}
+
+func TestCheckCompatibleWireKinds(t *testing.T) {
+	old := schemaOfFields(SchemaField{Name: "A", Kind: KindString}, SchemaField{Name: "B", Kind: KindArray, Elem: KindBytes})
+	for _, k := range []Kind{KindString, KindRaw, KindBytes} {
+		new := schemaOfFields(SchemaField{Name: "A", Kind: k}, SchemaField{Name: "B", Kind: KindArray, Elem: k})
+		if got := CheckCompatible(old, new); got != nil {
+			t.Errorf("%s: expected no issues, got %+v", k, got)
+		}
+	}
+	new := schemaOfFields(SchemaField{Name: "A", Kind: KindInt}, SchemaField{Name: "B", Kind: KindArray, Elem: KindBytes})
+	want := []Incompatibility{{Path: "A", Reason: "kind changed from string to int", BreaksNewReaders: true, BreaksOldReaders: true}}
+	if got := CheckCompatible(old, new); !reflect.DeepEqual(want, got) {
+		t.Errorf("Expected %+v, got %+v", want, got)
+	}
+}
+
+func TestCheckCompatibleNested(t *testing.T) {
+	old := SchemaOf(complexSchemaValue())
+	new := SchemaOf(complexSchemaValue())
+	new.Fields[1].Schema.Fields[6].Kind = KindString // Primary.Score
+	new.Fields[3].Schema = SchemaOf(&userV1{})       // List[] element type
+	new.Fields[4].Elem = KindFloat                   // Matrix[]
+
+	got := CheckCompatible(old, new)
+	paths := map[string]bool{}
+	for _, is := range got {
+		paths[is.Path] = true
+	}
+	for _, want := range []string{"Primary.Score", "List[].Legacy", "List[].Count", "Matrix[]"} {
+		if !paths[want] {
+			t.Errorf("Expected an issue at %s, got %+v", want, got)
+		}
+	}
+	newReadsOld, oldReadsNew := Readable(got)
+	if newReadsOld || oldReadsNew {
+		t.Error("Expected retyped fields to break both directions")
+	}
+}
+
+func TestCheckCompatibleNestedTrailing(t *testing.T) {
+	got := CheckCompatible(SchemaOf(&outV1{}), SchemaOf(&outV2{}))
+	if len(got) != 1 || got[0].Path != "In.B" || !got[0].BreaksNewReaders || !got[0].BreaksOldReaders {
+		t.Fatalf("Expected In.B to break both directions, got %+v", got)
+	}
+	// The probe behind the rule: an old reader misreads the fields after In.
+	var data []byte
+	if err := Encode(&outV2{In: inV2{A: "a", B: "bbbb"}, Tail: "t"}, &data); err != nil {
+		t.Fatal(err)
+	}
+	var old outV1
+	if err := Decode(data, &old); err == nil && old.Tail == "t" {
+		t.Error("Expected the old reader to misread Tail")
+	}
+
+	got = CheckCompatible(SchemaOf(&outV2{}), SchemaOf(&outV1{}))
+	if newReadsOld, oldReadsNew := Readable(got); newReadsOld || oldReadsNew {
+		t.Errorf("Expected a removed nested trailing field to break both directions, got %+v", got)
+	}
+}
+
+func TestCheckCompatibleReorderedVersions(t *testing.T) {
+	issues := CheckCompatible(SchemaOf(&userV1{}), SchemaOf(&userV2{}))
+	if len(issues) == 0 {
+		t.Fatal("Expected userV1 -> userV2 to be incompatible")
+	}
+
+	// Appending a field keeps old readers working but not the other way round.
+	grown := SchemaOf(&userV1{})
+	grown.Fields = append(grown.Fields, SchemaField{Name: "Email", Kind: KindString})
+	newReadsOld, oldReadsNew := Readable(CheckCompatible(SchemaOf(&userV1{}), grown))
+	if newReadsOld || !oldReadsNew {
+		t.Errorf("Expected (false, true), got (%v, %v)", newReadsOld, oldReadsNew)
+	}
+}
@@ -1,6 +1,7 @@
 package binary
 
 import (
+	"errors"
 	"io"
 	"reflect"
 	"testing"
@@ -55,3 +56,70 @@ func TestDecodeFromReader(t *testing.T) {
 		t.Errorf("Expected %v, got %v", string(data), decoded.val)
 	}
 }
+
+func TestDecodeTruncatedInput(t *testing.T) {
+	v := &FixtureComplex{
+		ID:        7,
+		Primary:   FixtureBasic{Name: "Primary", Payload: []byte{1, 2}, Tags: []uint32{1, 2}, Score: 1.5},
+		Secondary: &FixtureBasic{Name: "Secondary", Active: true},
+		List:      []FixtureBasic{{Name: "Item"}},
+		Matrix:    [3]int{1, 2, 3},
+	}
+	var encoded []byte
+	if err := Encode(v, &encoded); err != nil {
+		t.Fatalf("Encode failed: %v", err)
+	}
+
+	// Every proper prefix of a valid encoding must fail, on both reader paths.
+	for n := 0; n < len(encoded); n++ {
+		if err := Decode(encoded[:n], &FixtureComplex{}); !errors.Is(err, ErrTruncated) {
+			t.Fatalf("prefix %d/%d: expected ErrTruncated, got %v", n, len(encoded), err)
+		}
+		err := Decode(&oneByteReader{content: encoded[:n]}, &FixtureComplex{})
+		if !errors.Is(err, ErrTruncated) {
+			t.Fatalf("stream prefix %d/%d: expected ErrTruncated, got %v", n, len(encoded), err)
+		}
+	}
+
+	if err := Decode(encoded, &FixtureComplex{}); err != nil {
+		t.Fatalf("Unexpected error on full input: %v", err)
+	}
+}
+
+func TestDecodeCorruptInput(t *testing.T) {
+	overflow := []byte{0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x01}
+
+	t.Run("VarintOverflow", func(t *testing.T) {
+		if err := Decode(overflow, &s0{}); !errors.Is(err, ErrVarintOverflow) {
+			t.Errorf("Expected ErrVarintOverflow, got %v", err)
+		}
+		if err := Decode(&oneByteReader{content: overflow}, &s0{}); !errors.Is(err, ErrVarintOverflow) {
+			t.Errorf("Expected ErrVarintOverflow from stream, got %v", err)
+		}
+	})
+
+	t.Run("InvalidBool", func(t *testing.T) {
+		data := []byte{0x00, 0x00, 0x00, 0x00, 0x00, 0x02}
+		if err := Decode(data, &FixtureBasic{}); !errors.Is(err, ErrInvalidInput) {
+			t.Errorf("Expected ErrInvalidInput, got %v", err)
+		}
+	})
+
+	t.Run("InvalidPresence", func(t *testing.T) {
+		data := []byte{0x00, 0x07}
+		if err := Decode(data, &FixtureComplex{}); !errors.Is(err, ErrInvalidInput) {
+			t.Errorf("Expected ErrInvalidInput, got %v", err)
+		}
+	})
+
+	t.Run("StickyError", func(t *testing.T) {
+		// Once the first field fails, later fields must not consume input.
+		s := &s0{}
+		if err := Decode([]byte{0x05, 'A'}, s); !errors.Is(err, ErrTruncated) {
+			t.Errorf("Expected ErrTruncated, got %v", err)
+		}
+		if s.A != "" || s.B != "" || s.C != 0 {
+			t.Errorf("Expected zero fields after failure, got %+v", s)
+		}
+	})
+}
@@ -40,6 +40,15 @@ func BenchmarkBinary(b *testing.B) {
 		}
 	})
 
+	b.Run("append", func(b *testing.B) {
+		b.ReportAllocs()
+		b.ResetTimer()
+		out := make([]byte, 0, 64)
+		for n := 0; n < b.N; n++ {
+			out, _ = AppendEncode(out[:0], &v)
+		}
+	})
+
 	b.Run("unmarshal", func(b *testing.B) {
 		b.ReportAllocs()
 		b.ResetTimer()
@@ -142,3 +151,70 @@ func TestMarshalWithCustomcodec(t *testing.T) {
 		t.Errorf("Expected %v, got %v", v, out)
 	}
 }
+
+func TestAppendEncode(t *testing.T) {
+	var want []byte
+	if err := Encode(s0v, &want); err != nil {
+		t.Fatalf("Encode failed: %v", err)
+	}
+
+	prefix := []byte{0xca, 0xfe}
+	got, err := AppendEncode(prefix, s0v)
+	assertNoError(t, err)
+	assertEqualBytes(t, append([]byte{0xca, 0xfe}, want...), got)
+
+	// Appending twice yields two back-to-back values.
+	got, err = AppendEncode(got[:0], s0v)
+	assertNoError(t, err)
+	got, err = AppendEncode(got, s0v)
+	assertNoError(t, err)
+	assertEqualBytes(t, append(append([]byte{}, want...), want...), got)
+
+	if _, err := AppendEncode(nil, nil); err == nil {
+		t.Error("Expected error for nil input")
+	}
+}
+
+func TestEncodeInto(t *testing.T) {
+	var want []byte
+	if err := Encode(s0v, &want); err != nil {
+		t.Fatalf("Encode failed: %v", err)
+	}
+
+	buf := make([]byte, 64)
+	n, err := EncodeInto(buf, s0v)
+	assertNoError(t, err)
+	assertEqualBytes(t, want, buf[:n])
+
+	exact := make([]byte, len(want))
+	n, err = EncodeInto(exact, s0v)
+	assertNoError(t, err)
+	assertEqualInt(t, len(want), n)
+
+	short := make([]byte, len(want)-1, 64)
+	n, err = EncodeInto(short, s0v)
+	if err != ErrShortBuffer {
+		t.Errorf("Expected ErrShortBuffer, got %v", err)
+	}
+	assertEqualInt(t, 0, n)
+}
+
+func TestAppendEncodeAllocations(t *testing.T) {
+	v := testMsg
+	buf := make([]byte, 0, 256)
+	fixed := make([]byte, 256)
+
+	allocs := testing.AllocsPerRun(1000, func() {
+		buf, _ = AppendEncode(buf[:0], &v)
+	})
+	if allocs != 0 {
+		t.Errorf("AppendEncode: expected 0 allocations, got %v", allocs)
+	}
+
+	allocs = testing.AllocsPerRun(1000, func() {
+		_, _ = EncodeInto(fixed, &v)
+	})
+	if allocs != 0 {
+		t.Errorf("EncodeInto: expected 0 allocations, got %v", allocs)
+	}
+}
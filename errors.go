@@ -0,0 +1,33 @@
+package binary
+
+import (
+	"io"
+
+	"github.com/tinywasm/fmt"
+)
+
+// Decoding errors. They are returned as-is (or wrapped with fmt.ErrType) so
+// callers can match them with errors.Is.
+var (
+	// ErrTruncated reports that the input ended before the value was complete.
+	ErrTruncated = fmt.Err("binary", "truncated input")
+	// ErrVarintOverflow reports a varint that does not fit in 64 bits.
+	ErrVarintOverflow = fmt.Err("binary", "varint overflow 64-bit integer")
+	// ErrInvalidInput reports bytes that can never be produced by the encoder,
+	// such as a bool or presence byte other than 0 or 1.
+	ErrInvalidInput = fmt.Err("binary", "invalid input")
+	// ErrLimitExceeded reports input that breaks one of the decode Limits.
+	ErrLimitExceeded = fmt.Err("binary", "decode limit exceeded")
+)
+
+// ErrShortBuffer reports that EncodeInto ran out of room in its buffer.
+var ErrShortBuffer = fmt.Err("binary", "short buffer")
+
+// readError maps low-level reader errors to the package error values.
+func readError(err error) error {
+	switch err {
+	case io.EOF, io.ErrUnexpectedEOF:
+		return ErrTruncated
+	}
+	return err
+}
@@ -0,0 +1,49 @@
+package binary
+
+import (
+	"github.com/tinywasm/fmt"
+	"github.com/tinywasm/model"
+)
+
+// ErrFingerprintMismatch reports a payload written by a type whose fields
+// differ from the type it is being decoded into.
+var ErrFingerprintMismatch = fmt.Err("binary", "payload fingerprint mismatch")
+
+// Fingerprint returns a stable 32-bit hash of the names and kinds of the
+// fields the type of v writes in EncodeFields, in order. It is never zero.
+//
+// The hash only covers what every value of the type shares: the fields
+// SchemaOf records at the top level and the key and value kinds of maps.
+// Nested objects, array elements and optional values are left out, since a
+// nil pointer, an empty slice or an absent value would hide them, so values
+// of one type always share a fingerprint. Use CheckCompatible on captured
+// schemas to compare nested layouts.
+func Fingerprint(v model.Encodable) uint32 {
+	return SchemaOf(v).Fingerprint()
+}
+
+// Fingerprint returns the fingerprint of the type s was captured from.
+func (s *Schema) Fingerprint() uint32 {
+	h := uint32(fnvOffset32)
+	add := func(b byte) { h = (h ^ uint32(b)) * fnvPrime32 }
+	for _, f := range s.Fields {
+		add(byte(f.Kind))
+		for i := 0; i < len(f.Name); i++ {
+			add(f.Name[i])
+		}
+		add(0xff) // name terminator; 0xff never occurs in UTF-8
+		if f.Kind == KindMap {
+			add(byte(f.Key))
+			add(byte(f.Elem))
+		}
+	}
+	if h == 0 {
+		return 1
+	}
+	return h
+}
+
+const (
+	fnvOffset32 = 2166136261
+	fnvPrime32  = 16777619
+)
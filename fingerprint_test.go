@@ -0,0 +1,244 @@
+package binary
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/tinywasm/model"
+)
+
+// basicDecodeOnly exposes only the DecodeFields half of FixtureBasic.
+type basicDecodeOnly struct {
+	f FixtureBasic
+}
+
+func (b *basicDecodeOnly) IsNil() bool                      { return b == nil }
+func (b *basicDecodeOnly) DecodeFields(r model.FieldReader) { b.f.DecodeFields(r) }
+
+func TestFingerprint(t *testing.T) {
+	fp := Fingerprint(&FixtureBasic{})
+	if fp == 0 {
+		t.Fatal("Expected a non-zero fingerprint")
+	}
+
+	// Values of one type share a fingerprint, whatever they hold.
+	for _, v := range []*FixtureBasic{{Name: "x", Score: 3}, {Tags: []uint32{1, 2}}} {
+		if got := Fingerprint(v); got != fp {
+			t.Errorf("Expected %x for %+v, got %x", fp, v, got)
+		}
+	}
+	if a, b := Fingerprint(&FixtureComplex{}), Fingerprint(complexSchemaValue()); a != b {
+		t.Errorf("Expected nested values not to change the fingerprint, got %x and %x", a, b)
+	}
+	if got := SchemaOf(&FixtureBasic{}).Fingerprint(); got != fp {
+		t.Errorf("Expected %x from Schema, got %x", fp, got)
+	}
+
+	// Different names, kinds, order or map kinds give different fingerprints.
+	distinct := map[uint32]string{fp: "FixtureBasic"}
+	for name, v := range map[string]model.Encodable{
+		"FixtureComplex": &FixtureComplex{},
+		"FixtureMaps":    &FixtureMaps{},
+		"userV1":         &userV1{},
+		"userV2":         &userV2{},
+		"s0":             &s0{},
+		"Message":        &Message{},
+	} {
+		got := Fingerprint(v)
+		if other, dup := distinct[got]; dup {
+			t.Errorf("%s and %s share fingerprint %x", name, other, got)
+		}
+		distinct[got] = name
+	}
+	maps := SchemaOf(&FixtureMaps{})
+	maps.Fields[0].Elem = KindBytes
+	if maps.Fingerprint() == Fingerprint(&FixtureMaps{}) {
+		t.Error("Expected the map value kind to be hashed")
+	}
+}
+
+func TestMessagePayloadFingerprint(t *testing.T) {
+	var m Message
+	if err := m.SetPayload(&FixtureBasic{Name: "payload", Score: 1.5}); err != nil {
+		t.Fatalf("SetPayload failed: %v", err)
+	}
+	if m.Fingerprint != Fingerprint(&FixtureBasic{}) {
+		t.Errorf("Expected fingerprint %x, got %x", Fingerprint(&FixtureBasic{}), m.Fingerprint)
+	}
+
+	// The fingerprint travels with the envelope.
+	var data []byte
+	if err := Encode(&m, &data); err != nil {
+		t.Fatalf("Encode failed: %v", err)
+	}
+	var received Message
+	if err := Decode(data, &received); err != nil {
+		t.Fatalf("Decode failed: %v", err)
+	}
+
+	var basic FixtureBasic
+	if err := received.DecodePayload(&basic); err != nil {
+		t.Fatalf("DecodePayload failed: %v", err)
+	}
+	if basic.Name != "payload" || basic.Score != 1.5 {
+		t.Errorf("Unexpected payload %+v", basic)
+	}
+
+	// A mismatch is found before decoding and leaves the target alone.
+	user := &userV1{Name: "kept", Age: 3}
+	if err := received.DecodePayload(user); !errors.Is(err, ErrFingerprintMismatch) {
+		t.Errorf("Expected ErrFingerprintMismatch, got %v", err)
+	}
+	if user.Name != "kept" || user.Age != 3 {
+		t.Errorf("Expected the target to be untouched, got %+v", user)
+	}
+	if err := received.DecodePayload(&s0{}); !errors.Is(err, ErrFingerprintMismatch) {
+		t.Errorf("Expected ErrFingerprintMismatch, got %v", err)
+	}
+	wrong := &basicDecodeOnly{}
+	if err := received.DecodePayload(wrong); err == nil || wrong.f.Name != "" {
+		t.Errorf("Expected a Decodable-only target to be rejected, got %+v, %v", wrong.f, err)
+	}
+
+	// Messages without a fingerprint are decoded unchecked.
+	received.Fingerprint = 0
+	if err := received.DecodePayload(&s0{}); err != nil {
+		t.Errorf("Expected unchecked decode, got %v", err)
+	}
+	if err := received.DecodePayload(wrong); err != nil || wrong.f.Name != "payload" {
+		t.Errorf("Expected unchecked decode, got %+v, %v", wrong.f, err)
+	}
+}
+
+// messageV0 is the envelope as written before Fingerprint was added.
+type messageV0 struct {
+	m Message
+}
+
+func (v *messageV0) IsNil() bool { return v == nil }
+
+func (v *messageV0) EncodeFields(w model.FieldWriter) {
+	w.String("Topic", v.m.Topic)
+	w.Int("Type", int64(v.m.Type))
+	w.Int("ID", int64(v.m.ID))
+	w.Bytes("Payload", v.m.Payload)
+}
+
+func TestMessageWithoutFingerprint(t *testing.T) {
+	old := &messageV0{m: Message{Topic: "users.created", ID: 7, Payload: []byte{1, 2}}}
+	var data []byte
+	if err := Encode(old, &data); err != nil {
+		t.Fatal(err)
+	}
+	var got Message
+	if err := Decode(data, &got); err != nil || !reflect.DeepEqual(old.m, got) {
+		t.Errorf("Expected %+v from a pre-fingerprint envelope, got %+v, %v", old.m, got, err)
+	}
+	got = Message{}
+	if err := NewDecoder(bytes.NewReader(data)).Decode(&got); err != nil || !reflect.DeepEqual(old.m, got) {
+		t.Errorf("Expected %+v from a stream, got %+v, %v", old.m, got, err)
+	}
+	var framed bytes.Buffer
+	if err := WriteFrame(&framed, old); err != nil {
+		t.Fatal(err)
+	}
+	got = Message{}
+	if err := ReadFrame(&framed, &got); err != nil || !reflect.DeepEqual(old.m, got) {
+		t.Errorf("Expected %+v from a frame, got %+v, %v", old.m, got, err)
+	}
+
+	// An unset fingerprint costs one byte; a set one round-trips in every mode.
+	var current []byte
+	if err := Encode(&old.m, &current); err != nil || len(current) != len(data)+1 {
+		t.Errorf("Expected %d bytes, got %d, %v", len(data)+1, len(current), err)
+	}
+	m := old.m
+	m.Fingerprint = Fingerprint(&FixtureBasic{})
+	for name, roundTrip := range map[string]func(in, out *Message) error{
+		"positional": func(in, out *Message) error {
+			var b []byte
+			if err := Encode(in, &b); err != nil {
+				return err
+			}
+			return Decode(b, out)
+		},
+		"tagged": func(in, out *Message) error {
+			var b []byte
+			if err := EncodeTagged(in, &b); err != nil {
+				return err
+			}
+			return DecodeTagged(b, out)
+		},
+	} {
+		var out Message
+		if err := roundTrip(&m, &out); err != nil || !reflect.DeepEqual(m, out) {
+			t.Errorf("%s: expected %+v, got %+v, %v", name, m, out, err)
+		}
+	}
+}
+
+// inV1 and inV2 differ only inside a nested object, which outV1 and outV2
+// hold by value ahead of another field.
+type inV1 struct{ A string }
+type inV2 struct{ A, B string }
+
+func (v *inV1) IsNil() bool                      { return v == nil }
+func (v *inV1) EncodeFields(w model.FieldWriter) { w.String("A", v.A) }
+func (v *inV1) DecodeFields(r model.FieldReader) { v.A, _ = r.String("A") }
+func (v *inV2) IsNil() bool                      { return v == nil }
+func (v *inV2) EncodeFields(w model.FieldWriter) { w.String("A", v.A); w.String("B", v.B) }
+func (v *inV2) DecodeFields(r model.FieldReader) { v.A, _ = r.String("A"); v.B, _ = r.String("B") }
+
+type outV1 struct {
+	In   inV1
+	Tail string
+}
+
+type outV2 struct {
+	In   inV2
+	Tail string
+}
+
+func (v *outV1) IsNil() bool { return v == nil }
+func (v *outV1) EncodeFields(w model.FieldWriter) {
+	w.Object("In", &v.In)
+	w.String("Tail", v.Tail)
+}
+func (v *outV1) DecodeFields(r model.FieldReader) {
+	r.Object("In", &v.In)
+	v.Tail, _ = r.String("Tail")
+}
+func (v *outV2) IsNil() bool { return v == nil }
+func (v *outV2) EncodeFields(w model.FieldWriter) {
+	w.Object("In", &v.In)
+	w.String("Tail", v.Tail)
+}
+func (v *outV2) DecodeFields(r model.FieldReader) {
+	r.Object("In", &v.In)
+	v.Tail, _ = r.String("Tail")
+}
+
+func TestFingerprintTypeLevel(t *testing.T) {
+	// The sender holds a non-empty nested array and a present optional; the
+	// receiver starts from a zero value.
+	in := &FixtureComplex{ID: 9, List: []FixtureBasic{{Name: "a", Tags: []uint32{1}}}, Secondary: &FixtureBasic{Count: 2}}
+	var m Message
+	if err := m.SetPayload(in); err != nil {
+		t.Fatal(err)
+	}
+	var out FixtureComplex
+	if err := m.DecodePayload(&out); err != nil || !reflect.DeepEqual(in, &out) {
+		t.Errorf("Expected %+v, got %+v, %v", in, out, err)
+	}
+
+	opt := &FixtureOptional{Count: ptr(3), Ratio: ptr(float32(0.5)), After: "x"}
+	if err := m.SetPayload(opt); err != nil {
+		t.Fatal(err)
+	}
+	var gotOpt FixtureOptional
+	if err := m.DecodePayload(&gotOpt); err != nil || !reflect.DeepEqual(opt, &gotOpt) {
+		t.Errorf("Expected %+v, got %+v, %v", opt, gotOpt, err)
+	}
+}
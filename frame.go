@@ -0,0 +1,198 @@
+package binary
+
+import (
+	"bytes"
+	"io"
+	"math"
+
+	"github.com/tinywasm/fmt"
+	"github.com/tinywasm/model"
+)
+
+// DefaultMaxFrameSize is the largest frame body ReadFrame and FrameReader accept.
+const DefaultMaxFrameSize = 16 << 20
+
+// ErrFrameTooLarge reports a frame whose length prefix exceeds the maximum frame size.
+var ErrFrameTooLarge = fmt.Err("binary", "frame too large")
+
+// WriteFrame writes v to w as one frame: the encoded length as a uvarint
+// followed by the encoding itself. Frames are written with several small
+// writes, so w should be buffered.
+func WriteFrame(w io.Writer, v model.Encodable) error {
+	n, err := Size(v)
+	if err != nil {
+		return err
+	}
+
+	bw := getWriter()
+	defer putWriter(bw)
+
+	bw.reset(w)
+	bw.writeUvarint(uint64(n))
+	v.EncodeFields(bw)
+	return bw.err
+}
+
+// AppendFrame appends v to dst as one frame, like WriteFrame.
+func AppendFrame(dst []byte, v model.Encodable) ([]byte, error) {
+	n, err := Size(v)
+	if err != nil {
+		return dst, err
+	}
+
+	bw := getWriter()
+	defer putWriter(bw)
+
+	bw.resetBuffer(dst, false)
+	bw.writeUvarint(uint64(n))
+	v.EncodeFields(bw)
+	out := bw.buf
+	bw.buf = nil
+	return out, bw.err
+}
+
+// ReadFrame reads exactly one frame from r and decodes it into v. It never
+// reads past the end of the frame, so it is safe to mix with other readers of
+// r. It returns io.EOF when r ends cleanly before a frame starts.
+// Bytes left in the frame after v is decoded are ignored, which lets older
+// readers accept frames carrying newly appended fields.
+func ReadFrame(r io.Reader, v model.Decodable) error {
+	if v == nil || v.IsNil() {
+		return fmt.Err("ReadFrame: output is nil")
+	}
+	rdr, ok := r.(genericReader)
+	if !ok {
+		rdr = &singleByteReader{r: r}
+	}
+	sr := &streamReader{genericReader: rdr}
+
+	n, err := readFrameLen(sr, DefaultMaxFrameSize)
+	if err != nil {
+		return err
+	}
+	body, err := readBody(sr, nil, n)
+	if err != nil {
+		return readError(err)
+	}
+	return Decode(body, v)
+}
+
+// FrameReader reads a sequence of frames from one input, reusing a single
+// buffer between frames. A *bytes.Buffer is read without copying; frames
+// written to it after NewFrameReader are seen, and read frames are consumed.
+type FrameReader struct {
+	r       reader
+	mem     *bytes.Buffer // input held in memory, or nil
+	buf     []byte
+	maxSize int
+	limits  Limits
+	err     error
+}
+
+// NewFrameReader returns a FrameReader reading from r.
+func NewFrameReader(r io.Reader) *FrameReader {
+	fr := &FrameReader{maxSize: DefaultMaxFrameSize, limits: DefaultLimits}
+	if b, ok := r.(*bytes.Buffer); ok {
+		fr.mem = b
+		fr.r = newStreamReader(b)
+	} else {
+		fr.r = newReader(r)
+	}
+	return fr
+}
+
+// SetMaxFrameSize replaces DefaultMaxFrameSize as the largest accepted frame body.
+func (fr *FrameReader) SetMaxFrameSize(n int) {
+	fr.maxSize = n
+}
+
+// SetLimits replaces the DefaultLimits applied by Decode.
+func (fr *FrameReader) SetLimits(l Limits) {
+	fr.limits = l
+}
+
+// Next returns the body of the next frame. The slice is only valid until the
+// following call, or for a *bytes.Buffer until the buffer is next written.
+// It returns io.EOF when the input ends cleanly between frames; after any
+// other error every later call returns that error.
+func (fr *FrameReader) Next() ([]byte, error) {
+	if fr.err != nil {
+		return nil, fr.err
+	}
+	n, err := readFrameLen(fr.r, fr.maxSize)
+	if err != nil {
+		if err != io.EOF {
+			fr.err = err
+		}
+		return nil, err
+	}
+
+	if fr.mem != nil {
+		if fr.mem.Len() < n {
+			fr.err = ErrTruncated
+			return nil, fr.err
+		}
+		return fr.mem.Next(n), nil
+	}
+
+	if sr, ok := fr.r.(*sliceReader); ok {
+		b, err := sr.Slice(n)
+		if err != nil {
+			fr.err = ErrTruncated
+			return nil, fr.err
+		}
+		return b, nil
+	}
+
+	b, err := readBody(fr.r, fr.buf, n)
+	if err != nil {
+		fr.err = readError(err)
+		return nil, fr.err
+	}
+	fr.buf = b
+	return b, nil
+}
+
+// Decode reads the next frame and decodes it into v.
+func (fr *FrameReader) Decode(v model.Decodable) error {
+	b, err := fr.Next()
+	if err != nil {
+		return err
+	}
+	return DecodeWithLimits(b, v, fr.limits)
+}
+
+// readFrameLen reads a frame length prefix, returning io.EOF only when the
+// input ends before the first byte of the prefix.
+func readFrameLen(r reader, maxSize int) (int, error) {
+	start := r.Offset()
+	n, err := r.ReadUvarint()
+	if err != nil {
+		if err == io.EOF && r.Offset() == start {
+			return 0, io.EOF
+		}
+		return 0, readError(err)
+	}
+	if n > math.MaxInt || (maxSize > 0 && n > uint64(maxSize)) {
+		return 0, ErrFrameTooLarge
+	}
+	if sr, ok := r.(*sliceReader); ok && n > uint64(sr.Len()) {
+		return 0, ErrTruncated
+	}
+	return int(n), nil
+}
+
+// singleByteReader adds io.ByteReader to a plain io.Reader without reading ahead.
+type singleByteReader struct {
+	r io.Reader
+	b [1]byte
+}
+
+func (s *singleByteReader) Read(p []byte) (int, error) {
+	return s.r.Read(p)
+}
+
+func (s *singleByteReader) ReadByte() (byte, error) {
+	_, err := io.ReadFull(s.r, s.b[:])
+	return s.b[0], err
+}
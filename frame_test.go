@@ -0,0 +1,162 @@
+package binary
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"reflect"
+	"testing"
+)
+
+func frameMessages() []*Message {
+	return []*Message{
+		{Topic: "a", ID: 1},
+		{Topic: "b", ID: 2, Payload: bytes.Repeat([]byte{7}, 300)},
+		{Topic: "c", ID: 3, Payload: []byte{1}},
+	}
+}
+
+func TestWriteReadFrame(t *testing.T) {
+	var buf bytes.Buffer
+	for _, m := range frameMessages() {
+		if err := WriteFrame(&buf, m); err != nil {
+			t.Fatalf("WriteFrame failed: %v", err)
+		}
+	}
+
+	appended := []byte(nil)
+	for _, m := range frameMessages() {
+		var err error
+		if appended, err = AppendFrame(appended, m); err != nil {
+			t.Fatalf("AppendFrame failed: %v", err)
+		}
+	}
+	assertEqualBytes(t, buf.Bytes(), appended)
+
+	// ReadFrame must not consume bytes of the following frame, even on
+	// readers without buffering.
+	in := &oneByteReader{content: buf.Bytes()}
+	for _, want := range frameMessages() {
+		got := &Message{}
+		if err := ReadFrame(in, got); err != nil {
+			t.Fatalf("ReadFrame failed: %v", err)
+		}
+		if !reflect.DeepEqual(want, got) {
+			t.Errorf("Expected %+v, got %+v", want, got)
+		}
+	}
+	if err := ReadFrame(in, &Message{}); err != io.EOF {
+		t.Errorf("Expected io.EOF, got %v", err)
+	}
+}
+
+func TestFrameReader(t *testing.T) {
+	var encoded []byte
+	for _, m := range frameMessages() {
+		encoded, _ = AppendFrame(encoded, m)
+	}
+
+	inputs := map[string]func() io.Reader{
+		"Slice":  func() io.Reader { return bytes.NewBuffer(encoded) },
+		"Stream": func() io.Reader { return &oneByteReader{content: encoded} },
+	}
+	for name, input := range inputs {
+		t.Run(name, func(t *testing.T) {
+			fr := NewFrameReader(input())
+			for _, want := range frameMessages() {
+				got := &Message{}
+				if err := fr.Decode(got); err != nil {
+					t.Fatalf("Decode failed: %v", err)
+				}
+				if !reflect.DeepEqual(want, got) {
+					t.Errorf("Expected %+v, got %+v", want, got)
+				}
+			}
+			if _, err := fr.Next(); err != io.EOF {
+				t.Errorf("Expected io.EOF, got %v", err)
+			}
+		})
+	}
+}
+
+func TestFrameReaderBufferInterleaved(t *testing.T) {
+	var buf bytes.Buffer
+	fr := NewFrameReader(&buf)
+	if _, err := fr.Next(); err != io.EOF {
+		t.Fatalf("Expected io.EOF on an empty buffer, got %v", err)
+	}
+	for _, want := range frameMessages() {
+		if err := WriteFrame(&buf, want); err != nil {
+			t.Fatal(err)
+		}
+		got := &Message{}
+		if err := fr.Decode(got); err != nil {
+			t.Fatalf("Decode failed: %v", err)
+		}
+		if !reflect.DeepEqual(want, got) {
+			t.Errorf("Expected %+v, got %+v", want, got)
+		}
+		if buf.Len() != 0 {
+			t.Errorf("Expected the frame to be consumed, %d bytes left", buf.Len())
+		}
+	}
+	if _, err := fr.Next(); err != io.EOF {
+		t.Errorf("Expected io.EOF, got %v", err)
+	}
+}
+
+func TestFrameErrors(t *testing.T) {
+	var encoded []byte
+	encoded, _ = AppendFrame(encoded, &Message{Topic: "only", Payload: []byte("body")})
+
+	t.Run("Truncated", func(t *testing.T) {
+		cut := encoded[:len(encoded)-1]
+		if err := ReadFrame(bytes.NewReader(cut), &Message{}); !errors.Is(err, ErrTruncated) {
+			t.Errorf("ReadFrame: expected ErrTruncated, got %v", err)
+		}
+		for _, in := range []io.Reader{bytes.NewBuffer(cut), &oneByteReader{content: cut}} {
+			fr := NewFrameReader(in)
+			if _, err := fr.Next(); !errors.Is(err, ErrTruncated) {
+				t.Errorf("FrameReader: expected ErrTruncated, got %v", err)
+			}
+			if _, err := fr.Next(); !errors.Is(err, ErrTruncated) {
+				t.Errorf("FrameReader: expected sticky ErrTruncated, got %v", err)
+			}
+		}
+	})
+
+	t.Run("TruncatedPrefix", func(t *testing.T) {
+		if err := ReadFrame(bytes.NewReader([]byte{0x80}), &Message{}); !errors.Is(err, ErrTruncated) {
+			t.Errorf("Expected ErrTruncated, got %v", err)
+		}
+		if _, err := NewFrameReader(bytes.NewBuffer([]byte{0x80})).Next(); !errors.Is(err, ErrTruncated) {
+			t.Errorf("Expected ErrTruncated, got %v", err)
+		}
+	})
+
+	t.Run("TooLarge", func(t *testing.T) {
+		fr := NewFrameReader(bytes.NewBuffer(encoded))
+		fr.SetMaxFrameSize(len(encoded) - 2)
+		if _, err := fr.Next(); err != ErrFrameTooLarge {
+			t.Errorf("Expected ErrFrameTooLarge, got %v", err)
+		}
+		huge := []byte{0xff, 0xff, 0xff, 0xff, 0x0f}
+		if err := ReadFrame(bytes.NewReader(huge), &Message{}); err != ErrFrameTooLarge {
+			t.Errorf("Expected ErrFrameTooLarge, got %v", err)
+		}
+	})
+
+	t.Run("TrailingBytesInFrame", func(t *testing.T) {
+		// A frame written by a newer sender with an extra field still decodes.
+		body, _ := AppendEncode(nil, &Message{Topic: "new"})
+		body = append(body, 0x2a)
+		frame := append([]byte{byte(len(body))}, body...)
+		got := &Message{}
+		if err := ReadFrame(bytes.NewReader(frame), got); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if got.Topic != "new" {
+			t.Errorf("Expected topic new, got %q", got.Topic)
+		}
+	})
+}
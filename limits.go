@@ -0,0 +1,118 @@
+package binary
+
+import "math"
+
+// Limits bounds the resources a single decode may consume, so that a
+// hostile peer cannot force huge allocations with a few bytes of input.
+// A zero field disables that particular check.
+type Limits struct {
+	MaxBytesLen   int   // longest String, Raw or Bytes value, in bytes
+	MaxArrayLen   int   // most elements announced by a single Array
+	MaxMapLen     int   // most entries announced by a single Map
+	MaxDepth      int   // deepest Object nesting below the top-level value
+	MaxTotalBytes int64 // most input bytes consumed by one value
+}
+
+// DefaultLimits are the limits enforced by Decode.
+var DefaultLimits = Limits{
+	MaxBytesLen:   16 << 20,
+	MaxArrayLen:   1 << 20,
+	MaxMapLen:     1 << 20,
+	MaxDepth:      64,
+	MaxTotalBytes: 64 << 20,
+}
+
+// setLimits installs l for the value starting at the current input offset.
+func (br *binaryReader) setLimits(l Limits) {
+	br.limits = l
+	br.depth = 0
+	br.end = 0
+	if l.MaxTotalBytes > 0 {
+		if sr, ok := br.r.(*sliceReader); ok && int64(sr.Len()) <= l.MaxTotalBytes {
+			return // the whole input fits in the budget
+		}
+		br.end = br.r.Offset() + l.MaxTotalBytes
+	}
+}
+
+// within reports whether n more bytes fit in the total byte budget.
+func (br *binaryReader) within(n uint64) bool {
+	if br.end == 0 {
+		return true
+	}
+	if left := br.end - br.r.Offset(); left >= 0 && n <= uint64(left) {
+		return true
+	}
+	br.fail(ErrLimitExceeded)
+	return false
+}
+
+// readUvarint reads a uvarint within the total byte budget. A uvarint takes
+// up to 10 bytes, so the budget is checked for its first byte before the read
+// and for the bytes actually consumed after it.
+func (br *binaryReader) readUvarint() (uint64, bool) {
+	if br.err != nil || !br.within(1) {
+		return 0, false
+	}
+	v, err := br.r.ReadUvarint()
+	if err != nil {
+		br.fail(err)
+		return 0, false
+	}
+	return v, br.within(0)
+}
+
+// checkLen validates a length prefix before anything is allocated for it.
+func (br *binaryReader) checkLen(l uint64) bool {
+	if l > math.MaxInt || (br.limits.MaxBytesLen > 0 && l > uint64(br.limits.MaxBytesLen)) {
+		br.fail(ErrLimitExceeded)
+		return false
+	}
+	if sr, ok := br.r.(*sliceReader); ok && l > uint64(sr.Len()) {
+		br.fail(ErrTruncated)
+		return false
+	}
+	return br.within(l)
+}
+
+// checkCount validates an Array element count. Every element takes at least
+// one byte, so a count larger than the remaining input is always truncated.
+func (br *binaryReader) checkCount(n uint64) bool {
+	if n > math.MaxInt || (br.limits.MaxArrayLen > 0 && n > uint64(br.limits.MaxArrayLen)) {
+		br.fail(ErrLimitExceeded)
+		return false
+	}
+	if sr, ok := br.r.(*sliceReader); ok && n > uint64(sr.Len()) {
+		br.fail(ErrTruncated)
+		return false
+	}
+	return br.within(n)
+}
+
+// checkMapLen validates a Map entry count. Every entry takes at least two
+// bytes, a key and a value.
+func (br *binaryReader) checkMapLen(n uint64) bool {
+	if n > math.MaxInt/2 || (br.limits.MaxMapLen > 0 && n > uint64(br.limits.MaxMapLen)) {
+		br.fail(ErrLimitExceeded)
+		return false
+	}
+	if sr, ok := br.r.(*sliceReader); ok && 2*n > uint64(sr.Len()) {
+		br.fail(ErrTruncated)
+		return false
+	}
+	return br.within(2 * n)
+}
+
+// enter descends into a nested Object, enforcing MaxDepth.
+func (br *binaryReader) enter() bool {
+	if br.limits.MaxDepth > 0 && br.depth >= br.limits.MaxDepth {
+		br.fail(ErrLimitExceeded)
+		return false
+	}
+	br.depth++
+	return true
+}
+
+func (br *binaryReader) leave() {
+	br.depth--
+}
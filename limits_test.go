@@ -0,0 +1,179 @@
+package binary
+
+import (
+	"bytes"
+	"errors"
+	"math"
+	"runtime"
+	"testing"
+
+	"github.com/tinywasm/model"
+)
+
+func TestDecodeLimits(t *testing.T) {
+	basic := &FixtureBasic{Name: "limits", Payload: []byte{1, 2, 3, 4}, Tags: []uint32{1, 2, 3}}
+	var encoded []byte
+	if err := Encode(basic, &encoded); err != nil {
+		t.Fatalf("Encode failed: %v", err)
+	}
+
+	cases := []struct {
+		name   string
+		limits Limits
+		err    error
+	}{
+		{"Unlimited", Limits{}, nil},
+		{"Defaults", DefaultLimits, nil},
+		{"MaxBytesLen", Limits{MaxBytesLen: 3}, ErrLimitExceeded},
+		{"MaxArrayLen", Limits{MaxArrayLen: 2}, ErrLimitExceeded},
+		{"MaxTotalBytes", Limits{MaxTotalBytes: int64(len(encoded) - 1)}, ErrLimitExceeded},
+		{"MaxTotalBytesExact", Limits{MaxTotalBytes: int64(len(encoded))}, nil},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := DecodeWithLimits(encoded, &FixtureBasic{}, tc.limits)
+			if !errors.Is(err, tc.err) && err != tc.err {
+				t.Errorf("slice: expected %v, got %v", tc.err, err)
+			}
+			err = DecodeWithLimits(&oneByteReader{content: encoded}, &FixtureBasic{}, tc.limits)
+			if !errors.Is(err, tc.err) && err != tc.err {
+				t.Errorf("stream: expected %v, got %v", tc.err, err)
+			}
+		})
+	}
+}
+
+func TestDecodeMaxDepth(t *testing.T) {
+	v := &FixtureComplex{Secondary: &FixtureBasic{Name: "nested"}}
+	var encoded []byte
+	if err := Encode(v, &encoded); err != nil {
+		t.Fatalf("Encode failed: %v", err)
+	}
+	if err := DecodeWithLimits(encoded, &FixtureComplex{}, Limits{MaxDepth: 1}); err != nil {
+		t.Errorf("Unexpected error at depth 1: %v", err)
+	}
+	err := DecodeWithLimits(encoded, &FixtureComplex{}, Limits{MaxDepth: -1})
+	if err != nil {
+		t.Errorf("Negative MaxDepth should disable the check, got %v", err)
+	}
+
+	deep := &nestedChain{}
+	cur := deep
+	for i := 0; i < 10; i++ {
+		cur.Next = &nestedChain{}
+		cur = cur.Next
+	}
+	if err := Encode(deep, &encoded); err != nil {
+		t.Fatalf("Encode failed: %v", err)
+	}
+	if err := DecodeWithLimits(encoded, &nestedChain{}, Limits{MaxDepth: 10}); err != nil {
+		t.Errorf("Unexpected error at depth 10: %v", err)
+	}
+	if err := DecodeWithLimits(encoded, &nestedChain{}, Limits{MaxDepth: 9}); !errors.Is(err, ErrLimitExceeded) {
+		t.Errorf("Expected ErrLimitExceeded at depth 9, got %v", err)
+	}
+}
+
+func TestDecodeHostileLengths(t *testing.T) {
+	// A 10-byte length prefix announcing ~2^63 bytes must fail without allocating.
+	huge := []byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x7f}
+	if err := Decode(huge, &sliceStruct{}); !errors.Is(err, ErrLimitExceeded) {
+		t.Errorf("Expected ErrLimitExceeded, got %v", err)
+	}
+	if err := DecodeWithLimits(huge, &sliceStruct{}, Limits{}); !errors.Is(err, ErrTruncated) {
+		t.Errorf("Expected ErrTruncated without limits, got %v", err)
+	}
+	if err := DecodeWithLimits(bytes.NewReader(huge), &sliceStruct{}, Limits{MaxTotalBytes: 1 << 10}); !errors.Is(err, ErrLimitExceeded) {
+		t.Errorf("Expected ErrLimitExceeded from stream, got %v", err)
+	}
+
+	// An array count larger than the remaining input is rejected up front.
+	if err := Decode([]byte{0x00, 0x00, 0x00, 0xff, 0x01}, &FixtureBasic{}); !errors.Is(err, ErrTruncated) {
+		t.Errorf("Expected ErrTruncated for oversized array, got %v", err)
+	}
+}
+
+// intField holds a single Int field.
+type intField struct{ V int64 }
+
+func (f *intField) IsNil() bool                      { return f == nil }
+func (f *intField) EncodeFields(w model.FieldWriter) { w.Int("V", f.V) }
+func (f *intField) DecodeFields(r model.FieldReader) { f.V, _ = r.Int("V") }
+
+func TestDecodeLimitsVarint(t *testing.T) {
+	var data []byte
+	if err := Encode(&intField{V: math.MinInt64}, &data); err != nil {
+		t.Fatal(err)
+	}
+	for _, limit := range []int64{1, int64(len(data) - 1)} {
+		if err := DecodeWithLimits(data, &intField{}, Limits{MaxTotalBytes: limit}); !errors.Is(err, ErrLimitExceeded) {
+			t.Errorf("Limit %d: expected ErrLimitExceeded for a %d-byte varint, got %v", limit, len(data), err)
+		}
+	}
+	out := &intField{}
+	if err := DecodeWithLimits(data, out, Limits{MaxTotalBytes: int64(len(data))}); err != nil || out.V != math.MinInt64 {
+		t.Errorf("Expected %d, got %d, %v", int64(math.MinInt64), out.V, err)
+	}
+}
+
+func TestDecodeStreamBodyGrowsWithInput(t *testing.T) {
+	// A stream announcing 16 MiB but holding 5 bytes must not allocate the
+	// announced length.
+	short := []byte{0x80, 0x80, 0x80, 0x08, 1, 2, 3, 4, 5} // length 16 MiB
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	err := Decode(bytes.NewReader(short), &sliceStruct{})
+	runtime.ReadMemStats(&after)
+	if !errors.Is(err, ErrTruncated) {
+		t.Errorf("Expected ErrTruncated, got %v", err)
+	}
+	if n := after.TotalAlloc - before.TotalAlloc; n > 1<<20 {
+		t.Errorf("Expected a bounded allocation, got %d bytes", n)
+	}
+
+	// Bodies larger than one chunk still read in full.
+	payload := make([]byte, 3*readChunk+7)
+	for i := range payload {
+		payload[i] = byte(i)
+	}
+	var encoded []byte
+	if err := Encode(&sliceStruct{Payload: payload}, &encoded); err != nil {
+		t.Fatal(err)
+	}
+	out := &sliceStruct{}
+	if err := Decode(bytes.NewReader(encoded), out); err != nil {
+		t.Fatalf("Decode failed: %v", err)
+	}
+	assertEqualBytes(t, payload, out.Payload)
+	if err := Decode(bytes.NewReader(encoded[:len(encoded)-1]), out); !errors.Is(err, ErrTruncated) {
+		t.Errorf("Expected ErrTruncated, got %v", err)
+	}
+}
+
+func TestDecodeBytesDoNotAliasInput(t *testing.T) {
+	encoded := []byte{0x02, 0xaa, 0xbb}
+	s := &sliceStruct{}
+	if err := Decode(encoded, s); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	encoded[1] = 0x00
+	assertEqualBytes(t, []byte{0xaa, 0xbb}, s.Payload)
+}
+
+// nestedChain is a linked list of Objects used to exercise MaxDepth.
+type nestedChain struct {
+	Next *nestedChain
+}
+
+func (n *nestedChain) IsNil() bool { return n == nil }
+
+func (n *nestedChain) EncodeFields(w model.FieldWriter) {
+	w.Object("Next", n.Next)
+}
+
+func (n *nestedChain) DecodeFields(r model.FieldReader) {
+	n.Next = &nestedChain{}
+	if !r.Object("Next", n.Next) {
+		n.Next = nil
+	}
+}
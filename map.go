@@ -0,0 +1,205 @@
+package binary
+
+import (
+	"cmp"
+	"slices"
+
+	"github.com/tinywasm/model"
+)
+
+// MapWriter is implemented by writers that can write maps. Map starts a map
+// of n entries and returns an ArrayWriter taking each key followed by its
+// value, 2n calls in all. Keys are written with String or Int as declared by
+// key, values with the method for value (KindString, KindInt, KindFloat,
+// KindBool, KindBytes or KindObject). Callers write the entries sorted by
+// their encoded key so the encoding is deterministic; WriteMap and
+// WriteObjectMap do.
+//
+// In the positional format a map is uvarint(n) followed by the entries.
+type MapWriter interface {
+	Map(name string, n int, key, value Kind) model.ArrayWriter
+}
+
+// MapReader is the reading side of MapWriter. Map returns the entries as an
+// ArrayReader of 2n elements, keys at even and values at odd indexes.
+type MapReader interface {
+	Map(name string) (model.ArrayReader, bool)
+}
+
+// MapKey lists the key types WriteMap and ReadMap accept. Integer keys are
+// written with Int.
+type MapKey interface {
+	string |
+		int | int8 | int16 | int32 | int64 |
+		uint | uint8 | uint16 | uint32 | uint64
+}
+
+// WriteMap writes m as the map field name, with entries sorted by key.
+// Writers that do not implement MapWriter get an Array of 2n elements.
+//
+//	binary.WriteMap(w, "Labels", x.Labels) // map[string]string
+func WriteMap[K MapKey, V Primitive](w model.FieldWriter, name string, m map[K]V) {
+	aw := startMap(w, name, len(m), primitiveKind[K](), primitiveKind[V]())
+	for _, k := range sortedKeys(m) {
+		writePrimitive(elemWriter{aw}, "", k)
+		writePrimitive(elemWriter{aw}, "", m[k])
+	}
+}
+
+// ReadMap reads a map written by WriteMap. An empty map decodes as nil.
+//
+//	if v, ok := binary.ReadMap[string, string](r, "Labels"); ok {
+//		x.Labels = v
+//	}
+func ReadMap[K MapKey, V Primitive](r model.FieldReader, name string) (map[K]V, bool) {
+	ar, ok := readMap(r, name)
+	if !ok {
+		return nil, false
+	}
+	n := ar.Len() / 2
+	if n == 0 {
+		return nil, true
+	}
+	m := make(map[K]V, n)
+	for i := 0; i < n; i++ {
+		var k K
+		var v V
+		readPrimitive(elemReader{ar, 2 * i}, "", &k)
+		readPrimitive(elemReader{ar, 2*i + 1}, "", &v)
+		m[k] = v
+	}
+	return m, true
+}
+
+// WriteObjectMap writes m as the map field name with Object values, sorted
+// by key. Nil values are written as null.
+func WriteObjectMap[K MapKey, V model.Encodable](w model.FieldWriter, name string, m map[K]V) {
+	aw := startMap(w, name, len(m), primitiveKind[K](), KindObject)
+	for _, k := range sortedKeys(m) {
+		writePrimitive(elemWriter{aw}, "", k)
+		aw.Object(m[k])
+	}
+}
+
+// ReadObjectMap reads a map written by WriteObjectMap into a map of
+// pointers; null values decode as nil. An empty map decodes as nil.
+//
+//	if v, ok := binary.ReadObjectMap[string, User](r, "Users"); ok {
+//		x.Users = v // map[string]*User
+//	}
+func ReadObjectMap[K MapKey, V any, PV interface {
+	*V
+	model.Decodable
+}](r model.FieldReader, name string) (map[K]PV, bool) {
+	ar, ok := readMap(r, name)
+	if !ok {
+		return nil, false
+	}
+	n := ar.Len() / 2
+	if n == 0 {
+		return nil, true
+	}
+	m := make(map[K]PV, n)
+	for i := 0; i < n; i++ {
+		var k K
+		readPrimitive(elemReader{ar, 2 * i}, "", &k)
+		v := PV(new(V))
+		if !ar.Object(2*i+1, v) {
+			v = nil
+		}
+		m[k] = v
+	}
+	return m, true
+}
+
+// sortedKeys returns the keys of m in the order of their encoding: strings
+// bytewise and integers by their Int value, so that readers converting from
+// other formats, such as binaryjson.FromJSON, produce the same bytes. uint64
+// keys above math.MaxInt64 therefore come first.
+func sortedKeys[K MapKey, V any](m map[K]V) []K {
+	keys := make([]encodedKey[K], 0, len(m))
+	for k := range m {
+		e := encodedKey[K]{k: k}
+		writePrimitive(&e, "", k)
+		keys = append(keys, e)
+	}
+	slices.SortFunc(keys, func(a, b encodedKey[K]) int {
+		if c := cmp.Compare(a.s, b.s); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.i, b.i)
+	})
+	out := make([]K, len(keys))
+	for i, e := range keys {
+		out[i] = e.k
+	}
+	return out
+}
+
+// encodedKey is a scalarWriter capturing the encoded value of a map key.
+type encodedKey[K MapKey] struct {
+	k K
+	s string
+	i int64
+}
+
+func (e *encodedKey[K]) String(_, val string)        { e.s = val }
+func (e *encodedKey[K]) Bytes(_ string, val []byte)  {}
+func (e *encodedKey[K]) Bool(_ string, val bool)     {}
+func (e *encodedKey[K]) Int(_ string, val int64)     { e.i = val }
+func (e *encodedKey[K]) Float(_ string, val float64) {}
+
+// startMap starts a map on w, falling back to an Array of 2n elements.
+func startMap(w model.FieldWriter, name string, n int, key, value Kind) model.ArrayWriter {
+	if mw, ok := w.(MapWriter); ok {
+		return mw.Map(name, n, key, value)
+	}
+	return w.Array(name, 2*n)
+}
+
+// readMap is the reading side of startMap.
+func readMap(r model.FieldReader, name string) (model.ArrayReader, bool) {
+	if mr, ok := r.(MapReader); ok {
+		return mr.Map(name)
+	}
+	return r.Array(name)
+}
+
+// primitiveKind returns the Kind a Primitive is written with.
+func primitiveKind[T Primitive]() Kind {
+	var zero T
+	switch any(zero).(type) {
+	case string:
+		return KindString
+	case []byte:
+		return KindBytes
+	case bool:
+		return KindBool
+	case float32, float64:
+		return KindFloat
+	}
+	return KindInt
+}
+
+// elemWriter writes Primitives as array elements.
+type elemWriter struct {
+	aw model.ArrayWriter
+}
+
+func (e elemWriter) String(_, val string)        { e.aw.String(val) }
+func (e elemWriter) Bytes(_ string, val []byte)  { e.aw.Bytes(val) }
+func (e elemWriter) Bool(_ string, val bool)     { e.aw.Bool(val) }
+func (e elemWriter) Int(_ string, val int64)     { e.aw.Int(val) }
+func (e elemWriter) Float(_ string, val float64) { e.aw.Float(val) }
+
+// elemReader reads Primitives from array element i.
+type elemReader struct {
+	ar model.ArrayReader
+	i  int
+}
+
+func (e elemReader) String(string) (string, bool) { return e.ar.String(e.i), true }
+func (e elemReader) Bytes(string) ([]byte, bool)  { return e.ar.Bytes(e.i), true }
+func (e elemReader) Bool(string) (bool, bool)     { return e.ar.Bool(e.i), true }
+func (e elemReader) Int(string) (int64, bool)     { return e.ar.Int(e.i), true }
+func (e elemReader) Float(string) (float64, bool) { return e.ar.Float(e.i), true }
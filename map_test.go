@@ -0,0 +1,230 @@
+package binary
+
+import (
+	"bytes"
+	"math"
+	"math/rand"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/tinywasm/model"
+)
+
+// FixtureMaps has a map for every key kind, with primitive and object values.
+type FixtureMaps struct {
+	Labels   map[string]string
+	Counters map[int64]int64
+	Flags    map[uint16]bool
+	Weights  map[int8]float64
+	Blobs    map[uint64][]byte
+	Names    map[int]string
+	Users    map[string]*FixtureBasic
+}
+
+func (f *FixtureMaps) EncodeFields(w model.FieldWriter) {
+	WriteMap(w, "Labels", f.Labels)
+	WriteMap(w, "Counters", f.Counters)
+	WriteMap(w, "Flags", f.Flags)
+	WriteMap(w, "Weights", f.Weights)
+	WriteMap(w, "Blobs", f.Blobs)
+	WriteMap(w, "Names", f.Names)
+	WriteObjectMap(w, "Users", f.Users)
+}
+
+func (f *FixtureMaps) DecodeFields(r model.FieldReader) {
+	if v, ok := ReadMap[string, string](r, "Labels"); ok {
+		f.Labels = v
+	}
+	if v, ok := ReadMap[int64, int64](r, "Counters"); ok {
+		f.Counters = v
+	}
+	if v, ok := ReadMap[uint16, bool](r, "Flags"); ok {
+		f.Flags = v
+	}
+	if v, ok := ReadMap[int8, float64](r, "Weights"); ok {
+		f.Weights = v
+	}
+	if v, ok := ReadMap[uint64, []byte](r, "Blobs"); ok {
+		f.Blobs = v
+	}
+	if v, ok := ReadMap[int, string](r, "Names"); ok {
+		f.Names = v
+	}
+	if v, ok := ReadObjectMap[string, FixtureBasic](r, "Users"); ok {
+		f.Users = v
+	}
+}
+
+func (f *FixtureMaps) IsNil() bool {
+	return f == nil
+}
+
+func mapsValue() *FixtureMaps {
+	return &FixtureMaps{
+		Labels:   map[string]string{"env": "prod", "app": "api", "": "empty", "zone": ""},
+		Counters: map[int64]int64{-5: 1, 0: 2, 1 << 40: -3},
+		Flags:    map[uint16]bool{8080: true, 22: false},
+		Weights:  map[int8]float64{-128: 0.5, 127: -1},
+		Blobs:    map[uint64][]byte{1<<64 - 1: {1, 2}, 0: {3}},
+		Names:    map[int]string{3: "c", 1: "a", 2: "b"},
+		Users:    map[string]*FixtureBasic{"bob": {Name: "Bob", Tags: []uint32{1}}, "nil": nil, "al": {Count: 2, Tags: []uint32{5}}},
+	}
+}
+
+func TestMapRoundTrip(t *testing.T) {
+	in := mapsValue()
+	var data []byte
+	if err := Encode(in, &data); err != nil {
+		t.Fatalf("Encode failed: %v", err)
+	}
+	if n, err := Size(in); err != nil || n != len(data) {
+		t.Errorf("Expected size %d, got %d, %v", len(data), n, err)
+	}
+
+	decoders := map[string]func(in *FixtureMaps, out *FixtureMaps) error{
+		"positional": func(in, out *FixtureMaps) error { return Decode(data, out) },
+		"tagged": func(in, out *FixtureMaps) error {
+			var b []byte
+			if err := EncodeTagged(in, &b); err != nil {
+				return err
+			}
+			return DecodeTagged(b, out)
+		},
+		"sparse": func(in, out *FixtureMaps) error {
+			var b []byte
+			if err := EncodeSparse(in, &b); err != nil {
+				return err
+			}
+			_, err := DecodeSparse(b, out)
+			return err
+		},
+	}
+	for name, decode := range decoders {
+		out := &FixtureMaps{}
+		if err := decode(in, out); err != nil {
+			t.Fatalf("%s: decode failed: %v", name, err)
+		}
+		if !reflect.DeepEqual(in, out) {
+			t.Errorf("%s: expected %+v, got %+v", name, in, out)
+		}
+	}
+
+	// Empty and nil maps encode the same and decode as nil.
+	var empty, zero []byte
+	if err := Encode(&FixtureMaps{Labels: map[string]string{}}, &empty); err != nil {
+		t.Fatal(err)
+	}
+	if err := Encode(&FixtureMaps{}, &zero); err != nil {
+		t.Fatal(err)
+	}
+	out := &FixtureMaps{Labels: map[string]string{"stale": "x"}}
+	if !bytes.Equal(empty, zero) || Decode(empty, out) != nil || out.Labels != nil {
+		t.Errorf("Expected empty maps to round-trip as nil, got %x, %x, %v", empty, zero, out.Labels)
+	}
+}
+
+func TestMapDeterministic(t *testing.T) {
+	var first []byte
+	if err := Encode(mapsValue(), &first); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 20; i++ {
+		var again []byte
+		if err := Encode(mapsValue(), &again); err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(first, again) {
+			t.Fatalf("Encoding changed between runs:\n%x\n%x", first, again)
+		}
+	}
+
+	// Entries are sorted by key: numerically for integers.
+	var data []byte
+	if err := Encode(&FixtureMaps{Names: map[int]string{10: "j", -1: "z", 2: "b"}}, &data); err != nil {
+		t.Fatal(err)
+	}
+	want := []byte{0, 0, 0, 0, 0, 3, 1, 1, 'z', 4, 1, 'b', 20, 1, 'j', 0}
+	if !bytes.Equal(want, data) {
+		t.Errorf("Expected %x, got %x", want, data)
+	}
+	if got := Sprint(&FixtureMaps{Names: map[int]string{2: "b", 1: "a"}, Labels: map[string]string{"k": "v"}}); !strings.Contains(got, `Labels: {"k": "v"}`) || !strings.Contains(got, `Names: {1: "a", 2: "b"}`) {
+		t.Errorf("Unexpected text: %s", got)
+	}
+}
+
+func TestMapSortedKeys(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	uints := map[uint64]bool{0: true, math.MaxInt64: true, math.MaxInt64 + 1: true, math.MaxUint64: true}
+	for len(uints) < 2000 {
+		uints[r.Uint64()>>r.Intn(64)] = true
+	}
+	keys := sortedKeys(uints)
+	if len(keys) != len(uints) {
+		t.Fatalf("Expected %d keys, got %d", len(uints), len(keys))
+	}
+	for i := 1; i < len(keys); i++ {
+		if int64(keys[i-1]) >= int64(keys[i]) {
+			t.Fatalf("Keys %d and %d out of order", keys[i-1], keys[i])
+		}
+	}
+	if keys[0] != math.MaxInt64+1 || keys[len(keys)-1] != math.MaxInt64 {
+		t.Errorf("Expected keys above math.MaxInt64 first, got %d .. %d", keys[0], keys[len(keys)-1])
+	}
+
+	// Strings over a three-byte alphabet share prefixes and are drawn repeatedly.
+	strs := map[string]int{}
+	for len(strs) < 2000 {
+		b := make([]byte, r.Intn(10))
+		for i := range b {
+			b[i] = "ab\xff"[r.Intn(3)]
+		}
+		strs[string(b)]++
+	}
+	skeys := sortedKeys(strs)
+	if len(skeys) != len(strs) {
+		t.Fatalf("Expected %d keys, got %d", len(strs), len(skeys))
+	}
+	for i := 1; i < len(skeys); i++ {
+		if skeys[i-1] >= skeys[i] {
+			t.Fatalf("Keys %q and %q out of order", skeys[i-1], skeys[i])
+		}
+	}
+}
+
+func TestMapLimits(t *testing.T) {
+	var data []byte
+	if err := Encode(&FixtureMaps{Labels: map[string]string{"a": "1", "b": "2", "c": "3"}}, &data); err != nil {
+		t.Fatal(err)
+	}
+	limits := DefaultLimits
+	limits.MaxMapLen = 2
+	if err := DecodeWithLimits(data, &FixtureMaps{}, limits); err != ErrLimitExceeded {
+		t.Errorf("Expected ErrLimitExceeded, got %v", err)
+	}
+	if err := Decode([]byte{0x80, 0x80, 0x04}, &FixtureMaps{}); err != ErrTruncated {
+		t.Errorf("Expected ErrTruncated for a count larger than the input, got %v", err)
+	}
+}
+
+func TestMapSchema(t *testing.T) {
+	in := mapsValue()
+	s := SchemaOf(in)
+	if f, _ := s.Field("Counters"); f.Kind != KindMap || f.Key != KindInt || f.Elem != KindInt {
+		t.Errorf("Unexpected Counters field %+v", f)
+	}
+	if f, _ := s.Field("Users"); f.Key != KindString || f.Elem != KindObject || f.Schema == nil {
+		t.Errorf("Unexpected Users field %+v", f)
+	}
+
+	// The schema itself round-trips with the key kind.
+	var enc []byte
+	if err := Encode(s, &enc); err != nil {
+		t.Fatal(err)
+	}
+	decoded := &Schema{}
+	if err := Decode(enc, decoded); err != nil || !reflect.DeepEqual(s, decoded) {
+		t.Errorf("Expected schema %+v, got %+v, %v", s, decoded, err)
+	}
+
+}
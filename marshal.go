@@ -0,0 +1,104 @@
+package binary
+
+import (
+	"encoding"
+
+	"github.com/tinywasm/fmt"
+	"github.com/tinywasm/model"
+)
+
+// Marshaler gives an Encodable and Decodable value the
+// encoding.BinaryMarshaler and encoding.BinaryUnmarshaler methods, so it can
+// be stored by caches, KV stores and encoding/gob:
+//
+//	cache.Set(key, binary.Marshaler[*User]{V: u})
+//
+// T is normally a pointer type. V must be set before UnmarshalBinary, which
+// decodes into it.
+type Marshaler[T interface {
+	model.Encodable
+	model.Decodable
+}] struct {
+	V T
+}
+
+// MarshalBinary implements encoding.BinaryMarshaler
+func (m Marshaler[T]) MarshalBinary() ([]byte, error) {
+	var out []byte
+	err := Encode(m.V, &out)
+	return out, err
+}
+
+// UnmarshalBinary implements encoding.BinaryUnmarshaler. Decoded byte slices
+// are copies, so data may be reused afterwards.
+func (m *Marshaler[T]) UnmarshalBinary(data []byte) error {
+	if any(m.V) == nil || m.V.IsNil() {
+		return fmt.Err("UnmarshalBinary: Marshaler.V is nil")
+	}
+	return Decode(data, m.V)
+}
+
+// failer is implemented by the writers and readers of this package, which
+// report an error raised inside EncodeFields or DecodeFields from Encode and
+// Decode.
+type failer interface {
+	fail(err error)
+}
+
+// WriteBinary writes v.MarshalBinary() as the Bytes field name. It lets a
+// type that only implements encoding.BinaryMarshaler, such as time.Time,
+// appear inside EncodeFields. A MarshalBinary error is returned by Encode;
+// writers that cannot report errors get an empty field instead.
+func WriteBinary(w model.FieldWriter, name string, v encoding.BinaryMarshaler) {
+	b, err := v.MarshalBinary()
+	if err != nil {
+		if f, ok := w.(failer); ok {
+			f.fail(err)
+		}
+		b = nil
+	}
+	w.Bytes(name, b)
+}
+
+// ReadBinary reads the Bytes field name into v.UnmarshalBinary, the
+// counterpart of WriteBinary inside DecodeFields. It reports whether the
+// field was present and decoded; an UnmarshalBinary error is returned by
+// Decode.
+func ReadBinary(r model.FieldReader, name string, v encoding.BinaryUnmarshaler) bool {
+	b, ok := r.Bytes(name)
+	if !ok {
+		return false
+	}
+	if err := v.UnmarshalBinary(b); err != nil {
+		if f, ok := r.(failer); ok {
+			f.fail(err)
+		}
+		return false
+	}
+	return true
+}
+
+// Marshal encodes v and returns the bytes, a shorthand for Encode into a
+// new slice.
+func Marshal[T model.Encodable](v T) ([]byte, error) {
+	var out []byte
+	if err := Encode(v, &out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// Unmarshal decodes b into a new T, where *T implements model.Decodable:
+//
+//	u, err := binary.Unmarshal[User](data)
+func Unmarshal[T any, PT interface {
+	*T
+	model.Decodable
+}](b []byte) (T, error) {
+	var v T
+	if err := Decode(b, PT(&v)); err != nil {
+		var zero T
+		return zero, err
+	}
+	return v, nil
+}
@@ -0,0 +1,178 @@
+package binary
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/gob"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/tinywasm/model"
+)
+
+var (
+	_ encoding.BinaryMarshaler   = Marshaler[*FixtureBasic]{}
+	_ encoding.BinaryUnmarshaler = &Marshaler[*FixtureBasic]{}
+)
+
+// event holds a type that only implements encoding.BinaryMarshaler.
+type event struct {
+	Name string
+	At   time.Time
+	Tags []string
+}
+
+func (e *event) IsNil() bool { return e == nil }
+
+func (e *event) EncodeFields(w model.FieldWriter) {
+	w.String("Name", e.Name)
+	WriteBinary(w, "At", e.At)
+	aw := w.Array("Tags", len(e.Tags))
+	for _, t := range e.Tags {
+		aw.String(t)
+	}
+}
+
+func (e *event) DecodeFields(r model.FieldReader) {
+	if v, ok := r.String("Name"); ok {
+		e.Name = v
+	}
+	ReadBinary(r, "At", &e.At)
+	if ar, ok := r.Array("Tags"); ok {
+		e.Tags = make([]string, ar.Len())
+		for i := range e.Tags {
+			e.Tags[i] = ar.String(i)
+		}
+	}
+}
+
+func TestMarshalerRoundTrip(t *testing.T) {
+	in := Marshaler[*FixtureComplex]{V: complexSchemaValue()}
+	in.V.Primary.Name = "Alice"
+	data, err := in.MarshalBinary()
+	if err != nil {
+		t.Fatalf("MarshalBinary failed: %v", err)
+	}
+	var direct []byte
+	if err := Encode(in.V, &direct); err != nil || !bytes.Equal(data, direct) {
+		t.Errorf("Expected MarshalBinary to match Encode, got %x vs %x (%v)", data, direct, err)
+	}
+
+	out := Marshaler[*FixtureComplex]{V: &FixtureComplex{}}
+	if err := out.UnmarshalBinary(data); err != nil {
+		t.Fatalf("UnmarshalBinary failed: %v", err)
+	}
+	if !reflect.DeepEqual(in.V, out.V) {
+		t.Errorf("Expected %+v, got %+v", in.V, out.V)
+	}
+
+	var empty Marshaler[*FixtureComplex]
+	if err := empty.UnmarshalBinary(data); err == nil {
+		t.Error("Expected error for nil V")
+	}
+}
+
+// Encodable inside a BinaryMarshaler world: gob stores the field through
+// MarshalBinary.
+func TestMarshalerInsideGob(t *testing.T) {
+	type envelope struct {
+		Key   string
+		Value Marshaler[*event]
+	}
+	at := time.Date(2024, 5, 6, 7, 8, 9, 10, time.UTC)
+	in := envelope{Key: "k", Value: Marshaler[*event]{V: &event{Name: "deploy", At: at, Tags: []string{"a"}}}}
+
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(in); err != nil {
+		t.Fatalf("gob encode failed: %v", err)
+	}
+	out := envelope{Value: Marshaler[*event]{V: &event{}}}
+	if err := gob.NewDecoder(&buf).Decode(&out); err != nil {
+		t.Fatalf("gob decode failed: %v", err)
+	}
+	if out.Key != "k" || out.Value.V.Name != "deploy" || !out.Value.V.At.Equal(at) || len(out.Value.V.Tags) != 1 {
+		t.Errorf("Unexpected result: %+v %+v", out, out.Value.V)
+	}
+}
+
+// BinaryMarshaler inside an Encodable: time.Time travels as a Bytes field.
+func TestWriteBinaryInsideEncodable(t *testing.T) {
+	at := time.Date(2024, 1, 2, 3, 4, 5, 6, time.FixedZone("X", 3600))
+	in := &event{Name: "login", At: at}
+	var data []byte
+	if err := Encode(in, &data); err != nil {
+		t.Fatalf("Encode failed: %v", err)
+	}
+	out := &event{}
+	if err := Decode(data, out); err != nil {
+		t.Fatalf("Decode failed: %v", err)
+	}
+	if out.Name != "login" || !out.At.Equal(at) {
+		t.Errorf("Expected %v, got %+v", at, out)
+	}
+	if f, _ := SchemaOf(in).Field("At"); f.Kind != KindBytes {
+		t.Errorf("Expected At to be a Bytes field, got %v", f.Kind)
+	}
+}
+
+type failingMarshaler struct{}
+
+func (failingMarshaler) MarshalBinary() ([]byte, error) { return nil, errors.New("marshal failed") }
+func (*failingMarshaler) UnmarshalBinary([]byte) error  { return errors.New("unmarshal failed") }
+
+type failingHolder struct{ After string }
+
+func (h *failingHolder) IsNil() bool { return h == nil }
+
+func (h *failingHolder) EncodeFields(w model.FieldWriter) {
+	WriteBinary(w, "Bad", failingMarshaler{})
+	w.String("After", h.After)
+}
+
+func (h *failingHolder) DecodeFields(r model.FieldReader) {
+	ReadBinary(r, "Bad", &failingMarshaler{})
+	if v, ok := r.String("After"); ok {
+		h.After = v
+	}
+}
+
+func TestBinaryAdapterErrors(t *testing.T) {
+	var data []byte
+	if err := Encode(&failingHolder{}, &data); err == nil || err.Error() != "marshal failed" {
+		t.Errorf("Expected marshal error, got %v", err)
+	}
+
+	// Writers without error reporting still keep the layout.
+	if n, err := Size(&failingHolder{After: "x"}); err != nil || n != 3 {
+		t.Errorf("Expected size 3, got %d, %v", n, err)
+	}
+
+	if err := Decode([]byte{0, 1, 'x'}, &failingHolder{}); err == nil || err.Error() != "unmarshal failed" {
+		t.Errorf("Expected unmarshal error, got %v", err)
+	}
+}
+
+func TestMarshalUnmarshal(t *testing.T) {
+	in := complexSchemaValue()
+	in.ID = 7
+	data, err := Marshal(in)
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	out, err := Unmarshal[FixtureComplex](data)
+	if err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	if !reflect.DeepEqual(*in, out) {
+		t.Errorf("Expected %+v, got %+v", in, out)
+	}
+
+	if _, err := Marshal[*FixtureComplex](nil); err == nil {
+		t.Error("Expected error for nil input")
+	}
+	if out, err := Unmarshal[FixtureComplex](data[:2]); err == nil || !reflect.DeepEqual(out, FixtureComplex{}) {
+		t.Errorf("Expected error and zero value, got %+v, %v", out, err)
+	}
+}
@@ -0,0 +1,121 @@
+package binary
+
+import (
+	"github.com/tinywasm/fmt"
+	"github.com/tinywasm/model"
+)
+
+// ErrUnknownField reports a Mask path naming a field that the value being
+// encoded does not write.
+var ErrUnknownField = fmt.Err("binary", "mask path matches no field")
+
+// Mask is a list of dotted field paths, such as "Name", "Primary.Score" or
+// "List[].Name". Selecting an object selects all of its fields; "[]" marks
+// the elements of an array and may be left out.
+type Mask []string
+
+// Has reports whether m lists path.
+func (m Mask) Has(path string) bool {
+	path = fmt.Convert(path).Replace("[]", "").String()
+	for _, p := range m {
+		if fmt.Convert(p).Replace("[]", "").String() == path {
+			return true
+		}
+	}
+	return false
+}
+
+// tree builds the maskNode for m; an empty mask selects every field.
+func (m Mask) tree() *maskNode {
+	if len(m) == 0 {
+		return nil
+	}
+	root := &maskNode{}
+	for _, p := range m {
+		n := root
+		segs := fmt.Split(fmt.Convert(p).Replace("[]", "").String(), ".")
+		for i, seg := range segs {
+			child, ok := n.fields[seg]
+			if ok && child == nil {
+				break // the whole field is already selected
+			}
+			if n.fields == nil {
+				n.fields = make(map[string]*maskNode)
+			}
+			if i == len(segs)-1 {
+				n.fields[seg] = nil
+				break
+			}
+			if child == nil {
+				child = &maskNode{path: n.path + seg + "."}
+				n.fields[seg] = child
+			}
+			n = child
+		}
+	}
+	return root
+}
+
+// maskNode selects fields of one object. A nil node selects every field.
+type maskNode struct {
+	fields map[string]*maskNode // selected fields and the mask of their nested fields
+	path   string               // path of the object, "" or ending in '.'
+}
+
+// missing returns the path of a selected field that is not in seen, the
+// names of the fields written to the object, or "" if there is none.
+func (n *maskNode) missing(seen []string) string {
+	if n == nil || len(seen) == len(n.fields) {
+		return ""
+	}
+	name := ""
+	for f := range n.fields {
+		if !contains(seen, f) && (name == "" || f < name) {
+			name = f
+		}
+	}
+	return n.path + name
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// child reports whether field name is selected and returns its mask.
+func (n *maskNode) child(name string) (*maskNode, bool) {
+	if n == nil {
+		return nil, true
+	}
+	c, ok := n.fields[name]
+	return c, ok
+}
+
+// EncodeMask encodes only the fields of input selected by mask, in the
+// presence format: each object is preceded by a bitmap of the fields that
+// follow, so the receiver can tell omitted fields from zero values. An
+// empty mask selects every field. Decode the result with DecodeMask.
+//
+// Every path must name fields that input writes, or EncodeMask fails with
+// ErrUnknownField; a path into an object or array is only checked when a
+// non-nil object or element is written, since nothing of it is seen
+// otherwise.
+// input: Encodable struct
+// output: *[]byte or io.Writer
+func EncodeMask(input model.Encodable, mask Mask, output any) error {
+	return encodePresence(input, &presenceWriter{node: mask.tree()}, output)
+}
+
+// DecodeMask decodes input written by EncodeMask into output, enforcing
+// DefaultLimits. Omitted fields are reported to DecodeFields as ok=false.
+// It returns the paths of the fields that were present, with "[]" marking
+// array elements, e.g. "Primary", "Primary.Score", "List[].Name".
+// input: []byte or io.Reader
+// output: pointer to Decodable struct
+func DecodeMask(input, output any) (Mask, error) {
+	return decodePresence(input, output, &presenceReader{})
+}
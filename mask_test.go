@@ -0,0 +1,114 @@
+package binary
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestEncodeMask(t *testing.T) {
+	v := redactValue()
+	var data []byte
+	if err := EncodeMask(v, Mask{"ID", "Primary.Score", "Primary.Tags", "List[].Name", "Secondary"}, &data); err != nil {
+		t.Fatalf("EncodeMask failed: %v", err)
+	}
+	var full []byte
+	if err := Encode(v, &full); err != nil {
+		t.Fatal(err)
+	}
+	if len(data) >= len(full) {
+		t.Errorf("Expected masked encoding to be smaller: %d >= %d", len(data), len(full))
+	}
+
+	decoded := &FixtureComplex{}
+	present, err := DecodeMask(data, decoded)
+	if err != nil {
+		t.Fatalf("DecodeMask failed: %v", err)
+	}
+	want := &FixtureComplex{
+		ID:        7,
+		Primary:   FixtureBasic{Tags: []uint32{1, 2}, Score: 2.5},
+		Secondary: &FixtureBasic{Name: "bob", Count: 3},
+		List:      []FixtureBasic{{Name: "carol"}},
+	}
+	if !reflect.DeepEqual(want, decoded) {
+		t.Errorf("Expected %+v, got %+v", want, decoded)
+	}
+
+	wantPresent := Mask{"ID", "Primary", "Primary.Tags", "Primary.Score", "Secondary",
+		"Secondary.Name", "Secondary.Timestamp", "Secondary.Payload", "Secondary.Tags",
+		"Secondary.Count", "Secondary.Active", "Secondary.Score", "List", "List[].Name"}
+	if !reflect.DeepEqual(wantPresent, present) {
+		t.Errorf("Expected present %v, got %v", wantPresent, present)
+	}
+	if !present.Has("List.Name") || present.Has("Matrix") || present.Has("Primary.Name") {
+		t.Errorf("Unexpected Has results for %v", present)
+	}
+}
+
+func TestEncodeMaskAll(t *testing.T) {
+	v := redactValue()
+	var buf bytes.Buffer
+	if err := EncodeMask(v, nil, &buf); err != nil {
+		t.Fatalf("EncodeMask failed: %v", err)
+	}
+	decoded := &FixtureComplex{}
+	if _, err := DecodeMask(&buf, decoded); err != nil {
+		t.Fatalf("DecodeMask failed: %v", err)
+	}
+	// Primary.Payload decodes as a copy; compare everything.
+	if !reflect.DeepEqual(v, decoded) {
+		t.Errorf("Expected %+v, got %+v", v, decoded)
+	}
+}
+
+func TestDecodeMaskErrors(t *testing.T) {
+	var data []byte
+	if err := EncodeMask(redactValue(), Mask{"Primary.Name", "Matrix"}, &data); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < len(data); i++ {
+		if _, err := DecodeMask(data[:i], &FixtureComplex{}); !errors.Is(err, ErrTruncated) {
+			t.Errorf("Prefix %d: expected ErrTruncated, got %v", i, err)
+		}
+	}
+
+	// A present field the reader never asks for is rejected.
+	if _, err := DecodeMask([]byte{2, 0x03, 0, 0}, &Message{}); err != nil {
+		t.Errorf("Expected valid two-field header to decode, got %v", err)
+	}
+	if _, err := DecodeMask([]byte{9, 0x00, 0x01, 0}, &Message{}); !errors.Is(err, ErrInvalidInput) {
+		t.Errorf("Expected ErrInvalidInput for unread present field, got %v", err)
+	}
+}
+
+func TestEncodeMaskUnknownField(t *testing.T) {
+	cases := map[string]Mask{
+		"Nope":           {"ID", "Nope"},
+		"Primary.Nope":   {"Primary.Nope"},
+		"List.Nope":      {"List[].Nope"},
+		"Primary.Tags.X": {"Primary.Tags.X"},
+		"ID.X":           {"ID.X"},
+	}
+	for want, mask := range cases {
+		var data []byte
+		err := EncodeMask(redactValue(), mask, &data)
+		if !errors.Is(err, ErrUnknownField) || !strings.Contains(err.Error(), want) {
+			t.Errorf("%v: expected ErrUnknownField for %s, got %v", mask, want, err)
+		}
+	}
+
+	// Paths into nil objects and empty arrays cannot be checked.
+	var data []byte
+	if err := EncodeMask(&FixtureComplex{}, Mask{"Secondary.Nope", "List.Nope"}, &data); err != nil {
+		t.Errorf("Expected unseen paths to be accepted, got %v", err)
+	}
+	if err := EncodeMask(mapsValue(), Mask{"Users.Name", "Labels"}, &data); err != nil {
+		t.Errorf("Expected map value fields to match, got %v", err)
+	}
+	if err := EncodeMask(mapsValue(), Mask{"Labels.X"}, &data); !errors.Is(err, ErrUnknownField) {
+		t.Errorf("Expected ErrUnknownField inside map values, got %v", err)
+	}
+}
@@ -11,6 +11,10 @@ type Message struct {
 	Type    fmt.MessageType // Use fmt.MessageType instead of local byte
 	ID      uint32          // correlation ID for request/response pairs
 	Payload []byte          // binary-encoded body (domain-specific struct)
+	// Fingerprint of the Payload type (see Fingerprint); 0 means unchecked.
+	// It is an optional trailing field: absent when 0, and envelopes that
+	// end after Payload, written before it existed, decode with 0.
+	Fingerprint uint32
 }
 
 // EncodeFields implements model.Encodable
@@ -19,6 +23,11 @@ func (m *Message) EncodeFields(w model.FieldWriter) {
 	w.Int("Type", int64(m.Type))
 	w.Int("ID", int64(m.ID))
 	w.Bytes("Payload", m.Payload)
+	var fp *uint32
+	if m.Fingerprint != 0 {
+		fp = &m.Fingerprint
+	}
+	WriteOptional(w, "Fingerprint", fp)
 }
 
 // DecodeFields implements model.Decodable
@@ -35,9 +44,47 @@ func (m *Message) DecodeFields(r model.FieldReader) {
 	if v, ok := r.Bytes("Payload"); ok {
 		m.Payload = v
 	}
+	if e, ok := r.(ender); ok && e.atEnd() {
+		return // written before Fingerprint was added
+	}
+	if fp, ok := ReadOptional[uint32](r, "Fingerprint"); ok && fp != nil {
+		m.Fingerprint = *fp
+	}
+}
+
+// ender is implemented by readers that can tell when the input is exhausted.
+type ender interface {
+	atEnd() bool
 }
 
 // IsNil implements model.Encodable and model.Decodable
 func (m *Message) IsNil() bool {
 	return m == nil
 }
+
+// SetPayload encodes v into Payload and records its Fingerprint.
+func (m *Message) SetPayload(v model.Encodable) error {
+	if err := Encode(v, &m.Payload); err != nil {
+		return err
+	}
+	m.Fingerprint = Fingerprint(v)
+	return nil
+}
+
+// DecodePayload decodes Payload into v. When the message carries a
+// Fingerprint it is compared with Fingerprint(v) first, and a mismatch
+// returns ErrFingerprintMismatch with v left untouched. v must then also be
+// a model.Encodable, or the check cannot be made.
+func (m *Message) DecodePayload(v model.Decodable) error {
+	if m.Fingerprint == 0 || v == nil || v.IsNil() {
+		return Decode(m.Payload, v)
+	}
+	e, ok := v.(model.Encodable)
+	if !ok {
+		return fmt.Err("DecodePayload", "output", "must implement model.Encodable to check the fingerprint")
+	}
+	if Fingerprint(e) != m.Fingerprint {
+		return ErrFingerprintMismatch
+	}
+	return Decode(m.Payload, v)
+}
@@ -0,0 +1,178 @@
+package binary
+
+import "github.com/tinywasm/model"
+
+// OptionalWriter is implemented by writers that can tell an absent value
+// from a zero one. Present(name, false) writes an absent value; after
+// Present(name, true) the value itself must be written next, under the same
+// name. In the positional format the marker is one byte, 0 or 1, so an
+// absent value is written exactly like Null.
+type OptionalWriter interface {
+	Present(name string, ok bool)
+}
+
+// OptionalReader is the reading side of OptionalWriter. Present reports
+// whether the value follows, and ok=false when the marker could not be read.
+// When present is true the value must be read next.
+type OptionalReader interface {
+	Present(name string) (present, ok bool)
+}
+
+// Primitive lists the value types WriteOptional and ReadOptional accept.
+// Unsigned integers are written with Int and float32 with Float, like
+// cmd/binarygen does.
+type Primitive interface {
+	string | []byte | bool |
+		int | int8 | int16 | int32 | int64 |
+		uint | uint8 | uint16 | uint32 | uint64 |
+		float32 | float64
+}
+
+// WriteOptional writes the optional field name from a pointer: nil is
+// absent, anything else is present with the value *v. Writers that do not
+// implement OptionalWriter get Null for nil and the plain value otherwise.
+//
+//	binary.WriteOptional(w, "Age", u.Age) // u.Age is *int64
+func WriteOptional[T Primitive](w model.FieldWriter, name string, v *T) {
+	ow, _ := w.(OptionalWriter)
+	if v == nil {
+		if ow != nil {
+			ow.Present(name, false)
+		} else {
+			w.Null(name)
+		}
+		return
+	}
+	if ow != nil {
+		ow.Present(name, true)
+	}
+	writePrimitive(w, name, *v)
+}
+
+// scalarWriter is the part of model.FieldWriter that writes a Primitive.
+type scalarWriter interface {
+	String(name, val string)
+	Bytes(name string, val []byte)
+	Bool(name string, val bool)
+	Int(name string, val int64)
+	Float(name string, val float64)
+}
+
+func writePrimitive[T Primitive](w scalarWriter, name string, v T) {
+	switch x := any(v).(type) {
+	case string:
+		w.String(name, x)
+	case []byte:
+		w.Bytes(name, x)
+	case bool:
+		w.Bool(name, x)
+	case int:
+		w.Int(name, int64(x))
+	case int8:
+		w.Int(name, int64(x))
+	case int16:
+		w.Int(name, int64(x))
+	case int32:
+		w.Int(name, int64(x))
+	case int64:
+		w.Int(name, x)
+	case uint:
+		w.Int(name, int64(x))
+	case uint8:
+		w.Int(name, int64(x))
+	case uint16:
+		w.Int(name, int64(x))
+	case uint32:
+		w.Int(name, int64(x))
+	case uint64:
+		w.Int(name, int64(x))
+	case float32:
+		w.Float(name, float64(x))
+	case float64:
+		w.Float(name, x)
+	}
+}
+
+// ReadOptional reads an optional field written by WriteOptional. It returns
+// nil, true when the value is absent, a pointer to the value when present,
+// and nil, false when the field could not be read:
+//
+//	if v, ok := binary.ReadOptional[int64](r, "Age"); ok {
+//		u.Age = v
+//	}
+//
+// Readers that do not implement OptionalReader report a field they cannot
+// read as absent.
+func ReadOptional[T Primitive](r model.FieldReader, name string) (*T, bool) {
+	or, optional := r.(OptionalReader)
+	if optional {
+		present, ok := or.Present(name)
+		if !ok || !present {
+			return nil, ok
+		}
+	}
+	v := new(T)
+	if !readPrimitive(r, name, v) {
+		return nil, !optional
+	}
+	return v, true
+}
+
+// scalarReader is the part of model.FieldReader that reads a Primitive.
+type scalarReader interface {
+	String(name string) (string, bool)
+	Bytes(name string) ([]byte, bool)
+	Bool(name string) (bool, bool)
+	Int(name string) (int64, bool)
+	Float(name string) (float64, bool)
+}
+
+func readPrimitive[T Primitive](r scalarReader, name string, p *T) bool {
+	var ok bool
+	switch p := any(p).(type) {
+	case *string:
+		*p, ok = r.String(name)
+	case *[]byte:
+		*p, ok = r.Bytes(name)
+	case *bool:
+		*p, ok = r.Bool(name)
+	case *float32:
+		var f float64
+		f, ok = r.Float(name)
+		*p = float32(f)
+	case *float64:
+		*p, ok = r.Float(name)
+	default:
+		var i int64
+		if i, ok = r.Int(name); ok {
+			setInt(p, i)
+		}
+	}
+	return ok
+}
+
+// setInt stores i in an integer of any size.
+func setInt(p any, i int64) {
+	switch p := p.(type) {
+	case *int:
+		*p = int(i)
+	case *int8:
+		*p = int8(i)
+	case *int16:
+		*p = int16(i)
+	case *int32:
+		*p = int32(i)
+	case *int64:
+		*p = i
+	case *uint:
+		*p = uint(i)
+	case *uint8:
+		*p = uint8(i)
+	case *uint16:
+		*p = uint16(i)
+	case *uint32:
+		*p = uint32(i)
+	case *uint64:
+		*p = uint64(i)
+	}
+}
@@ -0,0 +1,230 @@
+package binary
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/tinywasm/model"
+)
+
+// FixtureOptional has an optional field of every primitive kind, like the
+// nullable columns of an ORM-generated type.
+type FixtureOptional struct {
+	Name   *string
+	Data   *[]byte
+	Active *bool
+	Count  *int
+	Small  *int8
+	Port   *uint16
+	ID     *uint64
+	Ratio  *float32
+	Score  *float64
+	After  string // plain field, checks the layout after the optionals
+}
+
+func (f *FixtureOptional) EncodeFields(w model.FieldWriter) {
+	WriteOptional(w, "Name", f.Name)
+	WriteOptional(w, "Data", f.Data)
+	WriteOptional(w, "Active", f.Active)
+	WriteOptional(w, "Count", f.Count)
+	WriteOptional(w, "Small", f.Small)
+	WriteOptional(w, "Port", f.Port)
+	WriteOptional(w, "ID", f.ID)
+	WriteOptional(w, "Ratio", f.Ratio)
+	WriteOptional(w, "Score", f.Score)
+	w.String("After", f.After)
+}
+
+func (f *FixtureOptional) DecodeFields(r model.FieldReader) {
+	if v, ok := ReadOptional[string](r, "Name"); ok {
+		f.Name = v
+	}
+	if v, ok := ReadOptional[[]byte](r, "Data"); ok {
+		f.Data = v
+	}
+	if v, ok := ReadOptional[bool](r, "Active"); ok {
+		f.Active = v
+	}
+	if v, ok := ReadOptional[int](r, "Count"); ok {
+		f.Count = v
+	}
+	if v, ok := ReadOptional[int8](r, "Small"); ok {
+		f.Small = v
+	}
+	if v, ok := ReadOptional[uint16](r, "Port"); ok {
+		f.Port = v
+	}
+	if v, ok := ReadOptional[uint64](r, "ID"); ok {
+		f.ID = v
+	}
+	if v, ok := ReadOptional[float32](r, "Ratio"); ok {
+		f.Ratio = v
+	}
+	if v, ok := ReadOptional[float64](r, "Score"); ok {
+		f.Score = v
+	}
+	if v, ok := r.String("After"); ok {
+		f.After = v
+	}
+}
+
+func (f *FixtureOptional) IsNil() bool {
+	return f == nil
+}
+
+func ptr[T any](v T) *T { return &v }
+
+func optionalValues() map[string]*FixtureOptional {
+	return map[string]*FixtureOptional{
+		"absent": {After: "end"},
+		"zero": {
+			Name: ptr(""), Data: ptr([]byte(nil)), Active: ptr(false), Count: ptr(0), Small: ptr(int8(0)),
+			Port: ptr(uint16(0)), ID: ptr(uint64(0)), Ratio: ptr(float32(0)), Score: ptr(0.0), After: "end",
+		},
+		"set": {
+			Name: ptr("Alice"), Data: ptr([]byte{1, 2}), Active: ptr(true), Count: ptr(-42), Small: ptr(int8(-8)),
+			Port: ptr(uint16(8080)), ID: ptr(uint64(1<<64 - 1)), Ratio: ptr(float32(0.5)), Score: ptr(2.5), After: "end",
+		},
+	}
+}
+
+func TestOptionalRoundTrip(t *testing.T) {
+	for name, in := range optionalValues() {
+		t.Run(name, func(t *testing.T) {
+			var data []byte
+			if err := Encode(in, &data); err != nil {
+				t.Fatalf("Encode failed: %v", err)
+			}
+			if n, err := Size(in); err != nil || n != len(data) {
+				t.Errorf("Expected size %d, got %d, %v", len(data), n, err)
+			}
+			out := &FixtureOptional{}
+			if err := Decode(data, out); err != nil {
+				t.Fatalf("Decode failed: %v", err)
+			}
+			if !reflect.DeepEqual(in, out) {
+				t.Errorf("Expected %+v, got %+v", in, out)
+			}
+
+			var tagged []byte
+			if err := EncodeTagged(in, &tagged); err != nil {
+				t.Fatalf("EncodeTagged failed: %v", err)
+			}
+			out = &FixtureOptional{}
+			if err := DecodeTagged(tagged, out); err != nil {
+				t.Fatalf("DecodeTagged failed: %v", err)
+			}
+			if !reflect.DeepEqual(in, out) {
+				t.Errorf("Tagged: expected %+v, got %+v", in, out)
+			}
+		})
+	}
+
+	// An absent value is a single 0 byte, the same as Null.
+	var data []byte
+	if err := Encode(&FixtureOptional{}, &data); err != nil {
+		t.Fatal(err)
+	}
+	if want := []byte{0, 0, 0, 0, 0, 0, 0, 0, 0, 0}; !bytes.Equal(want, data) {
+		t.Errorf("Expected %x, got %x", want, data)
+	}
+}
+
+func TestOptionalErrors(t *testing.T) {
+	if err := Decode([]byte{2}, &FixtureOptional{}); err != ErrInvalidInput {
+		t.Errorf("Expected ErrInvalidInput for a bad marker, got %v", err)
+	}
+	out := &FixtureOptional{Count: ptr(7)}
+	if err := Decode([]byte{0, 0, 0, 1}, out); err != ErrTruncated {
+		t.Errorf("Expected ErrTruncated, got %v", err)
+	}
+	if out.Count == nil || *out.Count != 7 {
+		t.Errorf("Expected a field that failed to read to be left alone, got %v", out.Count)
+	}
+}
+
+func TestOptionalSchema(t *testing.T) {
+	values := optionalValues()
+	s := SchemaOf(values["set"])
+	if f := s.Fields[3]; f.Kind != KindOptional || f.Elem != KindInt {
+		t.Errorf("Expected optional int, got %+v", f)
+	}
+	if f := s.Fields[9]; f.Name != "After" || f.Kind != KindString {
+		t.Errorf("Expected After string, got %+v", f)
+	}
+	if f := SchemaOf(values["absent"]).Fields[0]; f.Kind != KindOptional || f.Elem != KindInvalid {
+		t.Errorf("Expected optional of unknown kind, got %+v", f)
+	}
+
+	// Optional values are left out of the fingerprint, whether present or not.
+	fp := Fingerprint(values["set"])
+	if s.Fingerprint() != fp || Fingerprint(values["absent"]) != fp {
+		t.Error("Expected the fingerprint not to depend on present values")
+	}
+}
+
+func TestOptionalSprintAndMask(t *testing.T) {
+	in := &FixtureOptional{Count: ptr(3), After: "x"}
+	if got := Sprint(in); !strings.HasPrefix(got, "{Name: <nil>, Data: <nil>, Active: <nil>, Count: 3, Small: <nil>") {
+		t.Errorf("Unexpected text: %s", got)
+	}
+
+	var data []byte
+	if err := EncodeMask(in, Mask{"Count", "Name", "After"}, &data); err != nil {
+		t.Fatal(err)
+	}
+	out := &FixtureOptional{Score: ptr(1.0)}
+	mask, err := DecodeMask(data, out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &FixtureOptional{Count: ptr(3), Score: ptr(1.0), After: "x"}
+	if !reflect.DeepEqual(want, out) || !reflect.DeepEqual(Mask{"Name", "Count", "After"}, mask) {
+		t.Errorf("Expected %+v, got %+v (%v)", want, out, mask)
+	}
+}
+
+// optionalLists writes an optional Array and Map through OptionalWriter,
+// the way a hand-written EncodeFields would.
+type optionalLists struct {
+	Tags   []uint32
+	Labels map[string]int64
+}
+
+func (o *optionalLists) EncodeFields(w model.FieldWriter) {
+	ow := w.(OptionalWriter)
+	ow.Present("Tags", o.Tags != nil)
+	if o.Tags != nil {
+		aw := w.Array("Tags", len(o.Tags))
+		for _, v := range o.Tags {
+			aw.Int(int64(v))
+		}
+	}
+	ow.Present("Labels", o.Labels != nil)
+	if o.Labels != nil {
+		WriteMap(w, "Labels", o.Labels)
+	}
+}
+
+func (o *optionalLists) IsNil() bool { return o == nil }
+
+func TestOptionalSchemaArray(t *testing.T) {
+	s := SchemaOf(&optionalLists{Tags: []uint32{1}, Labels: map[string]int64{"a": 1}})
+	want := &Schema{Fields: []SchemaField{
+		{Name: "Tags", Kind: KindOptional, Elem: KindArray, Schema: &Schema{Fields: []SchemaField{{Name: "Tags", Kind: KindArray, Elem: KindInt}}}},
+		{Name: "Labels", Kind: KindOptional, Elem: KindMap, Schema: &Schema{Fields: []SchemaField{{Name: "Labels", Kind: KindMap, Key: KindString, Elem: KindInt}}}},
+	}}
+	if !reflect.DeepEqual(want, s) {
+		t.Errorf("Expected %+v, got %+v", want, s)
+	}
+
+	// The element kinds take part in compatibility checks.
+	changed := SchemaOf(&optionalLists{Tags: []uint32{1}, Labels: map[string]int64{"a": 1}})
+	changed.Fields[0].Schema.Fields[0].Elem = KindBool
+	got := CheckCompatible(s, changed)
+	if len(got) != 1 || got[0].Path != "Tags[]" {
+		t.Errorf("Expected an issue at Tags[], got %+v", got)
+	}
+}
@@ -0,0 +1,546 @@
+package binary
+
+import (
+	"io"
+	"math"
+
+	"github.com/tinywasm/fmt"
+	"github.com/tinywasm/model"
+)
+
+// Presence format is the variant of the wire format used when some fields
+// are left out. Every object, including the top-level one, starts with a
+// header listing which of its fields follow:
+//
+//	uvarint(field count) + bitmap of ceil(count/8) bytes, bit i = field i
+//
+// followed by the present fields only, in the positional encoding. Nested
+// objects keep their presence byte and, when present, carry their own
+// header; array elements are all present. EncodeMask leaves out the fields
+// outside its mask and EncodeSparse the fields holding zero values.
+
+// encodePresence writes input with p, whose w is set here, to output.
+func encodePresence(input model.Encodable, p *presenceWriter, output any) error {
+	if input == nil || input.IsNil() {
+		return fmt.Err("Encode: input is nil")
+	}
+
+	w := getWriter()
+	defer putWriter(w)
+
+	w.resetBuffer(nil, false)
+	p.w = w
+	p.object(input, p.node)
+	if w.err != nil {
+		return w.err
+	}
+	buf := w.buf
+	w.buf = nil
+
+	switch out := output.(type) {
+	case *[]byte:
+		*out = buf
+		return nil
+	case io.Writer:
+		_, err := out.Write(buf)
+		return err
+	}
+	return fmt.Err("Encode", "output", "must be *[]byte or io.Writer")
+}
+
+// decodePresence reads input into output with p, whose br is set here, and
+// returns the paths of the fields that were present.
+func decodePresence(input, output any, p *presenceReader) (Mask, error) {
+	if output == nil {
+		return nil, fmt.Err("Decode: output is nil")
+	}
+	dec, ok := output.(model.Decodable)
+	if !ok {
+		return nil, fmt.Err("Decode", "output", "must implement model.Decodable")
+	}
+	if dec.IsNil() {
+		return nil, fmt.Err("Decode: output is nil")
+	}
+
+	r := getReader()
+	defer putReader(r)
+
+	switch in := input.(type) {
+	case []byte:
+		r.reset(newSliceReader(in), DefaultLimits)
+	case io.Reader:
+		r.reset(in, DefaultLimits)
+	default:
+		return nil, fmt.Err("Decode", "input", "must be []byte or io.Reader")
+	}
+
+	p.br = r
+	p.object(dec, "")
+	if r.err != nil {
+		return nil, r.err
+	}
+	return p.present, nil
+}
+
+// presenceWriter is a model.FieldWriter that writes the presence format,
+// keeping the fields selected by a maskNode. In sparse mode it also leaves
+// out zero values.
+type presenceWriter struct {
+	w      *binaryWriter // buffer mode
+	node   *maskNode     // fields kept in the current object; nil keeps all
+	sparse bool          // omit zero values
+	start  int           // offset in w.buf of the current object's header
+	n      int           // fields seen in the current object
+	bits   []byte        // stack of bitmaps; the current one starts at base
+	base   int
+	hdr    []byte
+	value  int8     // set by Present: 1 keeps the optional value that follows, -1 drops it
+	seen   []string // stack of selected names written; the current object's start at seenAt
+	seenAt int
+}
+
+// field records one field of the current object and reports whether it is
+// written, with the mask for its nested fields. zero tells whether the value
+// is the zero value of its kind.
+func (p *presenceWriter) field(name string, zero bool) (*maskNode, bool) {
+	if v := p.value; v != 0 {
+		p.value = 0 // the value of an optional field is not a field itself
+		return nil, v > 0
+	}
+	i := p.n
+	p.n++
+	if i%8 == 0 {
+		p.bits = append(p.bits, 0)
+	}
+	child, ok := p.node.child(name)
+	if ok && p.node != nil && !contains(p.seen[p.seenAt:], name) {
+		p.seen = append(p.seen, name)
+	}
+	if ok = ok && !(zero && p.sparse); ok {
+		p.bits[p.base+i/8] |= 1 << (i % 8)
+	}
+	return child, ok
+}
+
+// scalar is field for values without nested fields; a mask selecting
+// fields inside one names fields that do not exist.
+func (p *presenceWriter) scalar(name string, zero bool) bool {
+	child, ok := p.field(name, zero)
+	if child != nil {
+		p.fail(unknownField(child.missing(nil)))
+	}
+	return ok
+}
+
+// unknownField returns ErrUnknownField for path.
+func unknownField(path string) error {
+	return fmt.ErrType(fmt.Err("EncodeMask", path), ErrUnknownField)
+}
+
+// object writes v in the presence format with the given mask.
+func (p *presenceWriter) object(v model.Encodable, node *maskNode) {
+	saved := *p
+	p.node, p.start, p.n, p.base = node, len(p.w.buf), 0, len(p.bits)
+	p.seenAt = len(p.seen)
+
+	v.EncodeFields(p)
+	if path := node.missing(p.seen[p.seenAt:]); path != "" {
+		p.fail(unknownField(path))
+	}
+
+	p.hdr = appendUvarint(p.hdr[:0], uint64(p.n))
+	p.hdr = append(p.hdr, p.bits[p.base:]...)
+	n := len(p.hdr)
+	p.w.buf = append(p.w.buf, p.hdr...)
+	copy(p.w.buf[p.start+n:], p.w.buf[p.start:len(p.w.buf)-n])
+	copy(p.w.buf[p.start:], p.hdr)
+
+	p.bits, p.seen = p.bits[:p.base], p.seen[:p.seenAt]
+	p.node, p.start, p.n, p.base, p.seenAt = saved.node, saved.start, saved.n, saved.base, saved.seenAt
+}
+
+func (p *presenceWriter) fail(err error) { p.w.fail(err) }
+
+// isZeroFloat reports whether f is +0, so -0 survives a sparse round trip.
+func isZeroFloat(f float64) bool {
+	return f == 0 && !math.Signbit(f)
+}
+
+// nested writes an Object value: its presence byte, then its fields.
+func (p *presenceWriter) nested(val model.Encodable, node *maskNode) {
+	if val == nil || val.IsNil() {
+		p.w.Null("")
+		return
+	}
+	p.w.Bool("", true) // presence byte
+	p.object(val, node)
+}
+
+func (p *presenceWriter) String(name, val string) {
+	if p.scalar(name, val == "") {
+		p.w.String(name, val)
+	}
+}
+
+func (p *presenceWriter) Raw(name, val string) {
+	if p.scalar(name, val == "") {
+		p.w.Raw(name, val)
+	}
+}
+
+func (p *presenceWriter) Int(name string, val int64) {
+	if p.scalar(name, val == 0) {
+		p.w.Int(name, val)
+	}
+}
+
+func (p *presenceWriter) Uint(name string, val uint64) {
+	if p.scalar(name, val == 0) {
+		p.w.Uint(name, val)
+	}
+}
+
+func (p *presenceWriter) Float(name string, val float64) {
+	if p.scalar(name, isZeroFloat(val)) {
+		p.w.Float(name, val)
+	}
+}
+
+func (p *presenceWriter) Float32(name string, val float32) {
+	if p.scalar(name, isZeroFloat(float64(val))) {
+		p.w.Float32(name, val)
+	}
+}
+
+func (p *presenceWriter) Fixed32(name string, val uint32) {
+	if p.scalar(name, val == 0) {
+		p.w.Fixed32(name, val)
+	}
+}
+
+func (p *presenceWriter) Fixed64(name string, val uint64) {
+	if p.scalar(name, val == 0) {
+		p.w.Fixed64(name, val)
+	}
+}
+
+func (p *presenceWriter) Bool(name string, val bool) {
+	if p.scalar(name, !val) {
+		p.w.Bool(name, val)
+	}
+}
+
+func (p *presenceWriter) Bytes(name string, val []byte) {
+	if p.scalar(name, len(val) == 0) {
+		p.w.Bytes(name, val)
+	}
+}
+
+func (p *presenceWriter) Null(name string) {
+	if p.scalar(name, true) {
+		p.w.Null(name)
+	}
+}
+
+// Present implements OptionalWriter: the marker and the value that follows
+// make up one field.
+func (p *presenceWriter) Present(name string, ok bool) {
+	if keep := p.scalar(name, !ok); keep {
+		p.w.Present(name, ok)
+		if ok {
+			p.value = 1
+		}
+	} else if ok {
+		p.value = -1
+	}
+}
+
+func (p *presenceWriter) Object(name string, val model.Encodable) {
+	if child, ok := p.field(name, val == nil || val.IsNil()); ok {
+		p.nested(val, child)
+	}
+}
+
+func (p *presenceWriter) Map(name string, n int, key, value Kind) model.ArrayWriter {
+	child, ok := p.field(name, n == 0)
+	if !ok {
+		return discardArrayWriter{}
+	}
+	p.w.writeUvarint(uint64(n))
+	return &presenceArrayWriter{p: p, node: child, keyed: true}
+}
+
+func (p *presenceWriter) Array(name string, n int) model.ArrayWriter {
+	child, ok := p.field(name, n == 0)
+	if !ok {
+		return discardArrayWriter{}
+	}
+	p.w.writeUvarint(uint64(n))
+	return &presenceArrayWriter{p: p, node: child}
+}
+
+// presenceArrayWriter writes array elements and map entries; object values
+// use the presence format with the field's mask.
+type presenceArrayWriter struct {
+	p     *presenceWriter
+	node  *maskNode
+	keyed bool // map entries: every other element is a key
+	i     int  // elements written
+}
+
+// scalar returns the writer for an element without nested fields, failing
+// if the mask selects fields inside the elements.
+func (a *presenceArrayWriter) scalar() *binaryArrayWriter {
+	key := a.keyed && a.i%2 == 0
+	a.i++
+	if a.node != nil && !key {
+		a.p.fail(unknownField(a.node.missing(nil)))
+		a.node = nil
+	}
+	return &a.p.w.aw
+}
+
+func (a *presenceArrayWriter) String(val string) { a.scalar().String(val) }
+func (a *presenceArrayWriter) Int(val int64)     { a.scalar().Int(val) }
+func (a *presenceArrayWriter) Float(val float64) { a.scalar().Float(val) }
+func (a *presenceArrayWriter) Bool(val bool)     { a.scalar().Bool(val) }
+func (a *presenceArrayWriter) Bytes(val []byte)  { a.scalar().Bytes(val) }
+func (a *presenceArrayWriter) Object(val model.Encodable) {
+	a.i++
+	a.p.nested(val, a.node)
+}
+func (a *presenceArrayWriter) Float32(val float32) { a.scalar().Float32(val) }
+func (a *presenceArrayWriter) Fixed32(val uint32)  { a.scalar().Fixed32(val) }
+func (a *presenceArrayWriter) Fixed64(val uint64)  { a.scalar().Fixed64(val) }
+func (a *presenceArrayWriter) Close()              {}
+
+// presenceReader is a model.FieldReader over the presence format. Absent
+// fields are reported as ok=false, or as zero values with ok=true when zeros
+// is set. It records the paths of the fields it finds present.
+type presenceReader struct {
+	br     *binaryReader
+	zeros  bool   // report absent fields as zero values
+	n      int    // fields in the current object's header
+	i      int    // index of the next field asked for
+	bits   []byte // stack of bitmaps; the current one starts at base
+	base   int
+	prefix string // path of the current object, "" or ending in '.'
+	value  bool   // set by Present: the next read is the optional value
+
+	present []string
+	seen    map[string]bool
+}
+
+// field reports whether the next field of the current object is present.
+func (p *presenceReader) field(name string) bool {
+	if p.value {
+		p.value = false
+		return p.br.err == nil
+	}
+	i := p.i
+	p.i++
+	if p.br.err != nil || i >= p.n || p.bits[p.base+i/8]&(1<<(i%8)) == 0 {
+		return false
+	}
+	if path := p.prefix + name; !p.seen[path] {
+		if p.seen == nil {
+			p.seen = make(map[string]bool)
+		}
+		p.seen[path] = true
+		p.present = append(p.present, path)
+	}
+	return true
+}
+
+// object reads a header and decodes the fields that follow into v, using
+// prefix for the paths of its fields.
+func (p *presenceReader) object(v model.Decodable, prefix string) {
+	br := p.br
+	count, ok := br.readUvarint()
+	if !ok {
+		return
+	}
+	size := count/8 + 1
+	if count%8 == 0 {
+		size--
+	}
+	if !br.checkLen(size) {
+		return
+	}
+
+	saved := *p
+	p.n, p.i, p.base, p.prefix = int(count), 0, len(p.bits), prefix
+
+	for j := uint64(0); j < size; j++ {
+		b, err := br.r.ReadByte()
+		if err != nil {
+			br.fail(readError(err))
+			break
+		}
+		p.bits = append(p.bits, b)
+	}
+	if br.err == nil {
+		v.DecodeFields(p)
+	}
+	// Present fields the reader did not ask for cannot be skipped.
+	for ; br.err == nil && p.i < p.n; p.i++ {
+		if p.bits[p.base+p.i/8]&(1<<(p.i%8)) != 0 {
+			br.fail(ErrInvalidInput)
+		}
+	}
+
+	p.bits = p.bits[:p.base]
+	p.n, p.i, p.base, p.prefix = saved.n, saved.i, saved.base, saved.prefix
+}
+
+func (p *presenceReader) fail(err error) { p.br.fail(err) }
+
+// absent is the ok result for a field that is not present.
+func (p *presenceReader) absent() bool {
+	return p.zeros && p.br.err == nil
+}
+
+// nested reads an Object value: its presence byte, then its fields.
+func (p *presenceReader) nested(into model.Decodable, prefix string) bool {
+	present, ok := p.br.readFlag()
+	if !ok || !present || !p.br.enter() {
+		return false
+	}
+	p.object(into, prefix)
+	p.br.leave()
+	return p.br.err == nil
+}
+
+func (p *presenceReader) String(name string) (string, bool) {
+	if !p.field(name) {
+		return "", p.absent()
+	}
+	return p.br.String(name)
+}
+
+func (p *presenceReader) Raw(name string) (string, bool) {
+	if !p.field(name) {
+		return "", p.absent()
+	}
+	return p.br.Raw(name)
+}
+
+func (p *presenceReader) Int(name string) (int64, bool) {
+	if !p.field(name) {
+		return 0, p.absent()
+	}
+	return p.br.Int(name)
+}
+
+func (p *presenceReader) Uint(name string) (uint64, bool) {
+	if !p.field(name) {
+		return 0, p.absent()
+	}
+	return p.br.Uint(name)
+}
+
+func (p *presenceReader) Float(name string) (float64, bool) {
+	if !p.field(name) {
+		return 0, p.absent()
+	}
+	return p.br.Float(name)
+}
+
+func (p *presenceReader) Float32(name string) (float32, bool) {
+	if !p.field(name) {
+		return 0, p.absent()
+	}
+	return p.br.Float32(name)
+}
+
+func (p *presenceReader) Fixed32(name string) (uint32, bool) {
+	if !p.field(name) {
+		return 0, p.absent()
+	}
+	return p.br.Fixed32(name)
+}
+
+func (p *presenceReader) Fixed64(name string) (uint64, bool) {
+	if !p.field(name) {
+		return 0, p.absent()
+	}
+	return p.br.Fixed64(name)
+}
+
+func (p *presenceReader) Bool(name string) (bool, bool) {
+	if !p.field(name) {
+		return false, p.absent()
+	}
+	return p.br.Bool(name)
+}
+
+func (p *presenceReader) Bytes(name string) ([]byte, bool) {
+	if !p.field(name) {
+		return nil, p.absent()
+	}
+	return p.br.Bytes(name)
+}
+
+// Present implements OptionalReader. A field that is not present is
+// reported like every other absent field.
+func (p *presenceReader) Present(name string) (bool, bool) {
+	if !p.field(name) {
+		return false, p.absent()
+	}
+	present, ok := p.br.Present(name)
+	p.value = present && ok
+	return present, ok
+}
+
+func (p *presenceReader) Object(name string, into model.Decodable) bool {
+	if !p.field(name) {
+		return false
+	}
+	return p.nested(into, p.prefix+name+".")
+}
+
+func (p *presenceReader) Map(name string) (model.ArrayReader, bool) {
+	if !p.field(name) {
+		if p.absent() {
+			return &binaryArrayReader{br: p.br}, true
+		}
+		return nil, false
+	}
+	ar, ok := p.br.Map(name)
+	if !ok {
+		return nil, false
+	}
+	return &presenceArrayReader{ArrayReader: ar, p: p, prefix: p.prefix + name + "[]."}, true
+}
+
+func (p *presenceReader) Array(name string) (model.ArrayReader, bool) {
+	if !p.field(name) {
+		if p.absent() {
+			return &binaryArrayReader{br: p.br}, true
+		}
+		return nil, false
+	}
+	ar, ok := p.br.Array(name)
+	if !ok {
+		return nil, false
+	}
+	return &presenceArrayReader{ArrayReader: ar, p: p, prefix: p.prefix + name + "[]."}, true
+}
+
+// presenceArrayReader reads object elements and map values in the presence
+// format.
+type presenceArrayReader struct {
+	model.ArrayReader
+	p      *presenceReader
+	prefix string
+}
+
+func (a *presenceArrayReader) Object(i int, into model.Decodable) bool {
+	return a.p.nested(into, a.prefix)
+}
+
+// The embedded ArrayReader does not promote CompactArrayReader.
+
+func (a *presenceArrayReader) Float32(i int) float32 { return float32At(a.ArrayReader, i) }
+func (a *presenceArrayReader) Fixed32(i int) uint32  { return fixed32At(a.ArrayReader, i) }
+func (a *presenceArrayReader) Fixed64(i int) uint64  { return fixed64At(a.ArrayReader, i) }
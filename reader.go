@@ -3,10 +3,7 @@ package binary
 import (
 	"bufio"
 	"bytes"
-	"encoding/binary"
 	"io"
-
-	"github.com/tinywasm/fmt"
 )
 
 // MaxVarintLenN is the maximum length of a varint-encoded N-bit integer.
@@ -14,8 +11,6 @@ const (
 	maxVarintLen64 = 10 * 7
 )
 
-var errOverflow = fmt.Err("binary", "varint overflow 64-bit integer")
-
 // reader represents a required contract for a decoder to work properly
 type reader interface {
 	io.Reader
@@ -23,6 +18,7 @@ type reader interface {
 	Slice(n int) (buffer []byte, err error)
 	ReadUvarint() (uint64, error)
 	ReadVarint() (int64, error)
+	Offset() int64 // number of bytes consumed so far
 }
 
 // newReader figures out the most efficient reader to use for the provided type
@@ -98,7 +94,7 @@ func (r *sliceReader) ReadByte() (byte, error) {
 // returns a sub-slice pointing to the same array. Since this requires access
 // to the underlying data, this is only available for our default reader.
 func (r *sliceReader) Slice(n int) ([]byte, error) {
-	if r.offset+int64(n) > int64(len(r.buffer)) {
+	if n < 0 || r.offset+int64(n) > int64(len(r.buffer)) {
 		return nil, io.EOF
 	}
 
@@ -119,13 +115,13 @@ func (r *sliceReader) ReadUvarint() (uint64, error) {
 		r.offset++
 		if b < 0x80 {
 			if s == maxVarintLen64-7 && b > 1 {
-				return x, errOverflow
+				return x, ErrVarintOverflow
 			}
 			return x | uint64(b)<<s, nil
 		}
 		x |= uint64(b&0x7f) << s
 	}
-	return x, errOverflow
+	return x, ErrVarintOverflow
 }
 
 // ReadVarint reads an encoded signed integer from r and returns it as an int64.
@@ -138,6 +134,9 @@ func (r *sliceReader) ReadVarint() (int64, error) {
 	return x, err
 }
 
+// Offset returns the number of bytes consumed so far.
+func (r *sliceReader) Offset() int64 { return r.offset }
+
 // Reset resets the Reader to be reading from b.
 func (r *sliceReader) Reset(b []byte) {
 	r.buffer = b
@@ -149,6 +148,7 @@ func (r *sliceReader) Reset(b []byte) {
 // streamReader represents a reader implementation for a generic reader (i.e. streams)
 type streamReader struct {
 	genericReader
+	offset int64 // bytes consumed so far
 }
 
 // genericReader represents the interface a reader should implement.
@@ -169,19 +169,95 @@ func newStreamReader(r io.Reader) *streamReader {
 	}
 }
 
+// Read implements the io.Reader interface.
+func (r *streamReader) Read(p []byte) (int, error) {
+	n, err := r.genericReader.Read(p)
+	r.offset += int64(n)
+	return n, err
+}
+
+// ReadByte implements the io.ByteReader interface.
+func (r *streamReader) ReadByte() (byte, error) {
+	b, err := r.genericReader.ReadByte()
+	if err == nil {
+		r.offset++
+	}
+	return b, err
+}
+
+// Offset returns the number of bytes consumed so far.
+func (r *streamReader) Offset() int64 { return r.offset }
+
+// peek checks that another byte is available without consuming it. Readers
+// that cannot unread a byte are assumed to have more data.
+func (r *streamReader) peek() error {
+	bs, ok := r.genericReader.(io.ByteScanner)
+	if !ok {
+		return nil
+	}
+	if _, err := bs.ReadByte(); err != nil {
+		return err
+	}
+	return bs.UnreadByte()
+}
+
 // Slice selects a sub-slice of next bytes.
 func (r *streamReader) Slice(n int) (buffer []byte, err error) {
-	buffer = make([]byte, n)
-	_, err = io.ReadFull(r, buffer)
-	return
+	return readBody(r, nil, n)
+}
+
+// readChunk is the most a stream body read allocates ahead of the bytes
+// received.
+const readChunk = 64 << 10
+
+// readBody reads exactly n bytes from r, reusing buf when it is large enough.
+// Otherwise the buffer starts at readChunk bytes and doubles as data arrives,
+// so a length prefix cannot make a short stream allocate the whole length.
+func readBody(r io.Reader, buf []byte, n int) ([]byte, error) {
+	if cap(buf) < n {
+		buf = make([]byte, 0, min(n, readChunk))
+	}
+	buf = buf[:0]
+	for {
+		k, err := io.ReadFull(r, buf[len(buf):min(n, cap(buf))])
+		buf = buf[:len(buf)+k]
+		if err != nil || len(buf) == n {
+			return buf, err
+		}
+		grown := make([]byte, len(buf), len(buf)+min(n-len(buf), len(buf)))
+		copy(grown, buf)
+		buf = grown
+	}
 }
 
 // ReadUvarint reads an encoded unsigned integer from r and returns it as a uint64.
 func (r *streamReader) ReadUvarint() (uint64, error) {
-	return binary.ReadUvarint(r)
+	var x uint64
+	for s := 0; s < maxVarintLen64; s += 7 {
+		b, err := r.ReadByte()
+		if err != nil {
+			if s > 0 && err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return x, err
+		}
+		if b < 0x80 {
+			if s == maxVarintLen64-7 && b > 1 {
+				return x, ErrVarintOverflow
+			}
+			return x | uint64(b)<<s, nil
+		}
+		x |= uint64(b&0x7f) << s
+	}
+	return x, ErrVarintOverflow
 }
 
 // ReadVarint reads a variable-length Int64 from the buffer.
 func (r *streamReader) ReadVarint() (int64, error) {
-	return binary.ReadVarint(r)
+	ux, err := r.ReadUvarint() // ok to continue in presence of error
+	x := int64(ux >> 1)
+	if ux&1 != 0 {
+		x = ^x
+	}
+	return x, err
 }
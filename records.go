@@ -0,0 +1,76 @@
+package binary
+
+import (
+	"io"
+	"iter"
+
+	"github.com/tinywasm/model"
+)
+
+// Records returns an iterator over the values concatenated in r, as written
+// by Encoder or repeated calls to Encode. Each value is decoded into a new T:
+//
+//	for u, err := range binary.Records[User](f) {
+//		if err != nil {
+//			return err
+//		}
+//		...
+//	}
+//
+// Iteration ends cleanly at the end of the stream; a partial trailing value
+// yields ErrTruncated and stops. Like Decoder, Records may read past the
+// last value it yields when r cannot unread a byte.
+func Records[T any, PT interface {
+	*T
+	model.Decodable
+}](r io.Reader) iter.Seq2[T, error] {
+	return records(func() *Decoder { return NewDecoder(r) }, decodeNew[T, PT])
+}
+
+// RecordsBytes is like Records but decodes from b without copying it into
+// an intermediate buffer. Decoded byte slices are still copies, and the
+// iterator can be ranged over more than once.
+func RecordsBytes[T any, PT interface {
+	*T
+	model.Decodable
+}](b []byte) iter.Seq2[T, error] {
+	return records(func() *Decoder { return NewDecoder(newSliceReader(b)) }, decodeNew[T, PT])
+}
+
+func records[T any](open func() *Decoder, next func(d *Decoder) (T, error)) iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		d := open()
+		for {
+			v, err := next(d)
+			if err == io.EOF {
+				return
+			}
+			if err != nil {
+				var zero T
+				yield(zero, err)
+				return
+			}
+			if !yield(v, nil) {
+				return
+			}
+		}
+	}
+}
+
+func decodeNew[T any, PT interface {
+	*T
+	model.Decodable
+}](d *Decoder) (T, error) {
+	var v T
+	err := d.Decode(PT(&v))
+	return v, err
+}
+
+// RecordsInto is like Records but decodes every value into v and yields it,
+// so a DecodeFields that reuses its slices avoids allocating per value. The
+// yielded value is overwritten by the next iteration.
+func RecordsInto[T model.Decodable](r io.Reader, v T) iter.Seq2[T, error] {
+	return records(func() *Decoder { return NewDecoder(r) }, func(d *Decoder) (T, error) {
+		return v, d.Decode(v)
+	})
+}
@@ -0,0 +1,100 @@
+package binary
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/tinywasm/fmt"
+)
+
+func recordStream(t *testing.T) ([]Message, []byte) {
+	t.Helper()
+	want := []Message{
+		{Topic: "a", Type: fmt.Msg.Event, ID: 1, Payload: []byte("x")},
+		{Topic: "b", Type: fmt.Msg.Request, ID: 2},
+		{Topic: "c", Type: fmt.Msg.Response, ID: 3, Payload: []byte{0, 1}},
+	}
+	var data []byte
+	for i := range want {
+		var err error
+		if data, err = AppendEncode(data, &want[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return want, data
+}
+
+func TestRecords(t *testing.T) {
+	want, data := recordStream(t)
+	for name, seq := range map[string]func() []Message{
+		"Reader": func() []Message { return collect(t, Records[Message](&oneByteReader{content: data})) },
+		"Bytes":  func() []Message { return collect(t, RecordsBytes[Message](data)) },
+	} {
+		if got := seq(); !reflect.DeepEqual(want, got) {
+			t.Errorf("%s: expected %+v, got %+v", name, want, got)
+		}
+	}
+
+	// Breaking early stops decoding.
+	n := 0
+	for range RecordsBytes[Message](data) {
+		n++
+		break
+	}
+	if n != 1 {
+		t.Errorf("Expected 1 iteration, got %d", n)
+	}
+}
+
+func collect(t *testing.T, seq func(yield func(Message, error) bool)) []Message {
+	t.Helper()
+	var got []Message
+	for m, err := range seq {
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		got = append(got, m)
+	}
+	return got
+}
+
+func TestRecordsTruncated(t *testing.T) {
+	want, data := recordStream(t)
+	var got []Message
+	var last error
+	// Cut into the last Payload: without its trailing Fingerprint marker
+	// the last envelope would read like one written before the field existed.
+	for m, err := range Records[Message](bytes.NewReader(data[:len(data)-2])) {
+		if err != nil {
+			last = err
+			continue
+		}
+		got = append(got, m)
+	}
+	if !errors.Is(last, ErrTruncated) {
+		t.Errorf("Expected ErrTruncated, got %v", last)
+	}
+	if !reflect.DeepEqual(want[:2], got) {
+		t.Errorf("Expected %+v, got %+v", want[:2], got)
+	}
+}
+
+func TestRecordsInto(t *testing.T) {
+	want, data := recordStream(t)
+	m := &Message{}
+	i := 0
+	for got, err := range RecordsInto(bytes.NewBuffer(data), m) {
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != m || !reflect.DeepEqual(want[i], *got) {
+			t.Errorf("Record %d: expected %+v, got %+v", i, want[i], got)
+		}
+		i++
+	}
+	if i != len(want) {
+		t.Errorf("Expected %d records, got %d", len(want), i)
+	}
+}
@@ -0,0 +1,414 @@
+package binary
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"math"
+
+	"github.com/tinywasm/fmt"
+	"github.com/tinywasm/model"
+)
+
+// DefaultRedactMarker replaces redacted strings when Redactor.Marker is empty.
+const DefaultRedactMarker = "[REDACTED]"
+
+// Redactor hides the values of selected fields while a value is encoded or
+// printed. A pattern is a dotted field path such as "User.Email"; "*"
+// matches any one field name and a pattern without dots, such as
+// "Password", matches that field at any depth. Fields of array elements are
+// addressed through the array field: "List.Name" or "List[].Name".
+//
+// Redacted values keep their wire kind, so the result still decodes into the
+// original type: strings and bytes become the marker, numbers and bools
+// become zero, and objects become nil. Sprint shows every redacted field as
+// the marker.
+type Redactor struct {
+	Marker string // replacement text; DefaultRedactMarker when empty
+	// Key, when set, replaces strings, bytes and numbers with an
+	// HMAC-SHA256 of the value under Key instead, so equal secrets can be
+	// correlated in logs without being found by hashing guesses. Strings
+	// become "#" and 16 hex digits; Raw values always become the marker.
+	Key []byte
+
+	patterns [][]string
+}
+
+// NewRedactor returns a Redactor for the given field patterns.
+func NewRedactor(patterns ...string) *Redactor {
+	r := &Redactor{}
+	for _, p := range patterns {
+		p = fmt.Convert(p).Replace("[]", "").String()
+		r.patterns = append(r.patterns, fmt.Split(p, "."))
+	}
+	return r
+}
+
+// Redact wraps v with NewRedactor(patterns...), e.g.
+// Sprint(Redact(msg, "Password", "*.Token")).
+func Redact(v model.Encodable, patterns ...string) model.Encodable {
+	return NewRedactor(patterns...).Encodable(v)
+}
+
+// Encodable wraps v so that its fields are redacted wherever it is encoded,
+// measured or printed.
+func (r *Redactor) Encodable(v model.Encodable) model.Encodable {
+	return &redactObject{r: r, v: v}
+}
+
+// Writer wraps w so that the fields written to it are redacted.
+func (r *Redactor) Writer(w model.FieldWriter) model.FieldWriter {
+	return &redactWriter{r: r, w: w}
+}
+
+func (r *Redactor) match(path []string) bool {
+	for _, p := range r.patterns {
+		if len(p) == 1 && p[0] != "*" {
+			if p[0] == path[len(path)-1] {
+				return true
+			}
+			continue
+		}
+		if len(p) != len(path) {
+			continue
+		}
+		i := 0
+		for i < len(p) && (p[i] == "*" || p[i] == path[i]) {
+			i++
+		}
+		if i == len(p) {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *Redactor) marker() string {
+	if r.Marker == "" {
+		return DefaultRedactMarker
+	}
+	return r.Marker
+}
+
+// sum returns the first 8 bytes of the HMAC-SHA256 of b under r.Key.
+func (r *Redactor) sum(b []byte) uint64 {
+	m := hmac.New(sha256.New, r.Key)
+	m.Write(b)
+	var v uint64
+	for _, c := range m.Sum(nil)[:8] {
+		v = v<<8 | uint64(c)
+	}
+	return v
+}
+
+// text replaces a redacted string or bytes value.
+func (r *Redactor) text(b []byte) string {
+	if r.Key == nil {
+		return r.marker()
+	}
+	const digits = "0123456789abcdef"
+	v := r.sum(b)
+	out := []byte{'#'}
+	for shift := 60; shift >= 0; shift -= 4 {
+		out = append(out, digits[v>>uint(shift)&0xf])
+	}
+	return string(out)
+}
+
+// number replaces a redacted number, given as its bits.
+func (r *Redactor) number(v uint64) uint64 {
+	if r.Key == nil {
+		return 0
+	}
+	return r.sum(appendFixed64(nil, v))
+}
+
+// redactObject is an Encodable that redacts the fields of v found under path.
+type redactObject struct {
+	r    *Redactor
+	v    model.Encodable
+	path []string
+}
+
+func (o *redactObject) IsNil() bool {
+	return o.v == nil || o.v.IsNil()
+}
+
+func (o *redactObject) EncodeFields(w model.FieldWriter) {
+	o.v.EncodeFields(&redactWriter{r: o.r, w: w, path: o.path})
+}
+
+// redactWriter is a model.FieldWriter passing fields to w, redacting the
+// ones matched by r.
+type redactWriter struct {
+	r    *Redactor
+	w    model.FieldWriter
+	path []string // path of the object being written
+}
+
+func (rw *redactWriter) fail(err error) {
+	if f, ok := rw.w.(failer); ok {
+		f.fail(err)
+	}
+}
+
+// matched reports whether the field name of the current object is redacted.
+func (rw *redactWriter) matched(name string) bool {
+	return rw.r.match(append(rw.path[:len(rw.path):len(rw.path)], name))
+}
+
+// printed reports whether w is the Sprint writer, which shows the marker for
+// redacted fields of any kind.
+func (rw *redactWriter) printed() bool {
+	_, ok := rw.w.(*textWriter)
+	return ok
+}
+
+func (rw *redactWriter) String(name, val string) {
+	switch {
+	case !rw.matched(name):
+		rw.w.String(name, val)
+	case rw.printed():
+		rw.w.Raw(name, rw.r.marker())
+	default:
+		rw.w.String(name, rw.r.text([]byte(val)))
+	}
+}
+
+func (rw *redactWriter) Raw(name, val string) {
+	if rw.matched(name) {
+		val = rw.r.marker()
+	}
+	rw.w.Raw(name, val)
+}
+
+func (rw *redactWriter) Int(name string, val int64) {
+	switch {
+	case !rw.matched(name):
+		rw.w.Int(name, val)
+	case rw.printed():
+		rw.w.Raw(name, rw.r.marker())
+	default:
+		rw.w.Int(name, int64(rw.r.number(uint64(val))))
+	}
+}
+
+// uintWriter is implemented by the writers of this package that support
+// unsigned fields, which model.FieldWriter does not declare.
+type uintWriter interface {
+	Uint(name string, val uint64)
+}
+
+func (rw *redactWriter) Uint(name string, val uint64) {
+	switch {
+	case !rw.matched(name):
+	case rw.printed():
+		rw.w.Raw(name, rw.r.marker())
+		return
+	default:
+		val = rw.r.number(val)
+	}
+	if uw, ok := rw.w.(uintWriter); ok {
+		uw.Uint(name, val)
+	} else {
+		rw.w.Int(name, int64(val))
+	}
+}
+
+func (rw *redactWriter) Float(name string, val float64) {
+	switch {
+	case !rw.matched(name):
+		rw.w.Float(name, val)
+	case rw.printed():
+		rw.w.Raw(name, rw.r.marker())
+	default:
+		rw.w.Float(name, float64(rw.r.number(math.Float64bits(val))))
+	}
+}
+
+func (rw *redactWriter) Float32(name string, val float32) {
+	switch {
+	case !rw.matched(name):
+		WriteFloat32(rw.w, name, val)
+	case rw.printed():
+		rw.w.Raw(name, rw.r.marker())
+	default:
+		WriteFloat32(rw.w, name, float32(rw.r.number(uint64(math.Float32bits(val)))))
+	}
+}
+
+func (rw *redactWriter) Fixed32(name string, val uint32) {
+	switch {
+	case !rw.matched(name):
+	case rw.printed():
+		rw.w.Raw(name, rw.r.marker())
+		return
+	default:
+		val = uint32(rw.r.number(uint64(val)))
+	}
+	WriteFixed32(rw.w, name, val)
+}
+
+func (rw *redactWriter) Fixed64(name string, val uint64) {
+	switch {
+	case !rw.matched(name):
+	case rw.printed():
+		rw.w.Raw(name, rw.r.marker())
+		return
+	default:
+		val = rw.r.number(val)
+	}
+	WriteFixed64(rw.w, name, val)
+}
+
+func (rw *redactWriter) Bool(name string, val bool) {
+	switch {
+	case !rw.matched(name):
+		rw.w.Bool(name, val)
+	case rw.printed():
+		rw.w.Raw(name, rw.r.marker())
+	default:
+		rw.w.Bool(name, false)
+	}
+}
+
+func (rw *redactWriter) Bytes(name string, val []byte) {
+	switch {
+	case !rw.matched(name):
+		rw.w.Bytes(name, val)
+	case rw.printed():
+		rw.w.Raw(name, rw.r.marker())
+	default:
+		rw.w.Bytes(name, []byte(rw.r.text(val)))
+	}
+}
+
+func (rw *redactWriter) Null(name string) {
+	rw.w.Null(name)
+}
+
+func (rw *redactWriter) Present(name string, ok bool) {
+	if ow, optional := rw.w.(OptionalWriter); optional {
+		ow.Present(name, ok)
+	} else if !ok {
+		rw.w.Null(name)
+	}
+}
+
+func (rw *redactWriter) Object(name string, val model.Encodable) {
+	path := append(rw.path[:len(rw.path):len(rw.path)], name)
+	switch {
+	case !rw.r.match(path):
+		if val == nil || val.IsNil() {
+			rw.w.Object(name, val)
+			return
+		}
+		rw.w.Object(name, &redactObject{r: rw.r, v: val, path: path})
+	case rw.printed():
+		rw.w.Raw(name, rw.r.marker())
+	default:
+		rw.w.Object(name, nil)
+	}
+}
+
+// Map redacts a matched map as a whole: it is written empty, or as the
+// marker when printed.
+func (rw *redactWriter) Map(name string, n int, key, value Kind) model.ArrayWriter {
+	path := append(rw.path[:len(rw.path):len(rw.path)], name)
+	switch {
+	case !rw.r.match(path):
+		return &redactArrayWriter{r: rw.r, aw: startMap(rw.w, name, n, key, value), path: path}
+	case rw.printed():
+		rw.w.Raw(name, rw.r.marker())
+	default:
+		startMap(rw.w, name, 0, key, value)
+	}
+	return discardArrayWriter{}
+}
+
+func (rw *redactWriter) Array(name string, n int) model.ArrayWriter {
+	path := append(rw.path[:len(rw.path):len(rw.path)], name)
+	redacted := rw.r.match(path)
+	if redacted && rw.printed() {
+		rw.w.Raw(name, rw.r.marker())
+		return discardArrayWriter{}
+	}
+	return &redactArrayWriter{r: rw.r, aw: rw.w.Array(name, n), path: path, redacted: redacted}
+}
+
+// redactArrayWriter passes elements to aw, redacting all of them when the
+// array itself is matched, and the matching fields of object elements
+// otherwise.
+type redactArrayWriter struct {
+	r        *Redactor
+	aw       model.ArrayWriter
+	path     []string
+	redacted bool
+}
+
+func (a *redactArrayWriter) String(val string) {
+	if a.redacted {
+		val = a.r.text([]byte(val))
+	}
+	a.aw.String(val)
+}
+
+func (a *redactArrayWriter) Int(val int64) {
+	if a.redacted {
+		val = int64(a.r.number(uint64(val)))
+	}
+	a.aw.Int(val)
+}
+
+func (a *redactArrayWriter) Float(val float64) {
+	if a.redacted {
+		val = float64(a.r.number(math.Float64bits(val)))
+	}
+	a.aw.Float(val)
+}
+
+func (a *redactArrayWriter) Float32(val float32) {
+	if a.redacted {
+		val = float32(a.r.number(uint64(math.Float32bits(val))))
+	}
+	float32Elem(a.aw, val)
+}
+
+func (a *redactArrayWriter) Fixed32(val uint32) {
+	if a.redacted {
+		val = uint32(a.r.number(uint64(val)))
+	}
+	fixed32Elem(a.aw, val)
+}
+
+func (a *redactArrayWriter) Fixed64(val uint64) {
+	if a.redacted {
+		val = a.r.number(val)
+	}
+	fixed64Elem(a.aw, val)
+}
+
+func (a *redactArrayWriter) Bool(val bool) {
+	a.aw.Bool(val && !a.redacted)
+}
+
+func (a *redactArrayWriter) Bytes(val []byte) {
+	if a.redacted {
+		val = []byte(a.r.text(val))
+	}
+	a.aw.Bytes(val)
+}
+
+func (a *redactArrayWriter) Object(val model.Encodable) {
+	switch {
+	case a.redacted:
+		a.aw.Object(nil)
+	case val == nil || val.IsNil():
+		a.aw.Object(val)
+	default:
+		a.aw.Object(&redactObject{r: a.r, v: val, path: a.path})
+	}
+}
+
+func (a *redactArrayWriter) Close() {
+	a.aw.Close()
+}
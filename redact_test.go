@@ -0,0 +1,118 @@
+package binary
+
+import (
+	"strings"
+	"testing"
+)
+
+func redactValue() *FixtureComplex {
+	return &FixtureComplex{
+		ID: 7,
+		Primary: FixtureBasic{
+			Name:    "alice",
+			Payload: []byte("secret"),
+			Tags:    []uint32{1, 2},
+			Active:  true,
+			Score:   2.5,
+		},
+		Secondary: &FixtureBasic{Name: "bob", Count: 3},
+		List:      []FixtureBasic{{Name: "carol", Score: 1, Active: true}},
+		Matrix:    [3]int{4, 5, 6},
+	}
+}
+
+func TestRedactSprint(t *testing.T) {
+	got := Sprint(Redact(redactValue(), "Name", "Primary.Payload", "*.Tags", "List[].Score", "Secondary"))
+	want := `{ID: 7, Primary: {Name: [REDACTED], Timestamp: 0, Payload: [REDACTED], Tags: [REDACTED], Count: 0, Active: true, Score: 2.5}, ` +
+		`Secondary: [REDACTED], ` +
+		`List: [{Name: [REDACTED], Timestamp: 0, Payload: 0x, Tags: [REDACTED], Count: 0, Active: true, Score: [REDACTED]}], ` +
+		`Matrix: [4, 5, 6]}`
+	if got != want {
+		t.Errorf("Expected\n%s\ngot\n%s", want, got)
+	}
+}
+
+func TestRedactEncode(t *testing.T) {
+	var data []byte
+	if err := Encode(Redact(redactValue(), "Name", "Primary.Payload", "Primary.Tags", "List.*", "Matrix"), &data); err != nil {
+		t.Fatalf("Encode failed: %v", err)
+	}
+	decoded := &FixtureComplex{}
+	if err := Decode(data, decoded); err != nil {
+		t.Fatalf("Decode failed: %v", err)
+	}
+
+	p := decoded.Primary
+	if p.Name != DefaultRedactMarker || string(p.Payload) != DefaultRedactMarker {
+		t.Errorf("Expected redacted Primary strings, got %+v", p)
+	}
+	if len(p.Tags) != 2 || p.Tags[0] != 0 || p.Tags[1] != 0 || !p.Active || p.Score != 2.5 {
+		t.Errorf("Unexpected Primary: %+v", p)
+	}
+	if decoded.Secondary == nil || decoded.Secondary.Name != DefaultRedactMarker || decoded.Secondary.Count != 3 {
+		t.Errorf("Unexpected Secondary: %+v", decoded.Secondary)
+	}
+	if l := decoded.List[0]; l.Name != DefaultRedactMarker || l.Score != 0 || l.Active {
+		t.Errorf("Expected every List field redacted, got %+v", l)
+	}
+	if decoded.ID != 7 || decoded.Matrix != [3]int{} {
+		t.Errorf("Unexpected ID or Matrix: %d %v", decoded.ID, decoded.Matrix)
+	}
+
+	// The source value is untouched.
+	if v := redactValue(); v.Primary.Name != "alice" {
+		t.Errorf("Source modified: %+v", v)
+	}
+}
+
+func TestRedactKey(t *testing.T) {
+	encode := func(r *Redactor, v *FixtureComplex) *FixtureComplex {
+		t.Helper()
+		var data []byte
+		if err := Encode(r.Encodable(v), &data); err != nil {
+			t.Fatalf("Encode failed: %v", err)
+		}
+		out := &FixtureComplex{}
+		if err := Decode(data, out); err != nil {
+			t.Fatalf("Decode failed: %v", err)
+		}
+		return out
+	}
+	r := NewRedactor("Name")
+	r.Key = []byte("key one")
+	b := redactValue()
+	b.Secondary.Name = "alice"
+	ga, gb := encode(r, redactValue()), encode(r, b)
+
+	name := ga.Primary.Name
+	if len(name) != 17 || !strings.HasPrefix(name, "#") || strings.Contains(name, "alice") {
+		t.Errorf("Expected a keyed hash, got %q", name)
+	}
+	if name != gb.Secondary.Name || name == ga.Secondary.Name {
+		t.Errorf("Expected equal values to hash equally: %q %q %q", name, gb.Secondary.Name, ga.Secondary.Name)
+	}
+
+	// Another key gives another hash, and Sprint still shows the marker.
+	other := NewRedactor("Name")
+	other.Key = []byte("key two")
+	if got := encode(other, redactValue()).Primary.Name; got == name {
+		t.Errorf("Expected the hash to depend on the key, got %q for both", got)
+	}
+	if got := Sprint(r.Encodable(redactValue())); !strings.Contains(got, "Name: [REDACTED]") || strings.Contains(got, "#") {
+		t.Errorf("Expected the marker when printed, got %s", got)
+	}
+}
+
+func TestRedactWriter(t *testing.T) {
+	s := &sizeWriter{}
+	s.aw.s = s
+	r := NewRedactor("Payload")
+	r.Marker = "x"
+	redactValue().EncodeFields(r.Writer(s))
+	// Primary, Secondary and List[0] each carry a Payload.
+	want, _ := Size(redactValue())
+	want += -len("secret") + 3*len("x")
+	if s.n != want {
+		t.Errorf("Expected size %d, got %d", want, s.n)
+	}
+}
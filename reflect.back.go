@@ -0,0 +1,383 @@
+//go:build !wasm && !tinygo
+
+package binary
+
+import (
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tinywasm/model"
+)
+
+// Reflect adapts a struct without codec methods to model.Encodable and
+// model.Decodable using reflection. Exported fields are walked in
+// declaration order with the same rules as cmd/binarygen: fields tagged
+// `binary:"-"` or `json:"-"` are skipped, `binary:"name"` renames a field,
+// unsigned integers are written with Int, and nested structs that implement
+// model.Encodable / model.Decodable themselves are encoded with their own
+// methods. time.Time fields are written like WriteTime. The bytes produced
+// match a correctly hand-written EncodeFields.
+//
+// v should be a pointer to a struct; a struct value is copied and can only
+// be encoded. Reflect panics when v is not a struct or holds a field type
+// the wire format cannot represent, such as a map or a pointer to a
+// non-struct. It is not available in wasm and TinyGo builds.
+func Reflect(v any) interface {
+	model.Encodable
+	model.Decodable
+} {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() == reflect.Struct {
+		p := reflect.New(rv.Type())
+		p.Elem().Set(rv)
+		rv = p
+	}
+	if rv.Kind() != reflect.Pointer || rv.Type().Elem().Kind() != reflect.Struct {
+		panic("binary.Reflect: " + typeName(rv) + " is not a struct or pointer to struct")
+	}
+	return &reflectCodec{ptr: rv, plan: planOf(rv.Type().Elem())}
+}
+
+func typeName(rv reflect.Value) string {
+	if !rv.IsValid() {
+		return "nil"
+	}
+	return rv.Type().String()
+}
+
+// reflectCodec encodes and decodes the struct ptr points to.
+type reflectCodec struct {
+	ptr  reflect.Value
+	plan *reflectPlan
+}
+
+// IsNil implements model.Encodable and model.Decodable
+func (c *reflectCodec) IsNil() bool {
+	return c == nil || c.ptr.IsNil()
+}
+
+// EncodeFields implements model.Encodable
+func (c *reflectCodec) EncodeFields(w model.FieldWriter) {
+	sv := c.ptr.Elem()
+	for _, f := range c.plan.fields {
+		fv := sv.Field(f.index)
+		switch t := f.typ; t.kind {
+		case rSlice, rArray:
+			aw := w.Array(f.name, fv.Len())
+			for i := 0; i < fv.Len(); i++ {
+				writeElem(aw, t.elem, fv.Index(i))
+			}
+		case rString:
+			w.String(f.name, fv.String())
+		case rInt:
+			w.Int(f.name, fv.Int())
+		case rUint:
+			w.Int(f.name, int64(fv.Uint()))
+		case rFloat:
+			w.Float(f.name, fv.Float())
+		case rBool:
+			w.Bool(f.name, fv.Bool())
+		case rBytes:
+			w.Bytes(f.name, fv.Bytes())
+		case rStruct:
+			w.Object(f.name, encodableOf(fv.Addr(), t))
+		case rPtr:
+			w.Object(f.name, encodableOf(fv, t.elem))
+		}
+	}
+}
+
+func writeElem(aw model.ArrayWriter, t *reflectType, ev reflect.Value) {
+	switch t.kind {
+	case rString:
+		aw.String(ev.String())
+	case rInt:
+		aw.Int(ev.Int())
+	case rUint:
+		aw.Int(int64(ev.Uint()))
+	case rFloat:
+		aw.Float(ev.Float())
+	case rBool:
+		aw.Bool(ev.Bool())
+	case rBytes:
+		aw.Bytes(ev.Bytes())
+	case rStruct:
+		aw.Object(encodableOf(ev.Addr(), t))
+	case rPtr:
+		aw.Object(encodableOf(ev, t.elem))
+	}
+}
+
+// DecodeFields implements model.Decodable
+func (c *reflectCodec) DecodeFields(r model.FieldReader) {
+	sv := c.ptr.Elem()
+	for _, f := range c.plan.fields {
+		fv := sv.Field(f.index)
+		switch t := f.typ; t.kind {
+		case rString:
+			if v, ok := r.String(f.name); ok {
+				fv.SetString(v)
+			}
+		case rInt:
+			if v, ok := r.Int(f.name); ok {
+				fv.SetInt(v)
+			}
+		case rUint:
+			if v, ok := r.Int(f.name); ok {
+				fv.SetUint(uint64(v))
+			}
+		case rFloat:
+			if v, ok := r.Float(f.name); ok {
+				fv.SetFloat(v)
+			}
+		case rBool:
+			if v, ok := r.Bool(f.name); ok {
+				fv.SetBool(v)
+			}
+		case rBytes:
+			if v, ok := r.Bytes(f.name); ok {
+				setBytes(fv, v)
+			}
+		case rStruct:
+			r.Object(f.name, decodableOf(fv.Addr(), t))
+		case rPtr:
+			nv := reflect.New(fv.Type().Elem())
+			if r.Object(f.name, decodableOf(nv, t.elem)) {
+				fv.Set(nv)
+			} else {
+				fv.SetZero()
+			}
+		case rSlice:
+			if ar, ok := r.Array(f.name); ok {
+				if n := ar.Len(); n > 0 {
+					s := reflect.MakeSlice(fv.Type(), n, n)
+					for i := 0; i < n; i++ {
+						readElem(ar, i, t.elem, s.Index(i))
+					}
+					fv.Set(s)
+				} else {
+					fv.SetZero()
+				}
+			}
+		case rArray:
+			if ar, ok := r.Array(f.name); ok {
+				for i := 0; i < ar.Len(); i++ {
+					if i < fv.Len() {
+						readElem(ar, i, t.elem, fv.Index(i))
+					} else {
+						readElem(ar, i, t.elem, reflect.New(fv.Type().Elem()).Elem())
+					}
+				}
+			}
+		}
+	}
+}
+
+func readElem(ar model.ArrayReader, i int, t *reflectType, ev reflect.Value) {
+	switch t.kind {
+	case rString:
+		ev.SetString(ar.String(i))
+	case rInt:
+		ev.SetInt(ar.Int(i))
+	case rUint:
+		ev.SetUint(uint64(ar.Int(i)))
+	case rFloat:
+		ev.SetFloat(ar.Float(i))
+	case rBool:
+		ev.SetBool(ar.Bool(i))
+	case rBytes:
+		setBytes(ev, ar.Bytes(i))
+	case rStruct:
+		ar.Object(i, decodableOf(ev.Addr(), t))
+	case rPtr:
+		nv := reflect.New(ev.Type().Elem())
+		if ar.Object(i, decodableOf(nv, t.elem)) {
+			ev.Set(nv)
+		} else {
+			ev.SetZero()
+		}
+	}
+}
+
+// setBytes stores b in a []byte-like slice or copies it into a zeroed byte
+// array.
+func setBytes(v reflect.Value, b []byte) {
+	if v.Kind() == reflect.Array {
+		v.SetZero()
+		reflect.Copy(v, reflect.ValueOf(b))
+		return
+	}
+	v.SetBytes(b)
+}
+
+// encodableOf returns the codec for a pointer to a struct, preferring the
+// type's own methods.
+func encodableOf(ptr reflect.Value, t *reflectType) model.Encodable {
+	if t.time {
+		return TimeOf(ptr.Interface().(*time.Time))
+	}
+	if t.ownEncode {
+		return ptr.Interface().(model.Encodable)
+	}
+	return &reflectCodec{ptr: ptr, plan: t.plan}
+}
+
+func decodableOf(ptr reflect.Value, t *reflectType) model.Decodable {
+	if t.time {
+		return TimeOf(ptr.Interface().(*time.Time))
+	}
+	if t.ownDecode {
+		return ptr.Interface().(model.Decodable)
+	}
+	return &reflectCodec{ptr: ptr, plan: t.plan}
+}
+
+// --- plans ---
+
+type rkind uint8
+
+const (
+	rString rkind = iota
+	rInt
+	rUint
+	rFloat
+	rBool
+	rBytes // []byte or [N]byte
+	rStruct
+	rPtr // pointer to struct
+	rSlice
+	rArray
+)
+
+// reflectPlan lists the encoded fields of a struct type.
+type reflectPlan struct {
+	fields []reflectField
+}
+
+type reflectField struct {
+	index int
+	name  string
+	typ   *reflectType
+}
+
+// reflectType describes how values of one Go type are written.
+type reflectType struct {
+	kind      rkind
+	elem      *reflectType // element of rSlice and rArray, target of rPtr
+	plan      *reflectPlan // fields of rStruct
+	ownEncode bool         // *T implements model.Encodable
+	ownDecode bool         // *T implements model.Decodable
+	time      bool         // time.Time, written like WriteTime
+}
+
+var (
+	planMu sync.Mutex
+	plans  = map[reflect.Type]*reflectPlan{}
+
+	byteType      = reflect.TypeFor[byte]()
+	timeType      = reflect.TypeFor[time.Time]()
+	encodableType = reflect.TypeFor[model.Encodable]()
+	decodableType = reflect.TypeFor[model.Decodable]()
+)
+
+func planOf(t reflect.Type) *reflectPlan {
+	planMu.Lock()
+	defer planMu.Unlock()
+	pending := map[reflect.Type]*reflectPlan{}
+	p := buildPlan(t, pending)
+	for pt, pp := range pending {
+		plans[pt] = pp
+	}
+	return p
+}
+
+// buildPlan must be called with planMu held. New plans go to pending before
+// their fields are filled in, so recursive types terminate, and are only
+// cached once the whole type is known to be supported.
+func buildPlan(t reflect.Type, pending map[reflect.Type]*reflectPlan) *reflectPlan {
+	if p, ok := plans[t]; ok {
+		return p
+	}
+	if p, ok := pending[t]; ok {
+		return p
+	}
+	p := &reflectPlan{}
+	pending[t] = p
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if !sf.IsExported() {
+			continue
+		}
+		name, skip := reflectTag(sf.Tag)
+		if skip {
+			continue
+		}
+		if name == "" {
+			name = sf.Name
+		}
+		rt, ok := typeOf(sf.Type, pending)
+		if !ok {
+			panic("binary.Reflect: " + t.String() + "." + sf.Name + ": unsupported type " + sf.Type.String())
+		}
+		p.fields = append(p.fields, reflectField{index: i, name: name, typ: rt})
+	}
+	return p
+}
+
+// reflectTag returns the wire name from a `binary:"name"` tag and whether
+// the field is skipped by `binary:"-"` or `json:"-"`.
+func reflectTag(tag reflect.StructTag) (string, bool) {
+	b := tag.Get("binary")
+	if b == "-" || tag.Get("json") == "-" {
+		return "", true
+	}
+	name, _, _ := strings.Cut(b, ",")
+	return name, false
+}
+
+func typeOf(t reflect.Type, pending map[reflect.Type]*reflectPlan) (*reflectType, bool) {
+	switch t.Kind() {
+	case reflect.String:
+		return &reflectType{kind: rString}, true
+	case reflect.Bool:
+		return &reflectType{kind: rBool}, true
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return &reflectType{kind: rInt}, true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return &reflectType{kind: rUint}, true
+	case reflect.Float32, reflect.Float64:
+		return &reflectType{kind: rFloat}, true
+	case reflect.Struct:
+		if t == timeType {
+			return &reflectType{kind: rStruct, time: true}, true
+		}
+		pt := reflect.PointerTo(t)
+		rt := &reflectType{kind: rStruct, ownEncode: pt.Implements(encodableType), ownDecode: pt.Implements(decodableType)}
+		if !rt.ownEncode || !rt.ownDecode {
+			rt.plan = buildPlan(t, pending)
+		}
+		return rt, true
+	case reflect.Pointer:
+		if t.Elem().Kind() != reflect.Struct {
+			return nil, false
+		}
+		elem, _ := typeOf(t.Elem(), pending)
+		return &reflectType{kind: rPtr, elem: elem}, true
+	case reflect.Slice, reflect.Array:
+		if t.Elem() == byteType {
+			return &reflectType{kind: rBytes}, true
+		}
+		elem, ok := typeOf(t.Elem(), pending)
+		if !ok || elem.kind == rSlice || elem.kind == rArray || elem.kind == rBytes {
+			return nil, false
+		}
+		kind := rSlice
+		if t.Kind() == reflect.Array {
+			kind = rArray
+		}
+		return &reflectType{kind: kind, elem: elem}, true
+	}
+	return nil, false
+}
@@ -0,0 +1,194 @@
+//go:build !wasm && !tinygo
+
+package binary
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tinywasm/model"
+)
+
+// plainBasic has FixtureBasic's layout but no codec methods.
+type plainBasic struct {
+	Name      string
+	Timestamp int64
+	Payload   []byte
+	Tags      []uint32
+	Count     int16
+	Active    bool
+	Score     float64
+}
+
+type plainComplex struct {
+	ID        uint64
+	Primary   plainBasic
+	Secondary *plainBasic
+	List      []plainBasic
+	Matrix    [3]int
+	Skipped   string `binary:"-"`
+	Ignored   string `json:"-"`
+	private   string
+}
+
+func TestReflectMatchesHandWritten(t *testing.T) {
+	hand := &FixtureComplex{
+		ID:        1<<64 - 1,
+		Primary:   FixtureBasic{Name: "Alice", Timestamp: -5, Payload: []byte{1}, Tags: []uint32{1, 2}, Count: -3, Active: true, Score: 1.5},
+		Secondary: &FixtureBasic{Name: "Bob"},
+		List:      []FixtureBasic{{Name: "x"}, {Tags: []uint32{9}}},
+		Matrix:    [3]int{1, -2, 3},
+	}
+	plain := &plainComplex{
+		ID:        1<<64 - 1,
+		Primary:   plainBasic{Name: "Alice", Timestamp: -5, Payload: []byte{1}, Tags: []uint32{1, 2}, Count: -3, Active: true, Score: 1.5},
+		Secondary: &plainBasic{Name: "Bob"},
+		List:      []plainBasic{{Name: "x"}, {Tags: []uint32{9}}},
+		Matrix:    [3]int{1, -2, 3},
+		Skipped:   "s",
+		Ignored:   "i",
+		private:   "p",
+	}
+
+	var want, got []byte
+	if err := Encode(hand, &want); err != nil {
+		t.Fatal(err)
+	}
+	if err := Encode(Reflect(plain), &got); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(want, got) {
+		t.Fatalf("Expected %x, got %x", want, got)
+	}
+
+	// Reflect over a type with codec methods walks its fields the same way.
+	if err := Encode(Reflect(hand), &got); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(want, got) {
+		t.Errorf("Expected %x, got %x", want, got)
+	}
+
+	decoded := &plainComplex{Secondary: &plainBasic{}}
+	if err := Decode(want, Reflect(decoded)); err != nil {
+		t.Fatal(err)
+	}
+	plain.Skipped, plain.Ignored, plain.private = "", "", ""
+	if !reflect.DeepEqual(plain, decoded) {
+		t.Errorf("Expected %+v, got %+v", plain, decoded)
+	}
+}
+
+type reflectNode struct {
+	Value int
+	Next  *reflectNode
+	Hash  [2]byte
+	Alias string `binary:"name"`
+}
+
+func TestReflectRecursive(t *testing.T) {
+	in := &reflectNode{Value: 1, Next: &reflectNode{Value: 2}, Hash: [2]byte{7, 8}, Alias: "a"}
+	var data []byte
+	if err := Encode(Reflect(in), &data); err != nil {
+		t.Fatal(err)
+	}
+	out := &reflectNode{}
+	if err := Decode(data, Reflect(out)); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(in, out) {
+		t.Errorf("Expected %+v, got %+v", in, out)
+	}
+	if f := SchemaOf(Reflect(in)).Fields[3]; f.Name != "name" {
+		t.Errorf("Expected renamed field, got %q", f.Name)
+	}
+
+	// A struct value can be encoded.
+	var fromValue []byte
+	if err := Encode(Reflect(*in), &fromValue); err != nil || !bytes.Equal(data, fromValue) {
+		t.Errorf("Expected struct value to encode the same, got %x, %v", fromValue, err)
+	}
+
+	// A shorter byte array value leaves no stale bytes.
+	var short []byte
+	if err := EncodeTagged(&reflectShortHash{}, &short); err != nil {
+		t.Fatal(err)
+	}
+	if err := DecodeTagged(short, Reflect(out)); err != nil || out.Hash != [2]byte{1} {
+		t.Errorf("Expected Hash [1 0], got %v, %v", out.Hash, err)
+	}
+}
+
+// reflectShortHash writes a Hash shorter than reflectNode's.
+type reflectShortHash struct{}
+
+func (*reflectShortHash) IsNil() bool { return false }
+
+func (*reflectShortHash) EncodeFields(w model.FieldWriter) {
+	w.Bytes("Hash", []byte{1})
+}
+
+func TestReflectUnsupported(t *testing.T) {
+	type withMap struct{ M map[string]int }
+	type withIntPtr struct{ P *int }
+	type nestedBad struct{ Inner withMap }
+
+	for name, v := range map[string]any{
+		"map":     &withMap{},
+		"int ptr": &withIntPtr{},
+		"nested":  &nestedBad{},
+		"int":     42,
+		"nil":     nil,
+	} {
+		func() {
+			defer func() {
+				r := recover()
+				if msg, _ := r.(string); !strings.HasPrefix(msg, "binary.Reflect: ") {
+					t.Errorf("%s: expected a binary.Reflect panic, got %v", name, r)
+				}
+			}()
+			Reflect(v)
+		}()
+	}
+
+	// A failed plan is not cached.
+	func() {
+		defer func() { recover() }()
+		Reflect(&nestedBad{})
+	}()
+	planMu.Lock()
+	_, cached := plans[reflect.TypeFor[nestedBad]()]
+	planMu.Unlock()
+	if cached {
+		t.Error("Expected unsupported type not to be cached")
+	}
+}
+
+func TestReflectTime(t *testing.T) {
+	type plainTimes struct {
+		When    time.Time
+		Timeout time.Duration
+		History []time.Time
+	}
+	when := time.Date(1500, 3, 4, 5, 6, 7, 8, time.FixedZone("X", -7200))
+	hand := &fixtureTimes{When: when, Timeout: time.Minute, History: []time.Time{{}, when}}
+	plain := &plainTimes{When: when, Timeout: time.Minute, History: []time.Time{{}, when}}
+
+	var want, got []byte
+	if err := Encode(hand, &want); err != nil {
+		t.Fatal(err)
+	}
+	if err := Encode(Reflect(plain), &got); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(want, got) {
+		t.Fatalf("Expected %x, got %x", want, got)
+	}
+	out := &plainTimes{}
+	if err := Decode(got, Reflect(out)); err != nil || !out.When.Equal(when) || out.Timeout != time.Minute || !out.History[0].IsZero() {
+		t.Errorf("Expected %+v, got %+v, %v", plain, out, err)
+	}
+}
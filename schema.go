@@ -0,0 +1,229 @@
+package binary
+
+import "github.com/tinywasm/model"
+
+// Kind identifies which writer method produced a field, and therefore how
+// it is laid out on the wire.
+type Kind uint8
+
+const (
+	KindInvalid Kind = iota // unknown, e.g. the element kind of an empty array
+	KindString
+	KindRaw
+	KindInt
+	KindUint
+	KindFloat
+	KindBool
+	KindBytes
+	KindNull
+	KindObject
+	KindArray
+	KindOptional // written through OptionalWriter; Elem holds the value kind, Schema describes an Object, Array or Map value
+	KindMap      // written through MapWriter; Key and Elem hold the key and value kinds
+	KindFloat32  // written through CompactWriter, as are KindFixed32 and KindFixed64
+	KindFixed32
+	KindFixed64
+)
+
+var kindNames = []string{"invalid", "string", "raw", "int", "uint", "float", "bool", "bytes", "null", "object", "array", "optional", "map", "float32", "fixed32", "fixed64"}
+
+func (k Kind) String() string {
+	if int(k) < len(kindNames) {
+		return kindNames[k]
+	}
+	return "unknown"
+}
+
+// Schema is the ordered list of fields a type writes in EncodeFields.
+// It is itself Encodable, so it can be stored or sent next to the data.
+type Schema struct {
+	Fields []SchemaField
+}
+
+// SchemaField describes one field of a Schema.
+type SchemaField struct {
+	Name   string
+	Kind   Kind
+	Elem   Kind    // element kind of a KindArray, value kind of a KindOptional or KindMap
+	Schema *Schema // nested fields of a KindObject, or of KindObject elements and values; see KindOptional
+	Key    Kind    // key kind of a KindMap
+}
+
+// SchemaOf records the fields v writes in EncodeFields. Nested schemas are
+// taken from the values present in v: a nil Object has no Schema and an
+// empty Array has a KindInvalid Elem, so capture from a fully populated value.
+func SchemaOf(v model.Encodable) *Schema {
+	s := &Schema{}
+	if v != nil && !v.IsNil() {
+		v.EncodeFields(&schemaWriter{s: s})
+	}
+	return s
+}
+
+// Field returns the field called name.
+func (s *Schema) Field(name string) (SchemaField, bool) {
+	for _, f := range s.Fields {
+		if f.Name == name {
+			return f, true
+		}
+	}
+	return SchemaField{}, false
+}
+
+// EncodeFields implements model.Encodable
+func (s *Schema) EncodeFields(w model.FieldWriter) {
+	aw := w.Array("Fields", len(s.Fields))
+	for i := range s.Fields {
+		aw.Object(&s.Fields[i])
+	}
+}
+
+// DecodeFields implements model.Decodable
+func (s *Schema) DecodeFields(r model.FieldReader) {
+	if ar, ok := r.Array("Fields"); ok {
+		s.Fields = make([]SchemaField, ar.Len())
+		for i := range s.Fields {
+			ar.Object(i, &s.Fields[i])
+		}
+	}
+}
+
+// IsNil implements model.Encodable and model.Decodable
+func (s *Schema) IsNil() bool {
+	return s == nil
+}
+
+// EncodeFields implements model.Encodable
+func (f *SchemaField) EncodeFields(w model.FieldWriter) {
+	w.String("Name", f.Name)
+	w.Int("Kind", int64(f.Kind))
+	w.Int("Elem", int64(f.Elem))
+	w.Object("Schema", f.Schema)
+	w.Int("Key", int64(f.Key))
+}
+
+// DecodeFields implements model.Decodable
+func (f *SchemaField) DecodeFields(r model.FieldReader) {
+	if v, ok := r.String("Name"); ok {
+		f.Name = v
+	}
+	if v, ok := r.Int("Kind"); ok {
+		f.Kind = Kind(v)
+	}
+	if v, ok := r.Int("Elem"); ok {
+		f.Elem = Kind(v)
+	}
+	f.Schema = &Schema{}
+	if !r.Object("Schema", f.Schema) {
+		f.Schema = nil
+	}
+	if v, ok := r.Int("Key"); ok {
+		f.Key = Kind(v)
+	}
+}
+
+// IsNil implements model.Encodable and model.Decodable
+func (f *SchemaField) IsNil() bool {
+	return f == nil
+}
+
+// schemaWriter is a model.FieldWriter that records field names and kinds.
+type schemaWriter struct {
+	s        *Schema
+	optional bool // the last field is a present KindOptional awaiting its value
+}
+
+func (w *schemaWriter) add(name string, kind Kind) *SchemaField {
+	if w.optional {
+		w.optional = false
+		f := &w.s.Fields[len(w.s.Fields)-1]
+		f.Elem = kind
+		return f
+	}
+	w.s.Fields = append(w.s.Fields, SchemaField{Name: name, Kind: kind})
+	return &w.s.Fields[len(w.s.Fields)-1]
+}
+
+// Present records a KindOptional field. The value kind is only known when
+// the value is present. A present Array or Map value is recorded as the
+// single field of the optional's Schema, so it keeps its element kinds.
+func (w *schemaWriter) Present(name string, ok bool) {
+	w.add(name, KindOptional)
+	w.optional = ok
+}
+
+func (w *schemaWriter) String(name, val string)        { w.add(name, KindString) }
+func (w *schemaWriter) Raw(name, val string)           { w.add(name, KindRaw) }
+func (w *schemaWriter) Int(name string, val int64)     { w.add(name, KindInt) }
+func (w *schemaWriter) Uint(name string, val uint64)   { w.add(name, KindUint) }
+func (w *schemaWriter) Float(name string, val float64) { w.add(name, KindFloat) }
+func (w *schemaWriter) Bool(name string, val bool)     { w.add(name, KindBool) }
+func (w *schemaWriter) Bytes(name string, val []byte)  { w.add(name, KindBytes) }
+func (w *schemaWriter) Null(name string)               { w.add(name, KindNull) }
+
+func (w *schemaWriter) Float32(name string, val float32) { w.add(name, KindFloat32) }
+func (w *schemaWriter) Fixed32(name string, val uint32)  { w.add(name, KindFixed32) }
+func (w *schemaWriter) Fixed64(name string, val uint64)  { w.add(name, KindFixed64) }
+
+func (w *schemaWriter) Object(name string, val model.Encodable) {
+	f := w.add(name, KindObject)
+	if val != nil && !val.IsNil() {
+		f.Schema = SchemaOf(val)
+	}
+}
+
+// Map records a KindMap field; the schema of Object values is taken from
+// the first one written.
+func (w *schemaWriter) Map(name string, n int, key, value Kind) model.ArrayWriter {
+	a := w.open(name, KindMap)
+	f := &a.s.Fields[a.i]
+	f.Key, f.Elem = key, value
+	return a
+}
+
+func (w *schemaWriter) Array(name string, n int) model.ArrayWriter {
+	return w.open(name, KindArray)
+}
+
+// open adds an Array or Map field and returns the writer that records its
+// elements.
+func (w *schemaWriter) open(name string, kind Kind) *schemaArrayWriter {
+	s := w.s
+	if w.optional {
+		f := w.add(name, kind)
+		f.Schema = &Schema{}
+		s = f.Schema
+	}
+	s.Fields = append(s.Fields, SchemaField{Name: name, Kind: kind})
+	return &schemaArrayWriter{s: s, i: len(s.Fields) - 1}
+}
+
+// schemaArrayWriter records the element kind of an array from its elements.
+type schemaArrayWriter struct {
+	s *Schema
+	i int // index of the array field; the slice may grow while elements are written
+}
+
+func (a *schemaArrayWriter) elem(kind Kind) {
+	if f := &a.s.Fields[a.i]; f.Elem == KindInvalid {
+		f.Elem = kind
+	}
+}
+
+func (a *schemaArrayWriter) String(val string) { a.elem(KindString) }
+func (a *schemaArrayWriter) Int(val int64)     { a.elem(KindInt) }
+func (a *schemaArrayWriter) Float(val float64) { a.elem(KindFloat) }
+func (a *schemaArrayWriter) Bool(val bool)     { a.elem(KindBool) }
+func (a *schemaArrayWriter) Bytes(val []byte)  { a.elem(KindBytes) }
+func (a *schemaArrayWriter) Close()            {}
+
+func (a *schemaArrayWriter) Float32(val float32) { a.elem(KindFloat32) }
+func (a *schemaArrayWriter) Fixed32(val uint32)  { a.elem(KindFixed32) }
+func (a *schemaArrayWriter) Fixed64(val uint64)  { a.elem(KindFixed64) }
+
+func (a *schemaArrayWriter) Object(val model.Encodable) {
+	a.elem(KindObject)
+	if f := &a.s.Fields[a.i]; f.Schema == nil && val != nil && !val.IsNil() {
+		f.Schema = SchemaOf(val)
+	}
+}
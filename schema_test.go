@@ -0,0 +1,73 @@
+package binary
+
+import (
+	"reflect"
+	"testing"
+)
+
+func complexSchemaValue() *FixtureComplex {
+	return &FixtureComplex{
+		Primary:   FixtureBasic{Tags: []uint32{1}},
+		Secondary: &FixtureBasic{},
+		List:      []FixtureBasic{{}},
+	}
+}
+
+func TestSchemaOf(t *testing.T) {
+	basic := &Schema{Fields: []SchemaField{
+		{Name: "Name", Kind: KindString},
+		{Name: "Timestamp", Kind: KindInt},
+		{Name: "Payload", Kind: KindBytes},
+		{Name: "Tags", Kind: KindArray, Elem: KindInt},
+		{Name: "Count", Kind: KindInt},
+		{Name: "Active", Kind: KindBool},
+		{Name: "Score", Kind: KindFloat},
+	}}
+	emptyTags := &Schema{Fields: append([]SchemaField(nil), basic.Fields...)}
+	emptyTags.Fields[3].Elem = KindInvalid
+
+	want := &Schema{Fields: []SchemaField{
+		{Name: "ID", Kind: KindInt},
+		{Name: "Primary", Kind: KindObject, Schema: basic},
+		{Name: "Secondary", Kind: KindObject, Schema: emptyTags},
+		{Name: "List", Kind: KindArray, Elem: KindObject, Schema: emptyTags},
+		{Name: "Matrix", Kind: KindArray, Elem: KindInt},
+	}}
+
+	got := SchemaOf(complexSchemaValue())
+	if !reflect.DeepEqual(want, got) {
+		t.Errorf("Expected %+v, got %+v", want, got)
+	}
+
+	// Nil objects and empty arrays leave the nested information unknown.
+	sparse := SchemaOf(&FixtureComplex{})
+	if f, _ := sparse.Field("Secondary"); f.Schema != nil {
+		t.Errorf("Expected no schema for nil Secondary, got %+v", f.Schema)
+	}
+	if f, _ := sparse.Field("List"); f.Elem != KindInvalid {
+		t.Errorf("Expected unknown element kind for empty List, got %v", f.Elem)
+	}
+	if _, ok := sparse.Field("Missing"); ok {
+		t.Error("Expected Missing field to be absent")
+	}
+}
+
+func TestSchemaRoundTrip(t *testing.T) {
+	s := SchemaOf(complexSchemaValue())
+	var data []byte
+	if err := Encode(s, &data); err != nil {
+		t.Fatalf("Encode failed: %v", err)
+	}
+	decoded := &Schema{}
+	if err := Decode(data, decoded); err != nil {
+		t.Fatalf("Decode failed: %v", err)
+	}
+	if !reflect.DeepEqual(s, decoded) {
+		t.Errorf("Expected %+v, got %+v", s, decoded)
+	}
+}
+
+func TestKindString(t *testing.T) {
+	assertEqual(t, "array", KindArray.String())
+	assertEqual(t, "unknown", Kind(200).String())
+}
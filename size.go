@@ -0,0 +1,114 @@
+package binary
+
+import (
+	"github.com/tinywasm/fmt"
+	"github.com/tinywasm/model"
+)
+
+// Size returns the exact number of bytes Encode would produce for v,
+// without writing anything.
+func Size(v model.Encodable) (int, error) {
+	if v == nil || v.IsNil() {
+		return 0, fmt.Err("Size: input is nil")
+	}
+	s := &sizeWriter{}
+	s.aw.s = s
+	v.EncodeFields(s)
+	return s.n, nil
+}
+
+// sizeWriter is a model.FieldWriter that mirrors binaryWriter but only
+// counts the bytes it would write.
+type sizeWriter struct {
+	n  int
+	aw sizeArrayWriter
+}
+
+func (s *sizeWriter) String(name, val string) {
+	s.n += uvarintLen(uint64(len(val))) + len(val)
+}
+
+func (s *sizeWriter) Raw(name, val string) {
+	s.String(name, val)
+}
+
+func (s *sizeWriter) Int(name string, val int64) {
+	s.n += uvarintLen(zigzag(val))
+}
+
+func (s *sizeWriter) Uint(name string, val uint64) {
+	s.n += uvarintLen(val)
+}
+
+func (s *sizeWriter) Float(name string, val float64) {
+	s.n += 8
+}
+
+func (s *sizeWriter) Float32(name string, val float32) {
+	s.n += 4
+}
+
+func (s *sizeWriter) Fixed32(name string, val uint32) {
+	s.n += 4
+}
+
+func (s *sizeWriter) Fixed64(name string, val uint64) {
+	s.n += 8
+}
+
+func (s *sizeWriter) Bool(name string, val bool) {
+	s.n++
+}
+
+func (s *sizeWriter) Bytes(name string, val []byte) {
+	s.n += uvarintLen(uint64(len(val))) + len(val)
+}
+
+func (s *sizeWriter) Null(name string) {
+	s.n++
+}
+
+func (s *sizeWriter) Present(name string, ok bool) {
+	s.n++
+}
+
+func (s *sizeWriter) Object(name string, val model.Encodable) {
+	s.n++ // presence byte
+	if val != nil && !val.IsNil() {
+		val.EncodeFields(s)
+	}
+}
+
+func (s *sizeWriter) Map(name string, n int, key, value Kind) model.ArrayWriter {
+	return s.Array(name, n)
+}
+
+func (s *sizeWriter) Array(name string, n int) model.ArrayWriter {
+	s.n += uvarintLen(uint64(n))
+	return &s.aw
+}
+
+type sizeArrayWriter struct {
+	s *sizeWriter
+}
+
+func (w *sizeArrayWriter) String(val string)          { w.s.String("", val) }
+func (w *sizeArrayWriter) Int(val int64)              { w.s.Int("", val) }
+func (w *sizeArrayWriter) Float(val float64)          { w.s.Float("", val) }
+func (w *sizeArrayWriter) Float32(val float32)        { w.s.Float32("", val) }
+func (w *sizeArrayWriter) Fixed32(val uint32)         { w.s.Fixed32("", val) }
+func (w *sizeArrayWriter) Fixed64(val uint64)         { w.s.Fixed64("", val) }
+func (w *sizeArrayWriter) Bool(val bool)              { w.s.Bool("", val) }
+func (w *sizeArrayWriter) Bytes(val []byte)           { w.s.Bytes("", val) }
+func (w *sizeArrayWriter) Object(val model.Encodable) { w.s.Object("", val) }
+func (w *sizeArrayWriter) Close()                     {}
+
+// uvarintLen returns the number of bytes writeUvarint uses for x.
+func uvarintLen(x uint64) int {
+	n := 1
+	for x >= 0x80 {
+		x >>= 7
+		n++
+	}
+	return n
+}
@@ -0,0 +1,65 @@
+package binary
+
+import (
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/tinywasm/model"
+)
+
+func TestSizeMatchesEncode(t *testing.T) {
+	values := map[string]model.Encodable{
+		"s0":      s0v,
+		"Message": &Message{Topic: "users.created", ID: 1 << 30, Payload: make([]byte, 300)},
+		"Basic": &FixtureBasic{
+			Name:      strings.Repeat("a", 200),
+			Timestamp: math.MinInt64,
+			Tags:      []uint32{0, 127, 128, 1 << 31},
+			Count:     -1,
+			Active:    true,
+			Score:     math.Pi,
+		},
+		"Complex": &FixtureComplex{
+			ID:        math.MaxUint64,
+			Primary:   FixtureBasic{Name: "Primary"},
+			Secondary: &FixtureBasic{Payload: []byte{1}},
+			List:      []FixtureBasic{{Name: "a"}, {Name: "b", Tags: []uint32{9}}},
+			Matrix:    [3]int{-64, 63, 64},
+		},
+		"NilSecondary": &FixtureComplex{},
+		"BoolSlice":    &testEncodableBoolSlice{B: []bool{true, false}},
+		"Nested":       &StructWithT1{V1: T1{ID: 1, Slice: []int{-1}}, V3: T1{Name: "x"}},
+	}
+	for name, v := range values {
+		t.Run(name, func(t *testing.T) {
+			var encoded []byte
+			if err := Encode(v, &encoded); err != nil {
+				t.Fatalf("Encode failed: %v", err)
+			}
+			n, err := Size(v)
+			assertNoError(t, err)
+			assertEqualInt(t, len(encoded), n)
+
+			buf := make([]byte, n)
+			written, err := EncodeInto(buf, v)
+			assertNoError(t, err)
+			assertEqualInt(t, n, written)
+		})
+	}
+
+	if _, err := Size(nil); err == nil {
+		t.Error("Expected error for nil input")
+	}
+}
+
+func TestUvarintLen(t *testing.T) {
+	var w binaryWriter
+	for _, x := range []uint64{0, 1, 127, 128, 16383, 16384, 1 << 35, math.MaxUint64} {
+		w.resetBuffer(nil, false)
+		w.writeUvarint(x)
+		if got := uvarintLen(x); got != len(w.buf) {
+			t.Errorf("uvarintLen(%d) = %d, want %d", x, got, len(w.buf))
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package binary
+
+import "github.com/tinywasm/model"
+
+// EncodeSlice encodes a top-level list to output (*[]byte or io.Writer). The
+// bytes are the same as an Array field of Objects: the element count
+// followed by each element, so nil elements are written as null.
+func EncodeSlice[T model.Encodable](s []T, output any) error {
+	return Encode(encodableSlice[T](s), output)
+}
+
+// DecodeSlice decodes a list written by EncodeSlice from input ([]byte or
+// io.Reader). Null elements decode as the zero T.
+//
+//	users, err := binary.DecodeSlice[User](data)
+func DecodeSlice[T any, PT interface {
+	*T
+	model.Decodable
+}](input any) ([]T, error) {
+	d := &decodableSlice[T, PT]{}
+	if err := Decode(input, d); err != nil {
+		return nil, err
+	}
+	return d.s, nil
+}
+
+type encodableSlice[T model.Encodable] []T
+
+func (s encodableSlice[T]) IsNil() bool { return false }
+
+func (s encodableSlice[T]) EncodeFields(w model.FieldWriter) {
+	aw := w.Array("", len(s))
+	for _, v := range s {
+		aw.Object(v)
+	}
+}
+
+type decodableSlice[T any, PT interface {
+	*T
+	model.Decodable
+}] struct {
+	s []T
+}
+
+func (d *decodableSlice[T, PT]) IsNil() bool { return d == nil }
+
+func (d *decodableSlice[T, PT]) DecodeFields(r model.FieldReader) {
+	ar, ok := r.Array("")
+	if !ok {
+		return
+	}
+	d.s = make([]T, ar.Len())
+	for i := range d.s {
+		ar.Object(i, PT(&d.s[i]))
+	}
+}
@@ -0,0 +1,66 @@
+package binary
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/tinywasm/model"
+)
+
+// basicList writes its elements as an Array field of Objects.
+type basicList struct{ Items []*FixtureBasic }
+
+func (l *basicList) IsNil() bool { return l == nil }
+
+func (l *basicList) EncodeFields(w model.FieldWriter) {
+	aw := w.Array("Items", len(l.Items))
+	for _, v := range l.Items {
+		aw.Object(v)
+	}
+}
+
+func TestEncodeSliceMatchesArray(t *testing.T) {
+	items := []*FixtureBasic{{Name: "a", Tags: []uint32{1}}, nil, {Name: "c", Score: 2}}
+	var want, got []byte
+	if err := Encode(&basicList{Items: items}, &want); err != nil {
+		t.Fatal(err)
+	}
+	if err := EncodeSlice(items, &got); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(want, got) {
+		t.Fatalf("Expected %x, got %x", want, got)
+	}
+
+	out, err := DecodeSlice[FixtureBasic](got)
+	if err != nil {
+		t.Fatalf("DecodeSlice failed: %v", err)
+	}
+	expected := []FixtureBasic{*items[0], {}, *items[2]}
+	if !reflect.DeepEqual(expected, out) {
+		t.Errorf("Expected %+v, got %+v", expected, out)
+	}
+
+	// Streams work too.
+	var buf bytes.Buffer
+	if err := EncodeSlice([]*FixtureBasic{}, &buf); err != nil {
+		t.Fatal(err)
+	}
+	if out, err := DecodeSlice[FixtureBasic](&buf); err != nil || len(out) != 0 {
+		t.Errorf("Expected empty slice, got %v, %v", out, err)
+	}
+}
+
+func TestDecodeSliceErrors(t *testing.T) {
+	var data []byte
+	if err := EncodeSlice([]*FixtureBasic{{Name: "abc"}}, &data); err != nil {
+		t.Fatal(err)
+	}
+	if out, err := DecodeSlice[FixtureBasic](data[:len(data)-3]); err == nil || out != nil {
+		t.Errorf("Expected error for truncated input, got %v, %v", out, err)
+	}
+	if _, err := DecodeSlice[FixtureBasic]([]byte{0xff, 0xff, 0xff, 0xff, 0x0f}); err == nil {
+		t.Error("Expected error for huge count")
+	}
+}
@@ -0,0 +1,25 @@
+package binary
+
+import "github.com/tinywasm/model"
+
+// EncodeSparse encodes input in the presence format, leaving out every
+// field that holds a zero value: empty strings and bytes, 0, +0.0, false,
+// nil objects, empty arrays, Null and absent optional values. Records that
+// are mostly defaults shrink accordingly, at the cost of one bitmap per
+// object. Decode the result with DecodeSparse.
+// input: Encodable struct
+// output: *[]byte or io.Writer
+func EncodeSparse(input model.Encodable, output any) error {
+	return encodePresence(input, &presenceWriter{sparse: true}, output)
+}
+
+// DecodeSparse decodes input written by EncodeSparse into output, enforcing
+// DefaultLimits. Omitted fields are reported to DecodeFields as zero values
+// with ok=true, so output ends up as if every field had been sent. It
+// returns the paths of the fields that were sent, which makes it usable for
+// PATCH-style updates; see DecodeMask for the path syntax.
+// input: []byte or io.Reader
+// output: pointer to Decodable struct
+func DecodeSparse(input, output any) (Mask, error) {
+	return decodePresence(input, output, &presenceReader{zeros: true})
+}
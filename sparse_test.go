@@ -0,0 +1,81 @@
+package binary
+
+import (
+	"bytes"
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestEncodeSparse(t *testing.T) {
+	v := &FixtureComplex{
+		ID:        9,
+		Primary:   FixtureBasic{Name: "alice"},
+		Secondary: &FixtureBasic{},
+		List:      []FixtureBasic{{Count: 2}, {}},
+		Matrix:    [3]int{0, 5, 0},
+	}
+	var sparse, full []byte
+	if err := EncodeSparse(v, &sparse); err != nil {
+		t.Fatalf("EncodeSparse failed: %v", err)
+	}
+	if err := Encode(v, &full); err != nil {
+		t.Fatal(err)
+	}
+	if len(sparse) >= len(full) {
+		t.Errorf("Expected sparse encoding to be smaller: %d >= %d", len(sparse), len(full))
+	}
+
+	// Omitted fields are restored as zero values, even over stale data.
+	decoded := &FixtureComplex{ID: 1, Primary: FixtureBasic{Score: 3, Tags: []uint32{1}}, List: []FixtureBasic{{}}}
+	present, err := DecodeSparse(sparse, decoded)
+	if err != nil {
+		t.Fatalf("DecodeSparse failed: %v", err)
+	}
+	if !reflect.DeepEqual(v, decoded) {
+		t.Errorf("Expected %+v, got %+v", v, decoded)
+	}
+	wantPresent := Mask{"ID", "Primary", "Primary.Name", "Secondary", "List", "List[].Count", "Matrix"}
+	if !reflect.DeepEqual(wantPresent, present) {
+		t.Errorf("Expected present %v, got %v", wantPresent, present)
+	}
+
+	// DecodeMask reads the same format, reporting omitted fields as absent.
+	masked := &FixtureComplex{Primary: FixtureBasic{Score: 3}}
+	if _, err := DecodeMask(sparse, masked); err != nil || masked.Primary.Score != 3 || masked.Primary.Name != "alice" {
+		t.Errorf("Unexpected DecodeMask result %+v, %v", masked, err)
+	}
+}
+
+func TestEncodeSparseValues(t *testing.T) {
+	// Optionals that are present keep their zero values; -0 is not zero.
+	in := optionalValues()["zero"]
+	in.Score = ptr(math.Copysign(0, -1))
+	var data []byte
+	if err := EncodeSparse(in, &data); err != nil {
+		t.Fatal(err)
+	}
+	out := &FixtureOptional{Name: ptr("stale")}
+	present, err := DecodeSparse(data, out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(in, out) || !math.Signbit(*out.Score) {
+		t.Errorf("Expected %+v, got %+v", in, out)
+	}
+	if len(present) != 10 {
+		t.Errorf("Expected every field present, got %v", present)
+	}
+
+	// Absent optionals and zero fields are all left out.
+	if err := EncodeSparse(&FixtureOptional{}, &data); err != nil {
+		t.Fatal(err)
+	}
+	if want := []byte{10, 0, 0}; !bytes.Equal(want, data) {
+		t.Errorf("Expected %x, got %x", want, data)
+	}
+	out = &FixtureOptional{Count: ptr(1), After: "stale"}
+	if _, err := DecodeSparse(data, out); err != nil || !reflect.DeepEqual(&FixtureOptional{}, out) {
+		t.Errorf("Expected zero value, got %+v, %v", out, err)
+	}
+}
@@ -0,0 +1,131 @@
+package binary
+
+import (
+	"bufio"
+	"io"
+
+	"github.com/tinywasm/fmt"
+	"github.com/tinywasm/model"
+)
+
+// Encoder writes a sequence of values to an output stream.
+// Values are written back-to-back with no framing; wrap the writer in a
+// bufio.Writer when it is unbuffered.
+type Encoder struct {
+	w   binaryWriter
+	out countingWriter
+}
+
+// NewEncoder returns an Encoder writing to w.
+func NewEncoder(w io.Writer) *Encoder {
+	e := &Encoder{out: countingWriter{w: w}}
+	e.w.reset(&e.out)
+	return e
+}
+
+// Encode writes the encoding of v to the stream. After a write error the
+// stream may hold part of a value, so every later call returns that error.
+func (e *Encoder) Encode(v model.Encodable) error {
+	if v == nil || v.IsNil() {
+		return fmt.Err("Encode: input is nil")
+	}
+	if e.w.err != nil {
+		return e.w.err
+	}
+	v.EncodeFields(&e.w)
+	return e.w.err
+}
+
+// OutputOffset returns the number of bytes written so far.
+func (e *Encoder) OutputOffset() int64 {
+	return e.out.n
+}
+
+// countingWriter counts the bytes written through it.
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// Decoder reads a sequence of values from an input stream. Unlike Decode,
+// it keeps its buffered state between calls, so bytes read ahead for the
+// next value are never lost.
+type Decoder struct {
+	r      binaryReader
+	limits Limits
+}
+
+// NewDecoder returns a Decoder reading from r. Readers that cannot unread a
+// byte are wrapped in a bufio.Reader, so the Decoder may read past the last
+// value it returns. Every other reader, including a *bytes.Buffer, is read
+// as a stream: values written to it after NewDecoder are seen by later
+// calls, and the bytes of decoded values are consumed.
+func NewDecoder(r io.Reader) *Decoder {
+	d := &Decoder{limits: DefaultLimits}
+	switch v := r.(type) {
+	case *sliceReader:
+		d.r.r = v
+	case io.ByteScanner:
+		d.r.r = newStreamReader(r)
+	default:
+		d.r.r = newStreamReader(bufio.NewReader(r))
+	}
+	return d
+}
+
+// SetLimits replaces the DefaultLimits applied to each decoded value.
+func (d *Decoder) SetLimits(l Limits) {
+	d.limits = l
+}
+
+// Decode reads the next value from the stream into v. It returns io.EOF
+// when the stream ends cleanly between values. After any other error the
+// stream position is undefined and every later call returns that error.
+func (d *Decoder) Decode(v model.Decodable) error {
+	if v == nil || v.IsNil() {
+		return fmt.Err("Decode: output is nil")
+	}
+	if d.r.err != nil {
+		return d.r.err
+	}
+	if err := d.peek(); err != nil {
+		if err == io.EOF {
+			return io.EOF
+		}
+		d.r.fail(err)
+		return d.r.err
+	}
+	d.r.setLimits(d.limits)
+	v.DecodeFields(&d.r)
+	return d.r.err
+}
+
+// More reports whether there is another value to decode.
+func (d *Decoder) More() bool {
+	return d.r.err == nil && d.peek() == nil
+}
+
+// InputOffset returns the number of bytes consumed so far, which is the
+// offset of the next value after a successful Decode.
+func (d *Decoder) InputOffset() int64 {
+	return d.r.r.Offset()
+}
+
+// peek checks that at least one more byte is available without consuming it.
+func (d *Decoder) peek() error {
+	switch r := d.r.r.(type) {
+	case *sliceReader:
+		if r.Len() == 0 {
+			return io.EOF
+		}
+	case *streamReader:
+		return r.peek()
+	}
+	return nil
+}
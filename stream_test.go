@@ -0,0 +1,186 @@
+package binary
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"reflect"
+	"testing"
+
+	"github.com/tinywasm/fmt"
+)
+
+func TestEncoderDecoderSequence(t *testing.T) {
+	messages := []*Message{
+		{Topic: "users.created", Type: fmt.Msg.Event, ID: 1, Payload: []byte("alice")},
+		{Topic: "users.get", Type: fmt.Msg.Request, ID: 2},
+		{Topic: "users.get", Type: fmt.Msg.Response, ID: 2, Payload: []byte{0x00, 0xff}},
+	}
+
+	var buf bytes.Buffer
+	enc := NewEncoder(&buf)
+	var offsets []int64
+	for _, m := range messages {
+		if err := enc.Encode(m); err != nil {
+			t.Fatalf("Encode failed: %v", err)
+		}
+		offsets = append(offsets, enc.OutputOffset())
+	}
+	if enc.OutputOffset() != int64(buf.Len()) {
+		t.Errorf("Expected output offset %d, got %d", buf.Len(), enc.OutputOffset())
+	}
+	encoded := buf.Bytes()
+
+	inputs := map[string]io.Reader{
+		"Buffer":     bytes.NewBuffer(encoded),
+		"Reader":     bytes.NewReader(encoded),
+		"OneByte":    &oneByteReader{content: encoded},
+		"SliceInput": newSliceReader(encoded),
+	}
+	for name, in := range inputs {
+		t.Run(name, func(t *testing.T) {
+			dec := NewDecoder(in)
+			var got []*Message
+			for dec.More() {
+				m := &Message{}
+				if err := dec.Decode(m); err != nil {
+					t.Fatalf("Decode failed: %v", err)
+				}
+				if dec.InputOffset() != offsets[len(got)] {
+					t.Errorf("Expected input offset %d, got %d", offsets[len(got)], dec.InputOffset())
+				}
+				got = append(got, m)
+			}
+			if !reflect.DeepEqual(messages, got) {
+				t.Errorf("Expected %+v, got %+v", messages, got)
+			}
+			if err := dec.Decode(&Message{}); err != io.EOF {
+				t.Errorf("Expected io.EOF, got %v", err)
+			}
+		})
+	}
+}
+
+func TestDecoderOverPipe(t *testing.T) {
+	pr, pw := io.Pipe()
+	go func() {
+		enc := NewEncoder(pw)
+		for i := 0; i < 100; i++ {
+			enc.Encode(&Message{Topic: "tick", ID: uint32(i)})
+		}
+		pw.Close()
+	}()
+
+	dec := NewDecoder(pr)
+	for i := 0; ; i++ {
+		var m Message
+		err := dec.Decode(&m)
+		if err == io.EOF {
+			if i != 100 {
+				t.Fatalf("Expected 100 messages, got %d", i)
+			}
+			break
+		}
+		if err != nil {
+			t.Fatalf("Decode %d failed: %v", i, err)
+		}
+		if m.ID != uint32(i) {
+			t.Fatalf("Expected ID %d, got %d", i, m.ID)
+		}
+	}
+}
+
+func TestDecoderTruncatedTail(t *testing.T) {
+	var buf bytes.Buffer
+	enc := NewEncoder(&buf)
+	enc.Encode(&Message{Topic: "first"})
+	enc.Encode(&Message{Topic: "second", Payload: []byte("body")})
+	encoded := buf.Bytes()[:buf.Len()-2]
+
+	dec := NewDecoder(&oneByteReader{content: encoded})
+	if err := dec.Decode(&Message{}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := dec.Decode(&Message{}); !errors.Is(err, ErrTruncated) {
+		t.Fatalf("Expected ErrTruncated, got %v", err)
+	}
+	if dec.More() {
+		t.Error("Expected More to be false after an error")
+	}
+	if err := dec.Decode(&Message{}); !errors.Is(err, ErrTruncated) {
+		t.Errorf("Expected sticky ErrTruncated, got %v", err)
+	}
+}
+
+func TestDecoderLimits(t *testing.T) {
+	var buf bytes.Buffer
+	NewEncoder(&buf).Encode(&Message{Topic: "limited"})
+
+	dec := NewDecoder(&buf)
+	dec.SetLimits(Limits{MaxBytesLen: 3})
+	if err := dec.Decode(&Message{}); !errors.Is(err, ErrLimitExceeded) {
+		t.Errorf("Expected ErrLimitExceeded, got %v", err)
+	}
+}
+
+func TestEncoderNilInput(t *testing.T) {
+	enc := NewEncoder(io.Discard)
+	if err := enc.Encode(nil); err == nil {
+		t.Error("Expected error encoding nil")
+	}
+	var m *Message
+	if err := NewDecoder(bytes.NewReader(nil)).Decode(m); err == nil {
+		t.Error("Expected error decoding into nil")
+	}
+}
+
+func TestDecoderBufferInterleaved(t *testing.T) {
+	var buf bytes.Buffer
+	enc := NewEncoder(&buf)
+	dec := NewDecoder(&buf)
+	if err := dec.Decode(&Message{}); err != io.EOF {
+		t.Fatalf("Expected io.EOF on an empty buffer, got %v", err)
+	}
+	for i := uint32(1); i <= 3; i++ {
+		if err := enc.Encode(&Message{Topic: "tick", ID: i}); err != nil {
+			t.Fatal(err)
+		}
+		var m Message
+		if err := dec.Decode(&m); err != nil || m.ID != i {
+			t.Fatalf("Expected ID %d, got %+v, %v", i, m, err)
+		}
+		if buf.Len() != 0 {
+			t.Errorf("Expected the decoded value to be consumed, %d bytes left", buf.Len())
+		}
+	}
+	if dec.More() {
+		t.Error("Expected no more values")
+	}
+}
+
+// failingWriter accepts n bytes, then fails every write.
+type failingWriter struct {
+	n int
+}
+
+func (f *failingWriter) Write(p []byte) (int, error) {
+	if len(p) > f.n {
+		n := f.n
+		f.n = 0
+		return n, io.ErrShortWrite
+	}
+	f.n -= len(p)
+	return len(p), nil
+}
+
+func TestEncoderStickyError(t *testing.T) {
+	w := &failingWriter{n: 3}
+	enc := NewEncoder(w)
+	if err := enc.Encode(&Message{Topic: "too long"}); err != io.ErrShortWrite {
+		t.Fatalf("Expected io.ErrShortWrite, got %v", err)
+	}
+	w.n = 1 << 10 // the writer recovers, but the stream is misaligned
+	if err := enc.Encode(&Message{Topic: "next"}); err != io.ErrShortWrite {
+		t.Errorf("Expected the error to be sticky, got %v", err)
+	}
+}
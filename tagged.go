@@ -0,0 +1,726 @@
+package binary
+
+import (
+	"io"
+	"math"
+
+	"github.com/tinywasm/fmt"
+	"github.com/tinywasm/model"
+)
+
+// Tagged mode is an opt-in, self-describing variant of the wire format.
+// Every field is written as a key followed by its value, where the key packs
+// a 16-bit hash of the field name with a 3-bit wire type, in at most 3 bytes:
+//
+//	key = uvarint(hash(name)<<3 | wire)
+//
+// Readers match fields by name instead of position, skip fields they do not
+// know and report fields that are absent as ok=false, so fields can be added,
+// removed and reordered without breaking older peers. Field names within one
+// object must hash to distinct keys: EncodeTagged fails when two collide, and
+// DecodeTagged rejects an object holding a key twice. A field read as a kind
+// whose wire type differs from the one written fails with ErrInvalidInput;
+// Null reads as absent whatever the kind.
+const (
+	wireVarint  = 0 // zig-zag varint: Int
+	wireFixed64 = 1 // 8 bytes little-endian: Float, Fixed64
+	wireBytes   = 2 // uvarint length + bytes: String, Raw, Bytes
+	wireObject  = 3 // uvarint length + tagged fields: Object
+	wireArray   = 4 // uvarint count + per element a wire type byte and value: Array
+	wireNull    = 5 // no payload: Null and nil Object
+	wireUvarint = 6 // plain varint: Uint, Bool
+	wireFixed32 = 7 // 4 bytes little-endian: Float32, Fixed32
+)
+
+// EncodeTagged encodes input to output in tagged mode.
+// input: Encodable struct
+// output: *[]byte or io.Writer
+func EncodeTagged(input model.Encodable, output any) error {
+	if input == nil || input.IsNil() {
+		return fmt.Err("Encode: input is nil")
+	}
+
+	w := &taggedWriter{}
+	w.aw.w = w
+	switch out := output.(type) {
+	case *[]byte:
+		input.EncodeFields(w)
+		if w.err != nil {
+			return w.err
+		}
+		*out = w.buf
+		return nil
+	case io.Writer:
+		input.EncodeFields(w)
+		if w.err != nil {
+			return w.err
+		}
+		_, err := out.Write(w.buf)
+		return err
+	}
+	return fmt.Err("Encode", "output", "must be *[]byte or io.Writer")
+}
+
+// DecodeTagged decodes tagged-mode input to output, enforcing DefaultLimits.
+// input: []byte or io.Reader (read until EOF; use frames to delimit values on a stream)
+// output: pointer to Decodable struct
+func DecodeTagged(input, output any) error {
+	if output == nil {
+		return fmt.Err("Decode: output is nil")
+	}
+	dec, ok := output.(model.Decodable)
+	if !ok {
+		return fmt.Err("Decode", "output", "must implement model.Decodable")
+	}
+	if dec.IsNil() {
+		return fmt.Err("Decode: output is nil")
+	}
+
+	limits := DefaultLimits
+	var data []byte
+	switch in := input.(type) {
+	case []byte:
+		data = in
+	case io.Reader:
+		r := in
+		if limits.MaxTotalBytes > 0 {
+			r = io.LimitReader(in, limits.MaxTotalBytes+1)
+		}
+		var err error
+		if data, err = io.ReadAll(r); err != nil {
+			return err
+		}
+	default:
+		return fmt.Err("Decode", "input", "must be []byte or io.Reader")
+	}
+	if limits.MaxTotalBytes > 0 && int64(len(data)) > limits.MaxTotalBytes {
+		return ErrLimitExceeded
+	}
+
+	st := &taggedState{limits: limits}
+	dec.DecodeFields(st.object(data))
+	return st.err
+}
+
+// fieldKey hashes a field name with 32-bit FNV-1a, xor-folded to 16 bits.
+func fieldKey(name string) uint16 {
+	h := uint32(fnvOffset32)
+	for i := 0; i < len(name); i++ {
+		h = (h ^ uint32(name[i])) * fnvPrime32
+	}
+	return uint16(h ^ h>>16)
+}
+
+// --- Writer ---
+
+type taggedWriter struct {
+	buf   []byte
+	aw    taggedArrayWriter
+	err   error
+	keys  []uint16 // stack of the keys written; the current object's start at base
+	names []string // the field names of keys
+	base  int
+}
+
+func (w *taggedWriter) key(name string, wire byte) {
+	k := fieldKey(name)
+	for i := w.base; i < len(w.keys); i++ {
+		if w.keys[i] == k && w.err == nil {
+			w.err = fmt.Err("EncodeTagged", "field", name, "has the key of", w.names[i])
+		}
+	}
+	w.keys, w.names = append(w.keys, k), append(w.names, name)
+	w.buf = appendUvarint(w.buf, uint64(k)<<3|uint64(wire))
+}
+
+func (w *taggedWriter) String(name, val string) {
+	w.key(name, wireBytes)
+	w.buf = appendUvarint(w.buf, uint64(len(val)))
+	w.buf = append(w.buf, val...)
+}
+
+func (w *taggedWriter) Raw(name, val string) {
+	w.String(name, val)
+}
+
+func (w *taggedWriter) Int(name string, val int64) {
+	w.key(name, wireVarint)
+	w.buf = appendUvarint(w.buf, zigzag(val))
+}
+
+func (w *taggedWriter) Uint(name string, val uint64) {
+	w.key(name, wireUvarint)
+	w.buf = appendUvarint(w.buf, val)
+}
+
+func (w *taggedWriter) Float(name string, val float64) {
+	w.key(name, wireFixed64)
+	w.buf = appendFixed64(w.buf, math.Float64bits(val))
+}
+
+// Float32 implements CompactWriter
+func (w *taggedWriter) Float32(name string, val float32) {
+	w.Fixed32(name, math.Float32bits(val))
+}
+
+// Fixed32 implements CompactWriter
+func (w *taggedWriter) Fixed32(name string, val uint32) {
+	w.key(name, wireFixed32)
+	w.buf = appendFixed32(w.buf, val)
+}
+
+// Fixed64 implements CompactWriter
+func (w *taggedWriter) Fixed64(name string, val uint64) {
+	w.key(name, wireFixed64)
+	w.buf = appendFixed64(w.buf, val)
+}
+
+func (w *taggedWriter) Bool(name string, val bool) {
+	w.key(name, wireUvarint)
+	w.buf = append(w.buf, boolByte(val))
+}
+
+func (w *taggedWriter) Bytes(name string, val []byte) {
+	w.key(name, wireBytes)
+	w.buf = appendUvarint(w.buf, uint64(len(val)))
+	w.buf = append(w.buf, val...)
+}
+
+func (w *taggedWriter) Null(name string) {
+	w.key(name, wireNull)
+}
+
+// Present writes an absent value as Null; a present one is written as the
+// plain value.
+func (w *taggedWriter) Present(name string, ok bool) {
+	if !ok {
+		w.Null(name)
+	}
+}
+
+func (w *taggedWriter) Object(name string, val model.Encodable) {
+	if val == nil || val.IsNil() {
+		w.Null(name)
+		return
+	}
+	w.key(name, wireObject)
+	w.object(val)
+}
+
+// Map writes the entries as an Array of keys and values.
+func (w *taggedWriter) Map(name string, n int, key, value Kind) model.ArrayWriter {
+	return w.Array(name, 2*n)
+}
+
+func (w *taggedWriter) Array(name string, n int) model.ArrayWriter {
+	w.key(name, wireArray)
+	w.buf = appendUvarint(w.buf, uint64(n))
+	return &w.aw
+}
+
+// object appends the length-prefixed tagged fields of val.
+func (w *taggedWriter) object(val model.Encodable) {
+	start, base := len(w.buf), w.base
+	w.base = len(w.keys)
+	val.EncodeFields(w)
+	w.keys, w.names, w.base = w.keys[:w.base], w.names[:w.base], base
+	w.buf = insertUvarint(w.buf, start, uint64(len(w.buf)-start))
+}
+
+type taggedArrayWriter struct {
+	w *taggedWriter
+}
+
+func (a *taggedArrayWriter) String(val string) {
+	a.w.buf = append(a.w.buf, wireBytes)
+	a.w.buf = appendUvarint(a.w.buf, uint64(len(val)))
+	a.w.buf = append(a.w.buf, val...)
+}
+
+func (a *taggedArrayWriter) Int(val int64) {
+	a.w.buf = append(a.w.buf, wireVarint)
+	a.w.buf = appendUvarint(a.w.buf, zigzag(val))
+}
+
+func (a *taggedArrayWriter) Float(val float64) {
+	a.w.buf = append(a.w.buf, wireFixed64)
+	a.w.buf = appendFixed64(a.w.buf, math.Float64bits(val))
+}
+
+func (a *taggedArrayWriter) Float32(val float32) {
+	a.Fixed32(math.Float32bits(val))
+}
+
+func (a *taggedArrayWriter) Fixed32(val uint32) {
+	a.w.buf = append(a.w.buf, wireFixed32)
+	a.w.buf = appendFixed32(a.w.buf, val)
+}
+
+func (a *taggedArrayWriter) Fixed64(val uint64) {
+	a.w.buf = append(a.w.buf, wireFixed64)
+	a.w.buf = appendFixed64(a.w.buf, val)
+}
+
+func (a *taggedArrayWriter) Bool(val bool) {
+	a.w.buf = append(a.w.buf, wireUvarint, boolByte(val))
+}
+
+func (a *taggedArrayWriter) Bytes(val []byte) {
+	a.w.buf = append(a.w.buf, wireBytes)
+	a.w.buf = appendUvarint(a.w.buf, uint64(len(val)))
+	a.w.buf = append(a.w.buf, val...)
+}
+
+func (a *taggedArrayWriter) Object(val model.Encodable) {
+	if val == nil || val.IsNil() {
+		a.w.buf = append(a.w.buf, wireNull)
+		return
+	}
+	a.w.buf = append(a.w.buf, wireObject)
+	a.w.object(val)
+}
+
+func (a *taggedArrayWriter) Close() {}
+
+// --- Reader ---
+
+// taggedField is one key/value pair of an object, or one array element
+// (with a zero key).
+type taggedField struct {
+	key  uint16
+	wire byte
+	val  []byte // encoded value, without the key
+}
+
+// taggedState is shared by all the readers of one decode.
+type taggedState struct {
+	err    error
+	limits Limits
+	depth  int
+	keys   map[uint16]bool // keys of the object being indexed, once it is large
+}
+
+func (st *taggedState) fail(err error) {
+	if st.err == nil {
+		st.err = err
+	}
+}
+
+// object indexes the tagged fields in body.
+func (st *taggedState) object(body []byte) *taggedReader {
+	r := &taggedReader{st: st}
+	sr := sliceReader{buffer: body}
+	for st.err == nil && sr.Len() > 0 {
+		k, err := sr.ReadUvarint()
+		if err != nil {
+			st.fail(readError(err))
+			break
+		}
+		if k>>3 > math.MaxUint16 || st.dup(r.fields, uint16(k>>3)) {
+			st.fail(ErrInvalidInput)
+			break
+		}
+		wire := byte(k & 7)
+		val, ok := st.skip(&sr, wire)
+		if !ok {
+			break
+		}
+		r.fields = append(r.fields, taggedField{key: uint16(k >> 3), wire: wire, val: val})
+	}
+	return r
+}
+
+// dup reports whether key is already among fields. Objects are indexed one
+// at a time, so large ones share the keys set.
+func (st *taggedState) dup(fields []taggedField, key uint16) bool {
+	const small = 16
+	if len(fields) < small {
+		for i := range fields {
+			if fields[i].key == key {
+				return true
+			}
+		}
+		return false
+	}
+	if len(fields) == small {
+		if st.keys == nil {
+			st.keys = make(map[uint16]bool)
+		}
+		clear(st.keys)
+		for i := range fields {
+			st.keys[fields[i].key] = true
+		}
+	}
+	if st.keys[key] {
+		return true
+	}
+	st.keys[key] = true
+	return false
+}
+
+// skip consumes one value of the given wire type and returns its bytes.
+func (st *taggedState) skip(sr *sliceReader, wire byte) ([]byte, bool) {
+	start := sr.offset
+	var err error
+	switch wire {
+	case wireVarint, wireUvarint:
+		_, err = sr.ReadUvarint()
+	case wireFixed64:
+		_, err = sr.Slice(8)
+	case wireFixed32:
+		_, err = sr.Slice(4)
+	case wireBytes, wireObject:
+		var l uint64
+		if l, err = sr.ReadUvarint(); err == nil {
+			if wire == wireBytes && st.limits.MaxBytesLen > 0 && l > uint64(st.limits.MaxBytesLen) {
+				st.fail(ErrLimitExceeded)
+				return nil, false
+			}
+			if l > uint64(sr.Len()) {
+				err = io.EOF
+			} else {
+				_, err = sr.Slice(int(l))
+			}
+		}
+	case wireArray:
+		var n uint64
+		if n, err = sr.ReadUvarint(); err == nil {
+			if st.limits.MaxArrayLen > 0 && n > uint64(st.limits.MaxArrayLen) {
+				st.fail(ErrLimitExceeded)
+				return nil, false
+			}
+			for i := uint64(0); i < n && err == nil; i++ {
+				var elem byte
+				if elem, err = sr.ReadByte(); err == nil {
+					if elem == wireArray {
+						st.fail(ErrInvalidInput)
+						return nil, false
+					}
+					if _, ok := st.skip(sr, elem); !ok {
+						return nil, false
+					}
+				}
+			}
+		}
+	case wireNull:
+	default:
+		st.fail(ErrInvalidInput)
+		return nil, false
+	}
+	if err != nil {
+		st.fail(readError(err))
+		return nil, false
+	}
+	return sr.buffer[start:sr.offset], true
+}
+
+// taggedReader implements model.FieldReader over one indexed object.
+type taggedReader struct {
+	fields []taggedField
+	next   int // where the next lookup starts, so in-order reads are O(1)
+	st     *taggedState
+}
+
+// find returns the field called name, or false if it is absent.
+func (r *taggedReader) find(name string) (*taggedField, bool) {
+	if r.st.err != nil {
+		return nil, false
+	}
+	key := fieldKey(name)
+	n := len(r.fields)
+	for i := 0; i < n; i++ {
+		j := (r.next + i) % n
+		if r.fields[j].key == key {
+			r.next = j + 1
+			return &r.fields[j], true
+		}
+	}
+	return nil, false
+}
+
+func (r *taggedReader) String(name string) (string, bool) {
+	f, ok := r.find(name)
+	if !ok {
+		return "", false
+	}
+	return r.st.stringValue(f)
+}
+
+func (r *taggedReader) Raw(name string) (string, bool) {
+	return r.String(name)
+}
+
+func (r *taggedReader) Int(name string) (int64, bool) {
+	f, ok := r.find(name)
+	if !ok {
+		return 0, false
+	}
+	return r.st.intValue(f)
+}
+
+func (r *taggedReader) Uint(name string) (uint64, bool) {
+	f, ok := r.find(name)
+	if !ok || !r.st.is(f, wireUvarint) {
+		return 0, false
+	}
+	return r.st.uvarintValue(f.val)
+}
+
+func (r *taggedReader) Float(name string) (float64, bool) {
+	f, ok := r.find(name)
+	if !ok {
+		return 0, false
+	}
+	return r.st.floatValue(f)
+}
+
+// Float32 implements CompactReader
+func (r *taggedReader) Float32(name string) (float32, bool) {
+	v, ok := r.Fixed32(name)
+	return math.Float32frombits(v), ok
+}
+
+// Fixed32 implements CompactReader
+func (r *taggedReader) Fixed32(name string) (uint32, bool) {
+	f, ok := r.find(name)
+	if !ok {
+		return 0, false
+	}
+	return r.st.fixed32Value(f)
+}
+
+// Fixed64 implements CompactReader
+func (r *taggedReader) Fixed64(name string) (uint64, bool) {
+	f, ok := r.find(name)
+	if !ok {
+		return 0, false
+	}
+	return r.st.fixed64Value(f)
+}
+
+func (r *taggedReader) Bool(name string) (bool, bool) {
+	f, ok := r.find(name)
+	if !ok {
+		return false, false
+	}
+	return r.st.boolValue(f)
+}
+
+func (r *taggedReader) Bytes(name string) ([]byte, bool) {
+	f, ok := r.find(name)
+	if !ok {
+		return nil, false
+	}
+	return r.st.bytesValue(f)
+}
+
+// Present reports a field that is missing or Null as absent.
+func (r *taggedReader) Present(name string) (bool, bool) {
+	f, ok := r.find(name)
+	if !ok {
+		return false, r.st.err == nil
+	}
+	if f.wire == wireNull {
+		return false, true
+	}
+	r.next-- // the value is read next
+	return true, true
+}
+
+func (r *taggedReader) Object(name string, into model.Decodable) bool {
+	f, ok := r.find(name)
+	if !ok {
+		return false
+	}
+	return r.st.objectValue(f, into)
+}
+
+func (r *taggedReader) Map(name string) (model.ArrayReader, bool) {
+	a, ok := r.Array(name)
+	if !ok {
+		return nil, false
+	}
+	if n := a.Len(); n%2 != 0 {
+		r.st.fail(ErrInvalidInput)
+		return nil, false
+	} else if r.st.limits.MaxMapLen > 0 && n/2 > r.st.limits.MaxMapLen {
+		r.st.fail(ErrLimitExceeded)
+		return nil, false
+	}
+	return a, true
+}
+
+func (r *taggedReader) Array(name string) (model.ArrayReader, bool) {
+	f, ok := r.find(name)
+	if !ok || !r.st.is(f, wireArray) {
+		return nil, false
+	}
+	sr := sliceReader{buffer: f.val}
+	n, _ := sr.ReadUvarint() // already validated by skip
+	a := &taggedArrayReader{st: r.st, elems: make([]taggedField, 0, int(n))}
+	for i := uint64(0); i < n; i++ {
+		wire, _ := sr.ReadByte()
+		val, _ := r.st.skip(&sr, wire)
+		a.elems = append(a.elems, taggedField{wire: wire, val: val})
+	}
+	return a, true
+}
+
+// taggedArrayReader implements model.ArrayReader; elements may be read in any order.
+type taggedArrayReader struct {
+	st    *taggedState
+	elems []taggedField
+}
+
+func (a *taggedArrayReader) Len() int {
+	return len(a.elems)
+}
+
+func (a *taggedArrayReader) elem(i int) *taggedField {
+	if a.st.err != nil || i < 0 || i >= len(a.elems) {
+		return &taggedField{wire: wireNull}
+	}
+	return &a.elems[i]
+}
+
+func (a *taggedArrayReader) String(i int) string {
+	v, _ := a.st.stringValue(a.elem(i))
+	return v
+}
+
+func (a *taggedArrayReader) Int(i int) int64 {
+	v, _ := a.st.intValue(a.elem(i))
+	return v
+}
+
+func (a *taggedArrayReader) Float(i int) float64 {
+	v, _ := a.st.floatValue(a.elem(i))
+	return v
+}
+
+func (a *taggedArrayReader) Float32(i int) float32 {
+	return math.Float32frombits(a.Fixed32(i))
+}
+
+func (a *taggedArrayReader) Fixed32(i int) uint32 {
+	v, _ := a.st.fixed32Value(a.elem(i))
+	return v
+}
+
+func (a *taggedArrayReader) Fixed64(i int) uint64 {
+	v, _ := a.st.fixed64Value(a.elem(i))
+	return v
+}
+
+func (a *taggedArrayReader) Bool(i int) bool {
+	v, _ := a.st.boolValue(a.elem(i))
+	return v
+}
+
+func (a *taggedArrayReader) Bytes(i int) []byte {
+	v, _ := a.st.bytesValue(a.elem(i))
+	return v
+}
+
+func (a *taggedArrayReader) Object(i int, into model.Decodable) bool {
+	return a.st.objectValue(a.elem(i), into)
+}
+
+// Value decoding. A Null value is reported as absent; any other value whose
+// wire type does not match the requested kind fails the decode.
+
+// is reports whether f has wire type want.
+func (st *taggedState) is(f *taggedField, want byte) bool {
+	if f.wire == want {
+		return true
+	}
+	if f.wire != wireNull {
+		st.fail(ErrInvalidInput)
+	}
+	return false
+}
+
+func (st *taggedState) uvarintValue(val []byte) (uint64, bool) {
+	sr := sliceReader{buffer: val}
+	v, err := sr.ReadUvarint()
+	return v, err == nil
+}
+
+func (st *taggedState) intValue(f *taggedField) (int64, bool) {
+	if !st.is(f, wireVarint) {
+		return 0, false
+	}
+	sr := sliceReader{buffer: f.val}
+	v, err := sr.ReadVarint()
+	return v, err == nil
+}
+
+func (st *taggedState) floatValue(f *taggedField) (float64, bool) {
+	v, ok := st.fixed64Value(f)
+	return math.Float64frombits(v), ok
+}
+
+func (st *taggedState) fixed64Value(f *taggedField) (uint64, bool) {
+	if !st.is(f, wireFixed64) {
+		return 0, false
+	}
+	return fixed64(f.val), true
+}
+
+func (st *taggedState) fixed32Value(f *taggedField) (uint32, bool) {
+	if !st.is(f, wireFixed32) {
+		return 0, false
+	}
+	return fixed32(f.val), true
+}
+
+func (st *taggedState) boolValue(f *taggedField) (bool, bool) {
+	if !st.is(f, wireUvarint) {
+		return false, false
+	}
+	v, ok := st.uvarintValue(f.val)
+	if ok && v > 1 {
+		st.fail(ErrInvalidInput)
+		return false, false
+	}
+	return v == 1, ok
+}
+
+// payload strips the length prefix of a wireBytes or wireObject value.
+func payload(val []byte) []byte {
+	sr := sliceReader{buffer: val}
+	sr.ReadUvarint() // already validated by skip
+	return val[sr.offset:]
+}
+
+func (st *taggedState) stringValue(f *taggedField) (string, bool) {
+	if !st.is(f, wireBytes) {
+		return "", false
+	}
+	return string(payload(f.val)), true
+}
+
+func (st *taggedState) bytesValue(f *taggedField) ([]byte, bool) {
+	if !st.is(f, wireBytes) {
+		return nil, false
+	}
+	b := payload(f.val)
+	if len(b) == 0 {
+		return nil, true
+	}
+	return append([]byte(nil), b...), true
+}
+
+func (st *taggedState) objectValue(f *taggedField, into model.Decodable) bool {
+	if into == nil || !st.is(f, wireObject) {
+		return false
+	}
+	if st.limits.MaxDepth > 0 && st.depth >= st.limits.MaxDepth {
+		st.fail(ErrLimitExceeded)
+		return false
+	}
+	st.depth++
+	into.DecodeFields(st.object(payload(f.val)))
+	st.depth--
+	return st.err == nil
+}
@@ -0,0 +1,240 @@
+package binary
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/tinywasm/model"
+)
+
+// userV1 and userV2 are two versions of the same record: V2 reorders the
+// fields, drops Legacy and adds Email in the middle.
+type userV1 struct {
+	Name   string
+	Age    int64
+	Legacy bool
+	Tags   []string
+}
+
+func (u *userV1) IsNil() bool { return u == nil }
+
+func (u *userV1) EncodeFields(w model.FieldWriter) {
+	w.String("Name", u.Name)
+	w.Int("Age", u.Age)
+	w.Bool("Legacy", u.Legacy)
+	aw := w.Array("Tags", len(u.Tags))
+	for _, t := range u.Tags {
+		aw.String(t)
+	}
+}
+
+func (u *userV1) DecodeFields(r model.FieldReader) {
+	u.Name, _ = r.String("Name")
+	u.Age, _ = r.Int("Age")
+	u.Legacy, _ = r.Bool("Legacy")
+	if ar, ok := r.Array("Tags"); ok {
+		u.Tags = make([]string, ar.Len())
+		for i := range u.Tags {
+			u.Tags[i] = ar.String(i)
+		}
+	}
+}
+
+type userV2 struct {
+	Tags     []string
+	Email    string
+	Name     string
+	Age      int64
+	hasEmail bool
+}
+
+func (u *userV2) IsNil() bool { return u == nil }
+
+func (u *userV2) EncodeFields(w model.FieldWriter) {
+	aw := w.Array("Tags", len(u.Tags))
+	for _, t := range u.Tags {
+		aw.String(t)
+	}
+	w.String("Email", u.Email)
+	w.String("Name", u.Name)
+	w.Int("Age", u.Age)
+}
+
+func (u *userV2) DecodeFields(r model.FieldReader) {
+	if ar, ok := r.Array("Tags"); ok {
+		u.Tags = make([]string, ar.Len())
+		for i := range u.Tags {
+			u.Tags[i] = ar.String(i)
+		}
+	}
+	u.Email, u.hasEmail = r.String("Email")
+	u.Name, _ = r.String("Name")
+	u.Age, _ = r.Int("Age")
+}
+
+func TestTaggedSchemaEvolution(t *testing.T) {
+	t.Run("NewReaderOldData", func(t *testing.T) {
+		var data []byte
+		if err := EncodeTagged(&userV1{Name: "Alice", Age: 30, Legacy: true, Tags: []string{"a"}}, &data); err != nil {
+			t.Fatalf("EncodeTagged failed: %v", err)
+		}
+		got := &userV2{}
+		if err := DecodeTagged(data, got); err != nil {
+			t.Fatalf("DecodeTagged failed: %v", err)
+		}
+		want := &userV2{Name: "Alice", Age: 30, Tags: []string{"a"}}
+		if !reflect.DeepEqual(want, got) {
+			t.Errorf("Expected %+v, got %+v", want, got)
+		}
+		if got.hasEmail {
+			t.Error("Expected missing Email to report ok=false")
+		}
+	})
+
+	t.Run("OldReaderNewData", func(t *testing.T) {
+		var data []byte
+		if err := EncodeTagged(&userV2{Name: "Bob", Age: 41, Email: "bob@example.com", Tags: []string{"x", "y"}}, &data); err != nil {
+			t.Fatalf("EncodeTagged failed: %v", err)
+		}
+		got := &userV1{}
+		if err := DecodeTagged(bytes.NewReader(data), got); err != nil {
+			t.Fatalf("DecodeTagged failed: %v", err)
+		}
+		want := &userV1{Name: "Bob", Age: 41, Tags: []string{"x", "y"}}
+		if !reflect.DeepEqual(want, got) {
+			t.Errorf("Expected %+v, got %+v", want, got)
+		}
+	})
+}
+
+func TestTaggedRoundTrip(t *testing.T) {
+	original := &FixtureComplex{
+		ID:        42,
+		Primary:   FixtureBasic{Name: "Primary", Timestamp: -5, Payload: []byte{1, 2}, Tags: []uint32{7}, Count: -3, Active: true, Score: 2.5},
+		Secondary: &FixtureBasic{Name: "Secondary"},
+		List:      []FixtureBasic{{Name: "a"}, {Name: "b", Score: -1}},
+		Matrix:    [3]int{1, -2, 3},
+	}
+	var data bytes.Buffer
+	if err := EncodeTagged(original, &data); err != nil {
+		t.Fatalf("EncodeTagged failed: %v", err)
+	}
+	decoded := &FixtureComplex{}
+	if err := DecodeTagged(data.Bytes(), decoded); err != nil {
+		t.Fatalf("DecodeTagged failed: %v", err)
+	}
+	if !reflect.DeepEqual(original, decoded) {
+		t.Errorf("Expected %#v, got %#v", original, decoded)
+	}
+
+	// Nil objects are encoded as null.
+	decoded = &FixtureComplex{}
+	var nilData []byte
+	EncodeTagged(&FixtureComplex{}, &nilData)
+	if err := DecodeTagged(nilData, decoded); err != nil {
+		t.Fatalf("DecodeTagged failed: %v", err)
+	}
+	if decoded.Secondary != nil {
+		t.Errorf("Expected nil Secondary, got %+v", decoded.Secondary)
+	}
+}
+
+func TestTaggedKindMismatch(t *testing.T) {
+	// A field that changed kind fails the decode rather than being misread.
+	var data []byte
+	EncodeTagged(&s0{A: "a", B: "b", C: 3}, &data)
+	st := &taggedState{limits: DefaultLimits}
+	r := st.object(data)
+	if v, ok := r.Int("C"); !ok || v != 3 {
+		t.Errorf("Expected C=3, got %v %v", v, ok)
+	}
+	if _, ok := r.Int("A"); ok || !errors.Is(st.err, ErrInvalidInput) {
+		t.Errorf("Expected ErrInvalidInput for String field read as Int, got %v", st.err)
+	}
+
+	// Null reads as absent whatever the kind.
+	data = appendUvarint(nil, uint64(fieldKey("C"))<<3|wireNull)
+	st = &taggedState{limits: DefaultLimits}
+	if _, ok := st.object(data).Int("C"); ok || st.err != nil {
+		t.Errorf("Expected Null to read as absent, got %v", st.err)
+	}
+}
+
+// collide has two field names with the same tagged key.
+type collide struct{ a, b string }
+
+func (c *collide) IsNil() bool { return c == nil }
+
+func (c *collide) EncodeFields(w model.FieldWriter) {
+	w.Int(c.a, 1)
+	w.Int(c.b, 2)
+}
+
+func TestTaggedKeyCollision(t *testing.T) {
+	// Find two names with the same 16-bit key.
+	names := map[uint16]string{}
+	c := &collide{}
+	for i := 0; c.b == ""; i++ {
+		name := "F" + string(appendInt(nil, int64(i)))
+		if prev, ok := names[fieldKey(name)]; ok {
+			c.a, c.b = prev, name
+		}
+		names[fieldKey(name)] = name
+	}
+
+	var data []byte
+	if err := EncodeTagged(c, &data); err == nil || !strings.Contains(err.Error(), c.b) {
+		t.Errorf("Expected a collision error naming %s, got %v", c.b, err)
+	}
+	// The same names in different objects do not collide.
+	if err := EncodeTagged(&FixtureComplex{Secondary: &FixtureBasic{}}, &data); err != nil {
+		t.Errorf("EncodeTagged failed: %v", err)
+	}
+
+	// A key repeated within one object is rejected, in small and large objects.
+	for _, n := range []int{1, 20} {
+		var dup []byte
+		for i := 0; i <= n; i++ {
+			dup = appendUvarint(dup, uint64(fieldKey(string(appendInt(nil, int64(i%n)))))<<3|wireVarint)
+			dup = append(dup, 0)
+		}
+		if err := DecodeTagged(dup, &s0{}); !errors.Is(err, ErrInvalidInput) {
+			t.Errorf("%d fields: expected ErrInvalidInput for a repeated key, got %v", n, err)
+		}
+	}
+}
+
+func TestTaggedKeySize(t *testing.T) {
+	var data []byte
+	if err := EncodeTagged(&s0{C: 1}, &data); err != nil {
+		t.Fatal(err)
+	}
+	// Three fields of at most 3 key bytes: two empty strings and one varint.
+	if len(data) > 3*3+1+1+1 {
+		t.Errorf("Expected keys of at most 3 bytes, got %d bytes: %x", len(data), data)
+	}
+}
+
+func TestTaggedCorruptInput(t *testing.T) {
+	var data []byte
+	EncodeTagged(&FixtureComplex{Secondary: &FixtureBasic{Name: "nested"}, List: []FixtureBasic{{}}}, &data)
+	for n := 1; n < len(data); n++ {
+		err := DecodeTagged(data[:n], &FixtureComplex{})
+		if err == nil {
+			continue // the cut may fall on a field boundary
+		}
+		if !errors.Is(err, ErrTruncated) && !errors.Is(err, ErrInvalidInput) {
+			t.Fatalf("prefix %d: unexpected error %v", n, err)
+		}
+	}
+
+	// every 3-bit key wire type is in use, so the unknown one is an element's
+	bad := appendUvarint(nil, uint64(fieldKey("A"))<<3|wireArray)
+	bad = append(bad, 1, 8)
+	if err := DecodeTagged(bad, &s0{}); !errors.Is(err, ErrInvalidInput) {
+		t.Errorf("Expected ErrInvalidInput for unknown wire type, got %v", err)
+	}
+}
@@ -0,0 +1,620 @@
+package binary
+
+import (
+	"math"
+
+	"github.com/tinywasm/fmt"
+	"github.com/tinywasm/model"
+)
+
+// Sprint formats the fields v writes as text, for logging and debugging:
+//
+//	{Name: "Alice", Tags: [1, 2], Secondary: <nil>}
+//
+// Strings are quoted, Raw values are written as is, byte slices are shown
+// as hex and nil objects and Null fields as <nil>. It works on any
+// Encodable, including Message, without reflection.
+func Sprint(v model.Encodable) string {
+	return SprintWidth(v, 0)
+}
+
+// SprintWidth is like Sprint but cuts the result to at most width bytes,
+// ending it with "..." when it is cut. A width of 0 or less means no limit.
+func SprintWidth(v model.Encodable, width int) string {
+	if v == nil || v.IsNil() {
+		return "<nil>"
+	}
+	w := &textWriter{max: width}
+	if width > 0 && width < 3 {
+		w.max = 3
+	}
+	w.object(v)
+	if w.max > 0 && len(w.buf) > w.max {
+		cut := w.max - 3
+		for cut > 0 && w.buf[cut]&0xc0 == 0x80 { // UTF-8 continuation byte
+			cut--
+		}
+		w.buf = append(w.buf[:cut], "..."...)
+	}
+	return string(w.buf)
+}
+
+// textWriter is a model.FieldWriter that writes fields as text.
+type textWriter struct {
+	buf   []byte
+	max   int  // stop writing once buf is longer than max; 0 means no limit
+	first bool // no field written yet in the current object
+}
+
+// full reports whether the width limit has been passed, so the remaining
+// fields can be skipped.
+func (w *textWriter) full() bool {
+	return w.max > 0 && len(w.buf) > w.max
+}
+
+func (w *textWriter) field(name string) bool {
+	if w.full() {
+		return false
+	}
+	if !w.first {
+		w.buf = append(w.buf, ", "...)
+	}
+	w.first = false
+	w.buf = append(w.buf, name...)
+	w.buf = append(w.buf, ": "...)
+	return true
+}
+
+func (w *textWriter) object(v model.Encodable) {
+	if v == nil || v.IsNil() {
+		w.buf = append(w.buf, "<nil>"...)
+		return
+	}
+	first := w.first
+	w.first = true
+	w.buf = append(w.buf, '{')
+	v.EncodeFields(w)
+	w.buf = append(w.buf, '}')
+	w.first = first
+}
+
+func (w *textWriter) String(name, val string) {
+	if w.field(name) {
+		w.buf = appendQuote(w.buf, val)
+	}
+}
+
+func (w *textWriter) Raw(name, val string) {
+	if w.field(name) {
+		w.buf = append(w.buf, val...)
+	}
+}
+
+func (w *textWriter) Int(name string, val int64) {
+	if w.field(name) {
+		w.buf = appendInt(w.buf, val)
+	}
+}
+
+func (w *textWriter) Uint(name string, val uint64) {
+	if w.field(name) {
+		w.buf = appendUint(w.buf, val)
+	}
+}
+
+func (w *textWriter) Float(name string, val float64) {
+	if w.field(name) {
+		w.buf = appendFloat(w.buf, val, 64)
+	}
+}
+
+func (w *textWriter) Float32(name string, val float32) {
+	if w.field(name) {
+		w.buf = appendFloat(w.buf, float64(val), 32)
+	}
+}
+
+func (w *textWriter) Fixed32(name string, val uint32) {
+	w.Uint(name, uint64(val))
+}
+
+func (w *textWriter) Fixed64(name string, val uint64) {
+	w.Uint(name, val)
+}
+
+func (w *textWriter) Bool(name string, val bool) {
+	if w.field(name) {
+		w.buf = appendBool(w.buf, val)
+	}
+}
+
+func (w *textWriter) Bytes(name string, val []byte) {
+	if w.field(name) {
+		w.buf = appendHex(w.buf, val)
+	}
+}
+
+func (w *textWriter) Null(name string) {
+	if w.field(name) {
+		w.buf = append(w.buf, "<nil>"...)
+	}
+}
+
+func (w *textWriter) Present(name string, ok bool) {
+	if !ok {
+		w.Null(name)
+	}
+}
+
+func (w *textWriter) Object(name string, val model.Encodable) {
+	if w.field(name) {
+		w.object(val)
+	}
+}
+
+func (w *textWriter) Array(name string, n int) model.ArrayWriter {
+	if !w.field(name) {
+		return discardArrayWriter{}
+	}
+	w.buf = append(w.buf, '[')
+	if n <= 0 {
+		w.buf = append(w.buf, ']')
+		return discardArrayWriter{}
+	}
+	return &textArrayWriter{w: w, left: n, end: ']'}
+}
+
+// Map writes the entries as {key: value, ...}.
+func (w *textWriter) Map(name string, n int, key, value Kind) model.ArrayWriter {
+	if !w.field(name) {
+		return discardArrayWriter{}
+	}
+	w.buf = append(w.buf, '{')
+	if n <= 0 {
+		w.buf = append(w.buf, '}')
+		return discardArrayWriter{}
+	}
+	return &textArrayWriter{w: w, left: 2 * n, end: '}'}
+}
+
+// textArrayWriter writes the elements of one array, or the keys and values
+// of a map. The closing bracket is written after the last of the announced
+// elements, since callers are not required to call Close.
+type textArrayWriter struct {
+	w    *textWriter
+	left int
+	n    int
+	end  byte // closing bracket
+}
+
+func (a *textArrayWriter) elem() bool {
+	if a.left <= 0 || a.w.full() {
+		return false
+	}
+	switch {
+	case a.end == '}' && a.n%2 == 1:
+		a.w.buf = append(a.w.buf, ": "...)
+	case a.n > 0:
+		a.w.buf = append(a.w.buf, ", "...)
+	}
+	a.n++
+	return true
+}
+
+func (a *textArrayWriter) done() {
+	if a.left--; a.left == 0 {
+		a.w.buf = append(a.w.buf, a.end)
+	}
+}
+
+func (a *textArrayWriter) String(val string) {
+	if a.elem() {
+		a.w.buf = appendQuote(a.w.buf, val)
+		a.done()
+	}
+}
+
+func (a *textArrayWriter) Int(val int64) {
+	if a.elem() {
+		a.w.buf = appendInt(a.w.buf, val)
+		a.done()
+	}
+}
+
+func (a *textArrayWriter) Float(val float64) {
+	if a.elem() {
+		a.w.buf = appendFloat(a.w.buf, val, 64)
+		a.done()
+	}
+}
+
+func (a *textArrayWriter) Float32(val float32) {
+	if a.elem() {
+		a.w.buf = appendFloat(a.w.buf, float64(val), 32)
+		a.done()
+	}
+}
+
+func (a *textArrayWriter) Fixed32(val uint32) {
+	a.Fixed64(uint64(val))
+}
+
+func (a *textArrayWriter) Fixed64(val uint64) {
+	if a.elem() {
+		a.w.buf = appendUint(a.w.buf, val)
+		a.done()
+	}
+}
+
+func (a *textArrayWriter) Bool(val bool) {
+	if a.elem() {
+		a.w.buf = appendBool(a.w.buf, val)
+		a.done()
+	}
+}
+
+func (a *textArrayWriter) Bytes(val []byte) {
+	if a.elem() {
+		a.w.buf = appendHex(a.w.buf, val)
+		a.done()
+	}
+}
+
+func (a *textArrayWriter) Object(val model.Encodable) {
+	if a.elem() {
+		a.w.object(val)
+		a.done()
+	}
+}
+
+// Close ends an array that received fewer elements than announced.
+func (a *textArrayWriter) Close() {
+	if a.left > 0 && !a.w.full() {
+		a.left = 0
+		a.w.buf = append(a.w.buf, a.end)
+	}
+}
+
+// appendHex appends b as 0x-prefixed lowercase hex.
+func appendHex(dst, b []byte) []byte {
+	const digits = "0123456789abcdef"
+	dst = append(dst, '0', 'x')
+	for _, c := range b {
+		dst = append(dst, digits[c>>4], digits[c&0xf])
+	}
+	return dst
+}
+
+// The helpers below format values with tinywasm/fmt, which keeps strconv
+// out of TinyGo builds.
+
+func appendBool(dst []byte, v bool) []byte {
+	return append(dst, fmt.Convert(v).String()...)
+}
+
+// appendUint formats v with fmt.Convert, which handles values up to
+// math.MaxInt64; larger ones are written as v/10 and their last digit.
+func appendUint(dst []byte, v uint64) []byte {
+	if v > math.MaxInt64 {
+		return append(appendUint(dst, v/10), byte('0'+v%10))
+	}
+	return append(dst, fmt.Convert(int64(v)).String()...)
+}
+
+func appendInt(dst []byte, v int64) []byte {
+	if v < 0 {
+		return appendUint(append(dst, '-'), uint64(-v))
+	}
+	return appendUint(dst, uint64(v))
+}
+
+// appendQuote appends s double-quoted by fmt, with quotes, backslashes,
+// newlines, carriage returns and tabs escaped.
+func appendQuote(dst []byte, s string) []byte {
+	return append(dst, fmt.Convert(s).Quote().String()...)
+}
+
+// appendFloat appends v like strconv.AppendFloat(dst, v, 'g', -1, bitSize):
+// the fewest digits that read back as the same float of bitSize bits, with
+// an exponent below 1e-4 and from 1e+06 on.
+func appendFloat(dst []byte, v float64, bitSize int) []byte {
+	switch {
+	case v != v:
+		return append(dst, "NaN"...)
+	case math.IsInf(v, 1):
+		return append(dst, "+Inf"...)
+	case math.IsInf(v, -1):
+		return append(dst, "-Inf"...)
+	}
+	if math.Signbit(v) {
+		dst = append(dst, '-')
+		v = -v
+	}
+	if v == 0 {
+		return append(dst, '0')
+	}
+	var d decimal
+	if bitSize == 32 {
+		d.shortest(uint64(math.Float32bits(float32(v))), 23, 8, -127)
+	} else {
+		d.shortest(math.Float64bits(v), 52, 11, -1023)
+	}
+	digits, dp := d.d[:d.nd], d.dp
+
+	if exp := dp - 1; exp < -4 || exp >= 6 {
+		dst = append(dst, digits[0])
+		if len(digits) > 1 {
+			dst = append(dst, '.')
+			dst = append(dst, digits[1:]...)
+		}
+		dst = append(dst, 'e')
+		if exp < 0 {
+			dst = append(dst, '-')
+			exp = -exp
+		} else {
+			dst = append(dst, '+')
+		}
+		if exp < 10 {
+			dst = append(dst, '0')
+		}
+		return appendUint(dst, uint64(exp))
+	}
+	switch {
+	case dp <= 0:
+		dst = append(dst, "0."...)
+		for ; dp < 0; dp++ {
+			dst = append(dst, '0')
+		}
+		return append(dst, digits...)
+	case dp >= len(digits):
+		dst = append(dst, digits...)
+		for i := len(digits); i < dp; i++ {
+			dst = append(dst, '0')
+		}
+		return dst
+	}
+	dst = append(dst, digits[:dp]...)
+	dst = append(dst, '.')
+	return append(dst, digits[dp:]...)
+}
+
+// decimal is an exact decimal number 0.d[:nd] × 10^dp, used to find the
+// shortest digits of a float the way strconv does when its fast paths fail:
+// the float and the midpoints to its neighbours are written out exactly, and
+// the digits are cut where they stop telling the three apart.
+type decimal struct {
+	d     [800]byte // enough for the exact value of any float64
+	nd    int
+	dp    int
+	trunc bool // nonzero digits were dropped past d[:nd]
+}
+
+// maxShift is the largest shift done at once: a digit shifted by it plus
+// the carry still fits in a uint64.
+const maxShift = 59
+
+// shortest sets d to the shortest digits of the positive finite float with
+// the given bits, mantissa and exponent widths and exponent bias.
+func (d *decimal) shortest(bits uint64, mantBits, expBits uint, bias int) {
+	exp := int(bits>>mantBits) & (1<<expBits - 1)
+	mant := bits & (1<<mantBits - 1)
+	if exp == 0 {
+		exp++ // denormal
+	} else {
+		mant |= 1 << mantBits
+	}
+	exp += bias
+
+	d.assign(mant)
+	d.shift(exp - int(mantBits))
+
+	minExp := bias + 1
+	if exp > minExp && 332*(d.dp-d.nd) >= 100*(exp-int(mantBits)) {
+		return // an integer small enough to be exact already
+	}
+
+	// The float is the only one between the midpoints lower and upper.
+	var upper, lower decimal
+	upper.assign(mant*2 + 1)
+	upper.shift(exp - int(mantBits) - 1)
+	mantLo, expLo := mant*2-1, exp-1
+	if mant > 1<<mantBits || exp == minExp {
+		mantLo, expLo = mant-1, exp
+	}
+	lower.assign(mantLo*2 + 1)
+	lower.shift(expLo - int(mantBits) - 1)
+
+	// An even mantissa wins ties, so the midpoints themselves read back as it.
+	inclusive := mant%2 == 0
+
+	var upperDelta byte // 0: upper equals d so far, 1: differs by one ulp, 2: more
+	for ui := 0; ; ui++ {
+		mi := ui - upper.dp + d.dp
+		if mi >= d.nd {
+			break
+		}
+		li := ui - upper.dp + lower.dp
+		l := byte('0')
+		if li >= 0 && li < lower.nd {
+			l = lower.d[li]
+		}
+		m := byte('0')
+		if mi >= 0 {
+			m = d.d[mi]
+		}
+		u := byte('0')
+		if ui < upper.nd {
+			u = upper.d[ui]
+		}
+
+		okDown := l != m || inclusive && li+1 == lower.nd
+		switch {
+		case upperDelta == 0 && m+1 < u:
+			upperDelta = 2
+		case upperDelta == 0 && m != u:
+			upperDelta = 1
+		case upperDelta == 1 && (m != '9' || u != '0'):
+			upperDelta = 2
+		}
+		okUp := upperDelta > 0 && (inclusive || upperDelta > 1 || ui+1 < upper.nd)
+
+		switch {
+		case okDown && okUp:
+			d.round(mi + 1)
+			return
+		case okDown:
+			d.roundDown(mi + 1)
+			return
+		case okUp:
+			d.roundUp(mi + 1)
+			return
+		}
+	}
+}
+
+func (d *decimal) assign(v uint64) {
+	var buf [20]byte
+	n := 0
+	for ; v > 0; v /= 10 {
+		buf[n] = byte(v%10) + '0'
+		n++
+	}
+	d.nd, d.dp, d.trunc = n, n, false
+	for i := 0; i < n; i++ {
+		d.d[i] = buf[n-1-i]
+	}
+	d.trim()
+}
+
+// trim drops trailing zeros.
+func (d *decimal) trim() {
+	for d.nd > 0 && d.d[d.nd-1] == '0' {
+		d.nd--
+	}
+	if d.nd == 0 {
+		d.dp = 0
+	}
+}
+
+// shift multiplies d by 2^k.
+func (d *decimal) shift(k int) {
+	switch {
+	case d.nd == 0:
+	case k > 0:
+		for ; k > maxShift; k -= maxShift {
+			d.leftShift(maxShift)
+		}
+		d.leftShift(uint(k))
+	case k < 0:
+		for ; k < -maxShift; k += maxShift {
+			d.rightShift(maxShift)
+		}
+		d.rightShift(uint(-k))
+	}
+}
+
+// leftShift multiplies d by 2^k, from the last digit up, and prepends the
+// final carry.
+func (d *decimal) leftShift(k uint) {
+	var carry uint64
+	for i := d.nd - 1; i >= 0; i-- {
+		n := uint64(d.d[i]-'0')<<k + carry
+		d.d[i] = byte(n%10) + '0'
+		carry = n / 10
+	}
+	var buf [20]byte
+	m := 0
+	for ; carry > 0; carry /= 10 {
+		buf[m] = byte(carry%10) + '0'
+		m++
+	}
+	copy(d.d[m:], d.d[:d.nd])
+	for i := 0; i < m; i++ {
+		d.d[i] = buf[m-1-i]
+	}
+	d.nd += m
+	d.dp += m
+	d.trim()
+}
+
+// rightShift divides d by 2^k, like long division from the first digit.
+func (d *decimal) rightShift(k uint) {
+	r, w := 0, 0 // read and write positions
+	var n uint64
+	for ; n>>k == 0; r++ {
+		if r >= d.nd {
+			if n == 0 {
+				d.nd = 0
+				return
+			}
+			for n>>k == 0 {
+				n *= 10
+				r++
+			}
+			break
+		}
+		n = n*10 + uint64(d.d[r]-'0')
+	}
+	d.dp -= r - 1
+
+	mask := uint64(1)<<k - 1
+	for ; r < d.nd; r++ {
+		c := d.d[r]
+		d.d[w] = byte(n>>k) + '0'
+		w++
+		n = (n&mask)*10 + uint64(c-'0')
+	}
+	for n > 0 {
+		dig := n >> k
+		n &= mask
+		if w < len(d.d) {
+			d.d[w] = byte(dig) + '0'
+			w++
+		} else if dig > 0 {
+			d.trunc = true
+		}
+		n *= 10
+	}
+	d.nd = w
+	d.trim()
+}
+
+// round cuts d to nd digits, rounding half to even.
+func (d *decimal) round(nd int) {
+	if nd < 0 || nd >= d.nd {
+		return
+	}
+	up := d.d[nd] >= '5'
+	if d.d[nd] == '5' && nd+1 == d.nd && !d.trunc {
+		up = nd > 0 && (d.d[nd-1]-'0')%2 == 1 // exactly halfway
+	}
+	if up {
+		d.roundUp(nd)
+	} else {
+		d.roundDown(nd)
+	}
+}
+
+func (d *decimal) roundDown(nd int) {
+	if nd < 0 || nd >= d.nd {
+		return
+	}
+	d.nd = nd
+	d.trim()
+}
+
+func (d *decimal) roundUp(nd int) {
+	if nd < 0 || nd >= d.nd {
+		return
+	}
+	for i := nd - 1; i >= 0; i-- {
+		if d.d[i] < '9' {
+			d.d[i]++
+			d.nd = i + 1
+			return
+		}
+	}
+	d.d[0] = '1' // all nines
+	d.nd = 1
+	d.dp++
+}
@@ -0,0 +1,124 @@
+package binary
+
+import (
+	"math"
+	"math/rand"
+	"strconv"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestSprint(t *testing.T) {
+	v := &FixtureComplex{
+		ID: 1,
+		Primary: FixtureBasic{
+			Name:    "Alice \"A\"",
+			Payload: []byte{0x01, 0xab},
+			Tags:    []uint32{1, 2},
+			Active:  true,
+			Score:   1.5,
+		},
+		List:   []FixtureBasic{{Name: "x"}, {}},
+		Matrix: [3]int{1, 2, 3},
+	}
+	want := `{ID: 1, Primary: {Name: "Alice \"A\"", Timestamp: 0, Payload: 0x01ab, Tags: [1, 2], Count: 0, Active: true, Score: 1.5}, ` +
+		`Secondary: <nil>, ` +
+		`List: [{Name: "x", Timestamp: 0, Payload: 0x, Tags: [], Count: 0, Active: false, Score: 0}, ` +
+		`{Name: "", Timestamp: 0, Payload: 0x, Tags: [], Count: 0, Active: false, Score: 0}], ` +
+		`Matrix: [1, 2, 3]}`
+	if got := Sprint(v); got != want {
+		t.Errorf("Expected\n%s\ngot\n%s", want, got)
+	}
+
+	msg := &Message{Topic: "users.created", ID: 9, Payload: []byte{0xff}}
+	if got := Sprint(msg); got != `{Topic: "users.created", Type: 0, ID: 9, Payload: 0xff, Fingerprint: <nil>}` {
+		t.Errorf("Unexpected message text: %s", got)
+	}
+
+	if got := Sprint((*Message)(nil)); got != "<nil>" {
+		t.Errorf("Expected <nil>, got %s", got)
+	}
+}
+
+func TestSprintWidth(t *testing.T) {
+	v := &FixtureBasic{Name: "ééééééééé", Tags: make([]uint32, 1000)}
+	full := Sprint(v)
+	if got := SprintWidth(v, 0); got != full {
+		t.Errorf("Expected unlimited output for width 0, got %s", got)
+	}
+	for _, width := range []int{1, 5, 12, 30, len(full) - 1} {
+		got := SprintWidth(v, width)
+		if len(got) > width && len(got) > 3 || !strings.HasSuffix(got, "...") || !utf8.ValidString(got) {
+			t.Errorf("Width %d: unexpected output %q", width, got)
+		}
+		if !strings.HasPrefix(full, strings.TrimSuffix(got, "...")) {
+			t.Errorf("Width %d: %q is not a prefix of the full output", width, got)
+		}
+	}
+	if got := SprintWidth(v, len(full)); got != full {
+		t.Errorf("Expected full output when it fits, got %s", got)
+	}
+}
+
+func TestSprintNumbers(t *testing.T) {
+	for _, v := range []int64{0, 7, -42, math.MaxInt64, math.MinInt64, math.MinInt64 + 1} {
+		if got, want := string(appendInt(nil, v)), strconv.FormatInt(v, 10); got != want {
+			t.Errorf("Expected %s, got %s", want, got)
+		}
+	}
+	for _, v := range []uint64{0, math.MaxInt64, math.MaxInt64 + 1, math.MaxUint64} {
+		if got, want := string(appendUint(nil, v)), strconv.FormatUint(v, 10); got != want {
+			t.Errorf("Expected %s, got %s", want, got)
+		}
+	}
+
+	floats := []float64{0, math.Copysign(0, -1), 1, -2.25, 0.1, 1.0 / 3, 100000, 1e6, 123456789, 1e-4, 1.5e-5,
+		1e21, 6.02214076e23, math.Pi, math.SmallestNonzeroFloat64, math.Inf(1), math.Inf(-1), math.NaN()}
+	for _, v := range floats {
+		if got, want := string(appendFloat(nil, v, 64)), strconv.FormatFloat(v, 'g', -1, 64); got != want {
+			t.Errorf("Expected %s, got %s", want, got)
+		}
+		f := float64(float32(v))
+		if got, want := string(appendFloat(nil, f, 32)), strconv.FormatFloat(f, 'g', -1, 32); got != want {
+			t.Errorf("float32: expected %s, got %s", want, got)
+		}
+	}
+
+	if got := string(appendQuote(nil, "a\"\\\n\té")); got != `"a\"\\\n\té"` {
+		t.Errorf("Unexpected quote: %s", got)
+	}
+}
+
+func TestSprintFloatRoundTrip(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	values := []float64{0.10072648451569735, -110777.50684827805, 1.9943204194383547e+06,
+		math.MaxFloat64, -math.SmallestNonzeroFloat64, 1e23, 5e-324, 2.2250738585072014e-308}
+	for i := 0; i < 20000; i++ {
+		values = append(values, math.Float64frombits(r.Uint64()), (r.Float64()-0.5)*math.Pow(10, float64(r.Intn(30)-12)))
+	}
+	for _, v := range values {
+		if math.IsNaN(v) {
+			continue
+		}
+		got := Sprint(&FixtureBasic{Score: v})
+		text := got[strings.LastIndex(got, "Score: ")+len("Score: ") : len(got)-1]
+		back, err := strconv.ParseFloat(text, 64)
+		if err != nil || math.Float64bits(back) != math.Float64bits(v) {
+			t.Fatalf("%v printed as %s, read back as %v, %v", v, text, back, err)
+		}
+		if want := strconv.FormatFloat(v, 'g', -1, 64); text != want {
+			t.Fatalf("Expected %s, got %s", want, text)
+		}
+
+		f := math.Float32frombits(r.Uint32())
+		if math.IsNaN(float64(f)) {
+			continue
+		}
+		text = string(appendFloat(nil, float64(f), 32))
+		back, err = strconv.ParseFloat(text, 32)
+		if err != nil || math.Float32bits(float32(back)) != math.Float32bits(f) {
+			t.Fatalf("float32 %v printed as %s, read back as %v, %v", f, text, back, err)
+		}
+	}
+}
@@ -0,0 +1,103 @@
+package binary
+
+import (
+	"time"
+
+	"github.com/tinywasm/model"
+)
+
+// WriteTime writes t as the Object field name:
+//
+//	Seconds int    // t.Unix()
+//	Nanos   int    // t.Nanosecond()
+//	Offset  *int   // zone offset east of UTC in seconds, absent for UTC
+//	Zone    string // zone abbreviation such as "CET", empty for UTC
+//
+// Unlike UnixNano this covers every year time.Time can hold, and it keeps
+// the zone offset. The monotonic clock reading is dropped.
+func WriteTime(w model.FieldWriter, name string, t time.Time) {
+	w.Object(name, &timeCodec{t: &t})
+}
+
+// ReadTime reads a time written by WriteTime. A time in UTC, including the
+// zero time, round-trips exactly. Other zones are restored as time.Local
+// when it has the same abbreviation and offset at that instant, and as a
+// time.FixedZone otherwise, so no tzdata is needed.
+func ReadTime(r model.FieldReader, name string) (time.Time, bool) {
+	var t time.Time
+	ok := r.Object(name, &timeCodec{t: &t})
+	return t, ok
+}
+
+// TimeOf adapts *t to model.Encodable and model.Decodable, for times held in
+// arrays, map values or at the top level:
+//
+//	aw.Object(binary.TimeOf(&x.Times[i]))
+func TimeOf(t *time.Time) interface {
+	model.Encodable
+	model.Decodable
+} {
+	return &timeCodec{t: t}
+}
+
+// WriteDuration writes d as an Int of nanoseconds, which covers the whole
+// range of time.Duration.
+func WriteDuration(w model.FieldWriter, name string, d time.Duration) {
+	w.Int(name, int64(d))
+}
+
+// ReadDuration reads a duration written by WriteDuration.
+func ReadDuration(r model.FieldReader, name string) (time.Duration, bool) {
+	v, ok := r.Int(name)
+	return time.Duration(v), ok
+}
+
+// timeCodec encodes and decodes the time t points to.
+type timeCodec struct {
+	t *time.Time
+}
+
+// IsNil implements model.Encodable and model.Decodable
+func (c *timeCodec) IsNil() bool {
+	return c == nil || c.t == nil
+}
+
+// EncodeFields implements model.Encodable
+func (c *timeCodec) EncodeFields(w model.FieldWriter) {
+	t := *c.t
+	w.Int("Seconds", t.Unix())
+	w.Int("Nanos", int64(t.Nanosecond()))
+	var offset *int
+	var zone string
+	if t.Location() != time.UTC {
+		var off int
+		zone, off = t.Zone()
+		offset = &off
+	}
+	WriteOptional(w, "Offset", offset)
+	w.String("Zone", zone)
+}
+
+// DecodeFields implements model.Decodable
+func (c *timeCodec) DecodeFields(r model.FieldReader) {
+	sec, _ := r.Int("Seconds")
+	nsec, _ := r.Int("Nanos")
+	offset, _ := ReadOptional[int](r, "Offset")
+	zone, _ := r.String("Zone")
+	if nsec < 0 || nsec >= 1e9 {
+		if f, ok := r.(failer); ok {
+			f.fail(ErrInvalidInput)
+		}
+		return
+	}
+
+	t := time.Unix(sec, nsec).UTC()
+	if offset != nil {
+		if name, off := t.In(time.Local).Zone(); name == zone && off == *offset {
+			t = t.In(time.Local)
+		} else {
+			t = t.In(time.FixedZone(zone, *offset))
+		}
+	}
+	*c.t = t
+}
@@ -0,0 +1,111 @@
+package binary
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/tinywasm/model"
+)
+
+type fixtureTimes struct {
+	When    time.Time
+	Timeout time.Duration
+	History []time.Time
+}
+
+func (f *fixtureTimes) EncodeFields(w model.FieldWriter) {
+	WriteTime(w, "When", f.When)
+	WriteDuration(w, "Timeout", f.Timeout)
+	aw := w.Array("History", len(f.History))
+	for i := range f.History {
+		aw.Object(TimeOf(&f.History[i]))
+	}
+}
+
+func (f *fixtureTimes) DecodeFields(r model.FieldReader) {
+	if v, ok := ReadTime(r, "When"); ok {
+		f.When = v
+	}
+	if v, ok := ReadDuration(r, "Timeout"); ok {
+		f.Timeout = v
+	}
+	if ar, ok := r.Array("History"); ok {
+		f.History = make([]time.Time, ar.Len())
+		for i := range f.History {
+			ar.Object(i, TimeOf(&f.History[i]))
+		}
+	}
+}
+
+func (f *fixtureTimes) IsNil() bool { return f == nil }
+
+func TestTimeRoundTrip(t *testing.T) {
+	cet := time.FixedZone("CET", 3600)
+	cases := map[string]time.Time{
+		"zero":      {},
+		"utc":       time.Date(2024, 2, 29, 23, 59, 59, 999999999, time.UTC),
+		"unix":      time.Unix(0, 0).UTC(),
+		"pre-1678":  time.Date(1066, 10, 14, 9, 0, 0, 1, time.UTC),
+		"post-2262": time.Date(9999, 12, 31, 23, 59, 59, 0, time.UTC),
+		"negative":  time.Date(-4000, 1, 1, 0, 0, 0, 5, time.UTC),
+		"local":     time.Date(2024, 6, 1, 12, 0, 0, 0, time.Local),
+		"fixed":     time.Date(2024, 6, 1, 12, 0, 0, 0, cet),
+	}
+	for name, when := range cases {
+		in := &fixtureTimes{When: when, Timeout: -90 * time.Second, History: []time.Time{when, {}}}
+		var data []byte
+		if err := Encode(in, &data); err != nil {
+			t.Fatalf("%s: Encode failed: %v", name, err)
+		}
+		out := &fixtureTimes{}
+		if err := Decode(data, out); err != nil {
+			t.Fatalf("%s: Decode failed: %v", name, err)
+		}
+		if name == "fixed" {
+			// A fixed zone is rebuilt, so compare instant and zone.
+			zone, off := out.When.Zone()
+			if !out.When.Equal(when) || zone != "CET" || off != 3600 || out.When.String() != when.String() {
+				t.Errorf("%s: expected %v, got %v", name, when, out.When)
+			}
+			continue
+		}
+		if out.When != when || out.History[0] != when || out.History[1] != (time.Time{}) || out.Timeout != in.Timeout {
+			t.Errorf("%s: expected %v, got %v (%v)", name, when, out.When, out.History)
+		}
+		if name == "zero" && !out.When.IsZero() {
+			t.Errorf("Expected the zero time, got %v", out.When)
+		}
+	}
+}
+
+func TestDurationRange(t *testing.T) {
+	for _, d := range []time.Duration{0, 1, -1, math.MaxInt64, math.MinInt64} {
+		out := &fixtureTimes{}
+		var data []byte
+		if err := Encode(&fixtureTimes{Timeout: d}, &data); err != nil {
+			t.Fatal(err)
+		}
+		if err := Decode(data, out); err != nil || out.Timeout != d {
+			t.Errorf("Expected %v, got %v, %v", d, out.Timeout, err)
+		}
+	}
+}
+
+func TestTimeTopLevelAndErrors(t *testing.T) {
+	when := time.Date(2000, 1, 2, 3, 4, 5, 6, time.UTC)
+	data, err := Marshal(TimeOf(&when))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got time.Time
+	if err := Decode(data, TimeOf(&got)); err != nil || got != when {
+		t.Errorf("Expected %v, got %v, %v", when, got, err)
+	}
+
+	// Nanos must be within a second.
+	bad := []byte{1, 0, 0x80, 0xa8, 0xd6, 0xb9, 0x07, 0, 0}
+	if err := Decode(bad, &fixtureTimes{}); err != ErrInvalidInput {
+		t.Errorf("Expected ErrInvalidInput, got %v", err)
+	}
+}